@@ -9,13 +9,17 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/cauldnclark/todo-go/internal/auth"
 	"github.com/cauldnclark/todo-go/internal/cache"
 	"github.com/cauldnclark/todo-go/internal/config"
 	"github.com/cauldnclark/todo-go/internal/handlers"
 	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/oidc"
 	"github.com/cauldnclark/todo-go/internal/ratelimit"
 	"github.com/cauldnclark/todo-go/internal/redis"
 	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/revocation"
 	"github.com/cauldnclark/todo-go/internal/service"
 	"github.com/cauldnclark/todo-go/internal/websocket"
 	"github.com/go-chi/chi/v5"
@@ -57,21 +61,74 @@ func main() {
 	redisCache := cache.NewRedisCache(redisClient)
 	log.Println("Redis cache initialized")
 
+	revocations := revocation.NewStore(redisClient)
+	keys, signingKeyID, err := loadSigningKeys(cfg.Server)
+	if err != nil {
+		log.Fatalf("Error loading signing keys: %v", err)
+	}
+	tokens, err := auth.NewManager(auth.Config{
+		Keys:         keys,
+		SigningKeyID: signingKeyID,
+		Issuer:       cfg.Server.JWTIssuer,
+		Audience:     cfg.Server.JWTAudience,
+		ClockSkew:    cfg.Server.JWTClockSkew,
+	}, revocations)
+	if err != nil {
+		log.Fatalf("Error configuring token signing: %v", err)
+	}
+
 	hub := websocket.NewHub(redisClient)
 	go hub.Run() // Start the hub to handle WebSocket connections
-	wsHandler := websocket.NewHandler(hub)
+	wsHandler := websocket.NewHandler(hub, tokens)
 	defer dbpool.Close()
 
 	userRepo := repository.NewUserRepository(dbpool)
 	todoRepo := repository.NewTodoRepository(dbpool)
+	timeRepo := repository.NewTimeEntryRepository(dbpool)
+	templateRepo := repository.NewTemplateRepository(dbpool)
+	feedRepo := repository.NewFeedRepository(dbpool)
+	appPasswordRepo := repository.NewAppPasswordRepository(dbpool)
+	refreshRepo := repository.NewRefreshTokenRepository(dbpool)
+	sessionRepo := repository.NewSessionRepository(dbpool)
+	personalTokenRepo := repository.NewPersonalAccessTokenRepository(dbpool)
+	identityRepo := repository.NewIdentityRepository(dbpool)
 
-	userService := service.NewUserService(userRepo, cfg.Server.JWTSecret, cfg.Google.ClientID, cfg.Google.ClientSecret, cfg.Google.RedirectURL)
-	todoService := service.NewTodoService(todoRepo, redisCache, hub)
+	sessionService := service.NewSessionService(sessionRepo, refreshRepo, userRepo, revocations, hub, tokens)
+	oauthStates := service.NewOAuthStateStore(redisCache, cfg.Server.OAuthStateSecret)
+	identityProviders := loadIdentityProviders(cfg)
+	userService := service.NewUserService(userRepo, identityRepo, sessionService, oauthStates, identityProviders)
+	identityService := service.NewIdentityService(identityRepo, oauthStates, identityProviders)
+	todoService := service.NewTodoService(todoRepo, userRepo, redisCache, hub)
+	timeService := service.NewTimeService(timeRepo, todoRepo, userRepo, hub)
+	templateService := service.NewTemplateService(templateRepo, todoRepo, userRepo, hub)
+	importService := service.NewImportService(todoRepo, redisCache, hub)
+	feedService := service.NewFeedService(feedRepo, todoRepo, cfg.Server.PublicURL)
+	syncService := service.NewSyncService(todoRepo, redisCache, hub)
+	caldavService := service.NewCalDAVService(todoRepo, appPasswordRepo, userRepo, redisCache, hub)
+	personalTokenService := service.NewPersonalAccessTokenService(personalTokenRepo)
+	accountService := service.NewAccountService(userRepo, todoRepo, templateRepo, timeRepo, feedRepo, appPasswordRepo, identityRepo, redisCache, hub, revocations)
 
-	authHandler := handlers.NewAuthHandler(userService)
+	authHandler := handlers.NewAuthHandler(userService, sessionService, tokens)
+	userHandler := handlers.NewUserHandler(userService, accountService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	identityHandler := handlers.NewIdentityHandler(identityService)
+	personalTokenHandler := handlers.NewPersonalAccessTokenHandler(personalTokenService)
 	todoHandler := handlers.NewTodoHandler(todoService, userService)
+	timeHandler := handlers.NewTimeHandler(timeService)
+	templateHandler := handlers.NewTemplateHandler(templateService)
+	importHandler := handlers.NewImportHandler(importService)
+	exportHandler := handlers.NewExportHandler(todoService)
+	feedHandler := handlers.NewFeedHandler(feedService)
+	caldavHandler := handlers.NewCalDAVHandler(caldavService)
+	syncHandler := handlers.NewSyncHandler(syncService)
+
+	authMiddleware := middleware.NewAuthMiddleware(tokens, personalTokenService)
+	idempotencyMiddleware := middleware.NewIdempotencyMiddleware(redisClient, 24*time.Hour, handlers.MaxImportSize)
 
-	authMiddleware := middleware.NewAuthMiddleware(cfg.Server.JWTSecret)
+	// WebDAV methods used by CalDAV clients.
+	chi.RegisterMethod("PROPFIND")
+	chi.RegisterMethod("PROPPATCH")
+	chi.RegisterMethod("REPORT")
 
 	r := chi.NewRouter()
 
@@ -79,46 +136,165 @@ func main() {
 	r.Use(chimiddle.Recoverer)
 	r.Use(chimiddle.RequestID)
 	r.Use(chimiddle.RealIP)
-	r.Use(chimiddle.Timeout(60 * time.Second))
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match", "Idempotency-Key", "API-Version"},
+		ExposedHeaders:   []string{"Link", "ETag", "Location", "Accept-Patch", "Idempotent-Replayed", "API-Version"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	r.Get("/ws", wsHandler.ServeHTTP)
+	rateLimiter := ratelimit.NewRateLimiter(redisClient, "sliding")
 
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	r.Group(func(r chi.Router) {
+		r.Use(chimiddle.Timeout(60 * time.Second))
 
-	r.Route("/auth", func(r chi.Router) {
-		r.Post("/google", authHandler.GoogleSignIn)
-	})
+		r.Get("/ws", wsHandler.ServeHTTP)
 
-	rateLimiter := ratelimit.NewRateLimiter(redisClient, "sliding")
+		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("OK"))
+		})
+
+		// Calendar subscriptions authenticate with the secret token in the URL,
+		// so guessing is throttled per client IP.
+		r.With(middleware.IPRateLimitMiddleware(rateLimiter, 60, time.Minute)).Get("/feeds/{token}", feedHandler.ServeFeed)
 
-	r.Route("/api", func(r chi.Router) {
-		r.Use(authMiddleware.Authenticate)
-		r.Use(middleware.RateLimitMiddleware(rateLimiter, 100, time.Minute))
-
-		r.Route("/todos", func(r chi.Router) {
-			r.Get("/", todoHandler.GetTodos)
-			r.Get("/{id}", todoHandler.GetTodoByID)
-			r.Post("/", todoHandler.CreateTodo)
-			r.Put("/{id}", todoHandler.UpdateTodo)
-			r.Delete("/{id}", todoHandler.DeleteTodo)
-			r.Delete("/{id}/cache", todoHandler.ClearTodoCache)
+		r.Get("/.well-known/jwks.json", authHandler.JWKS)
+
+		r.Route("/auth", func(r chi.Router) {
+			r.Get("/providers", authHandler.GetProviders)
+			r.Get("/{provider}/start", authHandler.StartSignIn)
+			r.Post("/{provider}", authHandler.SignIn)
+			r.Post("/refresh", authHandler.Refresh)
+			r.Post("/logout", authHandler.Logout)
+		})
+
+		// CalDAV clients sign in with HTTP Basic auth and an app password.
+		r.Handle("/.well-known/caldav", http.HandlerFunc(caldavHandler.WellKnown))
+		r.Route("/caldav", func(r chi.Router) {
+			r.Use(caldavHandler.Authenticate)
+			r.Use(middleware.RateLimitMiddleware(rateLimiter, 300, time.Minute))
+			r.Handle("/*", caldavHandler)
 		})
 
-		r.Get("/me", authHandler.GetCurrentUser)
+		r.Route("/api", func(r chi.Router) {
+			r.Use(authMiddleware.Authenticate)
+			r.Use(middleware.RateLimitMiddleware(rateLimiter, 100, time.Minute))
+			r.Use(middleware.APIVersionMiddleware(cfg.Server.DefaultAPIVersion))
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireReadWriteScope(models.ScopeTodosRead, models.ScopeTodosWrite))
+				r.Use(idempotencyMiddleware.Handle)
+
+				r.Route("/todos", func(r chi.Router) {
+					r.Get("/", todoHandler.GetTodos)
+					r.Get("/{id}", todoHandler.GetTodoByID)
+					r.Post("/", todoHandler.CreateTodo)
+					r.Post("/quick", todoHandler.QuickAddTodo)
+					r.Put("/{id}", todoHandler.UpdateTodo)
+					r.Patch("/{id}", todoHandler.PatchTodo)
+					r.Delete("/{id}", todoHandler.DeleteTodo)
+					r.Delete("/{id}/cache", todoHandler.ClearTodoCache)
+					r.Post("/{id}/timer/start", timeHandler.StartTimer)
+					r.Get("/{id}/time-entries", timeHandler.GetTimeEntries)
+					r.Post("/{id}/time-entries", timeHandler.CreateTimeEntry)
+					r.Post("/{id}/template", templateHandler.SaveTodoAsTemplate)
+				})
+
+				r.Route("/templates", func(r chi.Router) {
+					r.Get("/", templateHandler.GetTemplates)
+					r.Post("/", templateHandler.CreateTemplate)
+					r.Get("/{id}", templateHandler.GetTemplateByID)
+					r.Delete("/{id}", templateHandler.DeleteTemplate)
+					r.Post("/{id}/instantiate", templateHandler.InstantiateTemplate)
+				})
+
+				r.Route("/timer", func(r chi.Router) {
+					r.Get("/", timeHandler.GetTimer)
+					r.Post("/stop", timeHandler.StopTimer)
+				})
+
+				r.Post("/import", importHandler.Import)
+				r.Get("/import/{id}", importHandler.GetImportJob)
+
+				r.Get("/sync", syncHandler.GetChanges)
+				r.Post("/sync", syncHandler.PushChanges)
+
+				r.Route("/feeds", func(r chi.Router) {
+					r.Get("/", feedHandler.GetFeeds)
+					r.Post("/", feedHandler.CreateFeed)
+					r.Post("/{id}/rotate", feedHandler.RotateFeedToken)
+					r.Delete("/{id}", feedHandler.RevokeFeed)
+				})
+
+				r.Delete("/time-entries/{id}", timeHandler.DeleteTimeEntry)
+				r.Get("/reports/time", timeHandler.GetTimeReport)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireReadWriteScope(models.ScopeAccountRead, models.ScopeAccountWrite))
+				r.Use(idempotencyMiddleware.Handle)
+
+				r.Get("/me", authHandler.GetCurrentUser)
+				r.With(middleware.RequireSession).Delete("/me", userHandler.DeleteAccount)
+				r.Post("/me/export", userHandler.StartExport)
+				r.Get("/me/export", userHandler.GetExport)
+				r.Get("/me/export/download", userHandler.DownloadExport)
+				r.Get("/me/settings", userHandler.GetSettings)
+				r.Patch("/me/settings", userHandler.UpdateSettings)
+
+				// Sessions, credentials and sign-in methods are managed from a
+				// signed-in session only, never with a personal access token:
+				// a token must not sign its owner out or mint a credential
+				// wider than itself, such as a CalDAV app password.
+				r.Route("/me/sessions", func(r chi.Router) {
+					r.Get("/", sessionHandler.GetSessions)
+					r.With(middleware.RequireSession).Delete("/{id}", sessionHandler.RevokeSession)
+				})
+
+				r.Route("/me/identities", func(r chi.Router) {
+					r.Use(middleware.RequireSession)
+					r.Get("/", identityHandler.GetIdentities)
+					r.Post("/", identityHandler.LinkIdentity)
+					r.Post("/start", identityHandler.StartLink)
+					r.Delete("/{id}", identityHandler.UnlinkIdentity)
+				})
+
+				r.Route("/me/tokens", func(r chi.Router) {
+					r.Use(middleware.RequireSession)
+					r.Get("/", personalTokenHandler.GetTokens)
+					r.Post("/", personalTokenHandler.CreateToken)
+					r.Delete("/{id}", personalTokenHandler.RevokeToken)
+				})
+
+				r.Route("/me/app-passwords", func(r chi.Router) {
+					r.Use(middleware.RequireSession)
+					r.Get("/", caldavHandler.GetAppPasswords)
+					r.Post("/", caldavHandler.CreateAppPassword)
+					r.Delete("/{id}", caldavHandler.RevokeAppPassword)
+				})
+			})
+		})
 	})
 
+	// Exports stream for as long as the account needs, so they run outside
+	// the 60 second request timeout with a deadline of their own.
+	r.With(
+		authMiddleware.Authenticate,
+		middleware.RateLimitMiddleware(rateLimiter, 100, time.Minute),
+		middleware.APIVersionMiddleware(cfg.Server.DefaultAPIVersion),
+		middleware.RequireReadWriteScope(models.ScopeTodosRead, models.ScopeTodosWrite),
+		chimiddle.Timeout(handlers.ExportTimeout),
+	).Get("/api/export", exportHandler.Export)
+
+	// Accounts are deleted for good once their grace period is over.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go accountService.RunDeletionWorker(workerCtx, time.Hour)
+
 	server := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
 		Handler:      r,
@@ -145,3 +321,57 @@ func main() {
 		logrus.Fatalf("Error shutting down server: %v", err)
 	}
 }
+
+// loadSigningKeys loads the configured JWT keys, falling back to a single
+// HS256 key for JWT_SECRET when no key files are configured.
+func loadSigningKeys(cfg config.ServerConfig) ([]*auth.Key, string, error) {
+	if len(cfg.JWTKeys) == 0 {
+		key, err := auth.NewHMACKey("default", cfg.JWTSecret)
+		if err != nil {
+			return nil, "", err
+		}
+		return []*auth.Key{key}, key.ID, nil
+	}
+
+	keys := make([]*auth.Key, 0, len(cfg.JWTKeys))
+	for _, keyCfg := range cfg.JWTKeys {
+		key, err := auth.LoadKey(keyCfg.ID, keyCfg.Algorithm, keyCfg.Path)
+		if err != nil {
+			return nil, "", err
+		}
+		keys = append(keys, key)
+	}
+	return keys, cfg.JWTSigningKeyID, nil
+}
+
+// loadIdentityProviders returns the sign-in providers that are configured.
+func loadIdentityProviders(cfg *config.Config) service.IdentityProviders {
+	var providers []service.IdentityProvider
+	if cfg.Google.ClientID != "" {
+		// Google also puts its issuer in ID tokens without the scheme.
+		issuer := oidc.NewProvider(oidc.Config{
+			IssuerURL:    cfg.Google.IssuerURL,
+			ExtraIssuers: []string{"accounts.google.com"},
+		})
+		client := service.OAuthClient{ID: cfg.Google.ClientID, Secret: cfg.Google.ClientSecret, RedirectURI: cfg.Google.RedirectURL}
+		providers = append(providers, service.NewOIDCIdentityProvider("google", client, issuer, true))
+	}
+	if cfg.GitHub.ClientID != "" {
+		client := service.OAuthClient{ID: cfg.GitHub.ClientID, Secret: cfg.GitHub.ClientSecret, RedirectURI: cfg.GitHub.RedirectURL}
+		providers = append(providers, service.NewGitHubIdentityProvider(client))
+	}
+	if cfg.Microsoft.ClientID != "" {
+		// Microsoft does not send email_verified.
+		issuer := oidc.NewProvider(oidc.Config{
+			IssuerURL: "https://login.microsoftonline.com/" + cfg.Microsoft.Tenant + "/v2.0",
+		})
+		client := service.OAuthClient{ID: cfg.Microsoft.ClientID, Secret: cfg.Microsoft.ClientSecret, RedirectURI: cfg.Microsoft.RedirectURL}
+		providers = append(providers, service.NewOIDCIdentityProvider("microsoft", client, issuer, false))
+	}
+	for _, provider := range cfg.OIDC {
+		issuer := oidc.NewProvider(oidc.Config{IssuerURL: provider.IssuerURL})
+		client := service.OAuthClient{ID: provider.ClientID, Secret: provider.ClientSecret, RedirectURI: provider.RedirectURL}
+		providers = append(providers, service.NewOIDCIdentityProvider(provider.Name, client, issuer, true))
+	}
+	return service.NewIdentityProviders(providers...)
+}
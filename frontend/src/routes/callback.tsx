@@ -9,6 +9,8 @@ export function GoogleCallback() {
       const code = urlParams.get("code");
       const state = urlParams.get("state");
       const error = urlParams.get("error");
+      const expectedState = sessionStorage.getItem("oauth_state");
+      sessionStorage.removeItem("oauth_state");
 
       if (error) {
         setTimeout(() => {
@@ -18,7 +20,8 @@ export function GoogleCallback() {
         return;
       }
 
-      if (!code) {
+      // Only finish sign-ins this browser started.
+      if (!code || !state || state !== expectedState) {
         setTimeout(() => {
           window.location.href = "/login";
         }, 2000);
@@ -39,12 +42,22 @@ export function GoogleCallback() {
 
       const data = await response.json();
 
-      console.log(data);
+      if (!response.ok) {
+        setTimeout(() => {
+          window.location.href = "/login";
+        }, 2000);
+        alert("Google login failed");
+        return;
+      }
 
       if (data.token) {
         localStorage.setItem("todo-auth-token", data.token);
       }
 
+      if (data.refresh_token) {
+        localStorage.setItem("todo-refresh-token", data.refresh_token);
+      }
+
       if (data.user) {
         localStorage.setItem("todo-auth-user", JSON.stringify(data.user));
       }
@@ -194,8 +194,8 @@ export default function TodoListPage() {
               <Button
                 variant="ghost"
                 size="sm"
-                onClick={() => {
-                  localStorage.removeItem("todo-auth-token");
+                onClick={async () => {
+                  await todoApi.logout();
                   window.location.href = "/login";
                 }}
                 className="p-2 h-auto w-auto text-slate-500 hover:text-slate-700 hover:bg-slate-100 transition-all duration-200"
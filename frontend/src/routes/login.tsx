@@ -6,8 +6,7 @@ import {
 } from "@/components/ui/card";
 import { LogIn } from "lucide-react";
 
-const GOOGLE_CLIENT_ID = import.meta.env.VITE_GOOGLE_CLIENT_ID || "";
-const GOOGLE_REDIRECT_URI = import.meta.env.VITE_GOOGLE_REDIRECT_URI || "";
+const API_URL = import.meta.env.VITE_API_URL;
 
 export default function GoogleLoginPage() {
   const todoAuthToken = localStorage.getItem("todo-auth-token");
@@ -18,28 +17,21 @@ export default function GoogleLoginPage() {
     }
   }, [todoAuthToken]);
 
-  const handleGoogleLogin = () => {
-    // Construct Google OAuth URL for authorization code flow
-    // Use environment variable for redirect URI or fallback to current origin
-    const redirectUri =
-      GOOGLE_REDIRECT_URI || window.location.origin + "/google";
-    const scope = "openid email profile";
-    const responseType = "code";
-    const state = Math.random().toString(36).substring(2, 15);
-
-    const googleAuthUrl =
-      `https://accounts.google.com/o/oauth2/v2/auth?` +
-      `client_id=${GOOGLE_CLIENT_ID}&` +
-      `redirect_uri=${encodeURIComponent(redirectUri)}&` +
-      `scope=${encodeURIComponent(scope)}&` +
-      `response_type=${responseType}&` +
-      `state=${state}`;
+  const handleGoogleLogin = async () => {
+    // The server builds the Google URL, with a signed state and a PKCE
+    // challenge for the code exchange.
+    const response = await fetch(`${API_URL}/auth/google/start`);
+    if (!response.ok) {
+      alert("Google login failed");
+      return;
+    }
+    const { authorization_url, state } = await response.json();
 
-    // Store state in sessionStorage for verification
+    // Store state in sessionStorage so the callback can check it came back
     sessionStorage.setItem("oauth_state", state);
 
     // Redirect to Google OAuth
-    window.location.href = googleAuthUrl;
+    window.location.href = authorization_url;
   };
 
   return (
@@ -3,27 +3,109 @@ import type {
   TodosPaginated,
   CreateTodoRequest,
   UpdateTodoRequest,
+  TodoPatch,
   TodoFilters,
+  ApiEnvelope,
+  MetaPagination,
   ApiError,
+  AuthResponse,
+  ProblemDetails,
 } from "@/types/api";
 
 const API_URL = import.meta.env.VITE_API_URL;
+const API_VERSION = "2";
+
+const TOKEN_KEY = "todo-auth-token";
+const REFRESH_TOKEN_KEY = "todo-refresh-token";
 
 class TodoApiService {
+  private refreshing: Promise<boolean> | null = null;
+
   private getAuthHeaders(): HeadersInit {
-    const token = localStorage.getItem("todo-auth-token");
+    const token = localStorage.getItem(TOKEN_KEY);
     return {
       "Content-Type": "application/json",
+      "API-Version": API_VERSION,
       ...(token && { Authorization: `Bearer ${token}` }),
     };
   }
 
+  // Access tokens are short-lived: when one is rejected, renew it with the
+  // refresh token and retry the request once.
+  private async request(url: string, init: RequestInit = {}): Promise<Response> {
+    const send = () =>
+      fetch(url, {
+        ...init,
+        headers: { ...this.getAuthHeaders(), ...init.headers },
+      });
+
+    const response = await send();
+    if (response.status === 401 && (await this.refreshTokens())) {
+      return send();
+    }
+    return response;
+  }
+
+  // Concurrent requests share one refresh, since each refresh token only
+  // works once.
+  private refreshTokens(): Promise<boolean> {
+    if (!this.refreshing) {
+      this.refreshing = this.exchangeRefreshToken().finally(() => {
+        this.refreshing = null;
+      });
+    }
+    return this.refreshing;
+  }
+
+  private async exchangeRefreshToken(): Promise<boolean> {
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+    if (!refreshToken) {
+      return false;
+    }
+
+    const response = await fetch(`${API_URL}/auth/refresh`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    if (!response.ok) {
+      localStorage.removeItem(TOKEN_KEY);
+      localStorage.removeItem(REFRESH_TOKEN_KEY);
+      return false;
+    }
+
+    const data: AuthResponse = await response.json();
+    localStorage.setItem(TOKEN_KEY, data.token);
+    localStorage.setItem(REFRESH_TOKEN_KEY, data.refresh_token);
+    return true;
+  }
+
+  async logout(): Promise<void> {
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+    localStorage.removeItem(TOKEN_KEY);
+    localStorage.removeItem(REFRESH_TOKEN_KEY);
+    if (refreshToken) {
+      await fetch(`${API_URL}/auth/logout`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+      }).catch(() => undefined);
+    }
+  }
+
   private async handleResponse<T>(response: Response): Promise<T> {
     if (!response.ok) {
-      const errorText = await response.text();
+      const problem: Partial<ProblemDetails> = await response
+        .json()
+        .catch(() => ({}));
       const error: ApiError = {
-        message: errorText || `HTTP error! status: ${response.status}`,
+        message:
+          problem.detail ||
+          problem.title ||
+          `HTTP error! status: ${response.status}`,
         status: response.status,
+        code: problem.code,
+        fields: problem.errors,
       };
       throw error;
     }
@@ -36,6 +118,11 @@ class TodoApiService {
     return response.json();
   }
 
+  private async handleData<T>(response: Response): Promise<T> {
+    const envelope = await this.handleResponse<ApiEnvelope<T>>(response);
+    return envelope.data;
+  }
+
   async getTodos(filters: TodoFilters = {}): Promise<TodosPaginated> {
     const params = new URLSearchParams();
 
@@ -53,45 +140,52 @@ class TodoApiService {
       params.toString() ? `?${params.toString()}` : ""
     }`;
 
-    const response = await fetch(url, {
-      method: "GET",
-      headers: this.getAuthHeaders(),
-    });
+    const response = await this.request(url, { method: "GET" });
 
-    return this.handleResponse<TodosPaginated>(response);
+    const envelope = await this.handleResponse<
+      ApiEnvelope<Todo[], MetaPagination>
+    >(response);
+    return { todos: envelope.data, meta: envelope.meta! };
   }
 
   async createTodo(todoData: CreateTodoRequest): Promise<Todo> {
-    const response = await fetch(`${API_URL}/api/todos`, {
+    const response = await this.request(`${API_URL}/api/todos`, {
       method: "POST",
-      headers: this.getAuthHeaders(),
       body: JSON.stringify(todoData),
     });
 
-    return this.handleResponse<Todo>(response);
+    return this.handleData<Todo>(response);
   }
 
   async updateTodo(id: number, todoData: UpdateTodoRequest): Promise<Todo> {
-    const response = await fetch(`${API_URL}/api/todos/${id}`, {
+    const response = await this.request(`${API_URL}/api/todos/${id}`, {
       method: "PUT",
-      headers: this.getAuthHeaders(),
       body: JSON.stringify(todoData),
     });
 
-    return this.handleResponse<Todo>(response);
+    return this.handleData<Todo>(response);
+  }
+
+  async patchTodo(id: number, patch: TodoPatch): Promise<Todo> {
+    const response = await this.request(`${API_URL}/api/todos/${id}`, {
+      method: "PATCH",
+      headers: { "Content-Type": "application/merge-patch+json" },
+      body: JSON.stringify(patch),
+    });
+
+    return this.handleData<Todo>(response);
   }
 
   async deleteTodo(id: number): Promise<void> {
-    const response = await fetch(`${API_URL}/api/todos/${id}`, {
+    const response = await this.request(`${API_URL}/api/todos/${id}`, {
       method: "DELETE",
-      headers: this.getAuthHeaders(),
     });
 
     await this.handleResponse<void>(response);
   }
 
   async toggleTodoCompletion(id: number, completed: boolean): Promise<Todo> {
-    return this.updateTodo(id, { completed });
+    return this.patchTodo(id, { completed });
   }
 }
 
@@ -2,20 +2,51 @@
 
 export interface User {
   id: number;
-  google_id: string;
   email: string;
   name: string;
   picture_url: string;
+  settings: UserSettings;
+  deletion_scheduled_at?: string;
   created_at: string;
   updated_at: string;
 }
 
+export type WeekStart = "monday" | "sunday" | "saturday";
+
+export type TodoSort =
+  | "created_asc"
+  | "created_desc"
+  | "due_date"
+  | "priority"
+  | "title";
+
+export interface UserSettings {
+  timezone: string;
+  locale: string;
+  week_start: WeekStart;
+  default_sort: TodoSort;
+  notifications: {
+    due_reminders: boolean;
+    reminder_minutes: number;
+    daily_digest: boolean;
+  };
+}
+
+export type Priority = "none" | "low" | "medium" | "high";
+
 export interface Todo {
   id: number;
   user_id: number;
   title: string;
   description: string;
   completed: boolean;
+  due_date: string | null;
+  priority: Priority;
+  recurrence: string;
+  labels: string[];
+  ical_uid: string;
+  client_id?: string;
+  version: number;
   created_at: string;
   updated_at: string;
 }
@@ -26,6 +57,12 @@ export interface MetaPagination {
   limit: number;
 }
 
+// Todo endpoints wrap their responses like this from API version 2 on.
+export interface ApiEnvelope<T, M = undefined> {
+  data: T;
+  meta?: M;
+}
+
 export interface TodosPaginated {
   todos: Todo[];
   meta: MetaPagination;
@@ -37,21 +74,111 @@ export interface CreateTodoRequest {
   completed?: boolean;
 }
 
+// Full replacement for PUT: fields left out are reset.
 export interface UpdateTodoRequest {
-  title?: string;
-  description?: string;
-  completed?: boolean;
+  title: string;
+  description: string;
+  completed: boolean;
+  due_date: string | null;
+  priority: Priority;
+  recurrence: string;
+  labels: string[];
+}
+
+// JSON Merge Patch for PATCH: only the fields present are changed, and null
+// clears a field.
+export type TodoPatch = {
+  [K in keyof UpdateTodoRequest]?: UpdateTodoRequest[K] | null;
+};
+
+export interface QuickAddRequest {
+  text: string;
+  timezone?: string;
+  dry_run?: boolean;
+}
+
+export interface QuickAddMatch {
+  kind: "label" | "priority" | "recurrence" | "date" | "time";
+  text: string;
+}
+
+export interface QuickAddParse {
+  input: string;
+  title: string;
+  due_date: string | null;
+  has_time: boolean;
+  recurrence?: string;
+  labels: string[];
+  priority: Priority;
+  timezone: string;
+  matches: QuickAddMatch[];
+}
+
+export interface QuickAddResponse {
+  parsed: QuickAddParse;
+  todo: Todo | null;
 }
 
 export interface ApiError {
   error?: string;
   message: string;
   status?: number;
+  code?: string;
+  fields?: FieldError[];
+}
+
+// Error responses are RFC 7807 problem details.
+export interface ProblemDetails {
+  type: string;
+  title: string;
+  status: number;
+  detail?: string;
+  instance?: string;
+  code: string;
+  errors?: FieldError[];
+}
+
+export interface FieldError {
+  field: string;
+  rule: string;
+  message: string;
 }
 
 export interface AuthResponse {
   user: User;
   token: string;
+  // Lifetime of token in seconds.
+  expires_in: number;
+  refresh_token: string;
+}
+
+export interface Session {
+  id: string;
+  user_id: number;
+  device: string;
+  user_agent: string;
+  ip_address: string;
+  created_at: string;
+  last_seen_at: string;
+  current: boolean;
+}
+
+export type Scope =
+  | "todos:read"
+  | "todos:write"
+  | "account:read"
+  | "account:write";
+
+// token is only present in the response that creates it.
+export interface PersonalAccessToken {
+  id: number;
+  user_id: number;
+  name: string;
+  scopes: Scope[];
+  expires_at: string | null;
+  last_used_at: string | null;
+  created_at: string;
+  token?: string;
 }
 
 // Frontend-specific types
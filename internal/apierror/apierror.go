@@ -0,0 +1,160 @@
+// Package apierror writes API errors as RFC 7807 problem details
+// (application/problem+json) with stable, machine-readable codes.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/cauldnclark/todo-go/internal/validation"
+)
+
+const (
+	ContentType = "application/problem+json"
+	typeBaseURI = "https://todo-go/problems/"
+)
+
+// Code identifies the kind of problem. Codes are part of the API: clients
+// may switch on them, so they never change once published.
+type Code string
+
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeValidation           Code = "validation_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeConflict             Code = "conflict"
+	CodePreconditionFailed   Code = "precondition_failed"
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeUnprocessable        Code = "unprocessable_entity"
+	CodeRateLimited          Code = "rate_limited"
+	CodeInternal             Code = "internal_error"
+)
+
+// Error is an error as clients see it. Detail is sent to the client; Err is
+// the underlying cause, which is logged but never sent.
+type Error struct {
+	Status int
+	Code   Code
+	Detail string
+	Fields []validation.FieldError
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Problem is the problem details document written to the client.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     Code   `json:"code"`
+	// Errors lists the invalid fields of a request that failed validation.
+	Errors []validation.FieldError `json:"errors,omitempty"`
+}
+
+func New(status int, code Code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(detail string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, detail)
+}
+
+func Unauthorized(detail string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func Forbidden(detail string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(detail string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+func MethodNotAllowed(detail string) *Error {
+	return New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, detail)
+}
+
+func Conflict(detail string) *Error {
+	return New(http.StatusConflict, CodeConflict, detail)
+}
+
+func PreconditionFailed(detail string) *Error {
+	return New(http.StatusPreconditionFailed, CodePreconditionFailed, detail)
+}
+
+func PayloadTooLarge(detail string) *Error {
+	return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, detail)
+}
+
+func UnsupportedMediaType(detail string) *Error {
+	return New(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, detail)
+}
+
+func Unprocessable(detail string) *Error {
+	return New(http.StatusUnprocessableEntity, CodeUnprocessable, detail)
+}
+
+func RateLimited(detail string) *Error {
+	return New(http.StatusTooManyRequests, CodeRateLimited, detail)
+}
+
+// Invalid reports the fields of a request that failed validation.
+func Invalid(status int, err *validation.Error) *Error {
+	return &Error{Status: status, Code: CodeValidation, Detail: "Request validation failed", Fields: err.Fields}
+}
+
+// Internal reports a failure on our side. detail should say what failed in
+// general terms; err is only logged.
+func Internal(detail string, err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: detail, Err: err}
+}
+
+// Write sends err as a problem document. Validation errors become 400s;
+// any other error that is not an *Error is treated as an internal error so
+// its text never reaches the client.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *Error
+	var invalid *validation.Error
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.As(err, &invalid):
+		apiErr = Invalid(http.StatusBadRequest, invalid)
+	default:
+		apiErr = Internal("Internal server error", err)
+	}
+	if apiErr.Status >= http.StatusInternalServerError && apiErr.Err != nil {
+		log.Printf("%s %s: %s: %v", r.Method, r.URL.Path, apiErr.Detail, apiErr.Err)
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:     typeBaseURI + string(apiErr.Code),
+		Title:    http.StatusText(apiErr.Status),
+		Status:   apiErr.Status,
+		Detail:   apiErr.Detail,
+		Instance: r.URL.Path,
+		Code:     apiErr.Code,
+		Errors:   apiErr.Fields,
+	})
+}
@@ -0,0 +1,188 @@
+// Package auth issues and verifies the JWT access tokens used by the HTTP
+// API and the websocket. Every token names the key it was signed with (kid)
+// and carries the issuer, audience, a unique ID (jti) and the user it was
+// issued to; verification rejects anything else.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/revocation"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrRevokedToken = errors.New("token has been revoked")
+)
+
+// Config configures token signing and verification.
+type Config struct {
+	// Keys are all keys tokens may be signed with. New tokens are signed with
+	// the one named by SigningKeyID; the others only verify, so a key can be
+	// rotated out without signing everybody out.
+	Keys         []*Key
+	SigningKeyID string
+	Issuer       string
+	Audience     string
+	// ClockSkew is how far the clocks of this server and the ones verifying
+	// its tokens may drift apart.
+	ClockSkew time.Duration
+}
+
+// Claims are the claims of an access token.
+type Claims struct {
+	UserID    int    `json:"user_id"`
+	SessionID string `json:"sid,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Scopes returns the space-separated scope claim as a list.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+type Manager struct {
+	config      Config
+	keys        map[string]*Key
+	signingKey  *Key
+	revocations *revocation.Store
+	parser      *jwt.Parser
+}
+
+// NewManager returns a Manager for cfg. Tokens revoked in revocations are
+// rejected by Authenticate.
+func NewManager(cfg Config, revocations *revocation.Store) (*Manager, error) {
+	keys := make(map[string]*Key, len(cfg.Keys))
+	var methods []string
+	for _, key := range cfg.Keys {
+		if key.ID == "" {
+			return nil, errors.New("auth: every key needs an ID")
+		}
+		if _, ok := keys[key.ID]; ok {
+			return nil, fmt.Errorf("auth: duplicate key ID %q", key.ID)
+		}
+		keys[key.ID] = key
+		methods = append(methods, key.Algorithm)
+	}
+
+	signingKey, ok := keys[cfg.SigningKeyID]
+	if !ok {
+		return nil, fmt.Errorf("auth: signing key %q is not configured", cfg.SigningKeyID)
+	}
+	if !signingKey.CanSign() {
+		return nil, fmt.Errorf("auth: signing key %q has no private key", cfg.SigningKeyID)
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.Audience),
+		jwt.WithLeeway(cfg.ClockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	return &Manager{
+		config:      cfg,
+		keys:        keys,
+		signingKey:  signingKey,
+		revocations: revocations,
+		parser:      parser,
+	}, nil
+}
+
+// Issue signs an access token for the user, valid for ttl. The returned
+// claims include the generated jti.
+func (m *Manager) Issue(userID int, sessionID string, scopes []string, ttl time.Duration) (string, *Claims, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Scope:     strings.Join(scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    m.config.Issuer,
+			Audience:  jwt.ClaimStrings{m.config.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	token := jwt.NewWithClaims(m.signingKey.method(), claims)
+	token.Header["kid"] = m.signingKey.ID
+	signed, err := token.SignedString(m.signingKey.signing)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+// Verify checks the token's signature, algorithm, issuer, audience and
+// lifetime and returns its claims. Any failure is reported as
+// ErrInvalidToken.
+func (m *Manager) Verify(tokenString string) (*Claims, error) {
+	var claims Claims
+	token, err := m.parser.ParseWithClaims(tokenString, &claims, m.verificationKey)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	if claims.UserID <= 0 || claims.ID == "" || claims.IssuedAt == nil {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// Authenticate verifies the token and checks that it has not been revoked,
+// either on its own or with all of the user's tokens.
+func (m *Manager) Authenticate(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := m.Verify(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := m.revocations.IsAccessTokenRevoked(ctx, claims.UserID, claims.IssuedAt.Time, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRevokedToken
+	}
+	return claims, nil
+}
+
+// verificationKey looks up the key named by the token's kid header. The
+// token must use that key's algorithm, so a public key can never be used as
+// an HMAC secret.
+func (m *Manager) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("key %q does not use %s", kid, token.Method.Alg())
+	}
+	return key.verify, nil
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
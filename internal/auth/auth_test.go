@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"crypto/elliptic"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/redis/redistest"
+	"github.com/cauldnclark/todo-go/internal/revocation"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "todo-go"
+	testAudience = "todo-go-api"
+)
+
+func newTestManager(t *testing.T, signingKeyID string, keys ...*Key) *Manager {
+	t.Helper()
+	manager, err := NewManager(Config{
+		Keys:         keys,
+		SigningKeyID: signingKeyID,
+		Issuer:       testIssuer,
+		Audience:     testAudience,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return manager
+}
+
+func newTestHMACKey(t *testing.T, id, secret string) *Key {
+	t.Helper()
+	key, err := NewHMACKey(id, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	manager := newTestManager(t, "k1", newTestHMACKey(t, "k1", "s3cret"))
+
+	token, issued, err := manager.Issue(42, "session-1", []string{"todos:read", "todos:write"}, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	claims, err := manager.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.UserID != 42 || claims.SessionID != "session-1" || claims.ID != issued.ID || claims.ID == "" {
+		t.Errorf("claims = %+v, want user 42, session-1 and jti %q", claims, issued.ID)
+	}
+	if got := claims.Scopes(); len(got) != 2 || got[0] != "todos:read" || got[1] != "todos:write" {
+		t.Errorf("scopes = %v", got)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "k1" {
+		t.Errorf("kid = %v, want k1", parsed.Header["kid"])
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := newRSAKey(t, 2048)
+	newKey := newECKey(t, elliptic.P256())
+
+	load := func(id, algorithm, path string) *Key {
+		t.Helper()
+		key, err := LoadKey(id, algorithm, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	before := newTestManager(t, "old", load("old", AlgRS256, writePKCS8(t, oldKey)))
+	oldToken, _, err := before.Issue(1, "", nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key is kept as a public key only; new tokens use the new key.
+	after := newTestManager(t, "new",
+		load("old", AlgRS256, writePublic(t, &oldKey.PublicKey)),
+		load("new", AlgES256, writePKCS8(t, newKey)),
+	)
+	if _, err := after.Verify(oldToken); err != nil {
+		t.Errorf("token signed with the retired key: %v", err)
+	}
+
+	newToken, _, err := after.Issue(1, "", nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := after.Verify(newToken); err != nil {
+		t.Errorf("token signed with the new key: %v", err)
+	}
+
+	// Once the old key is dropped its tokens stop working.
+	dropped := newTestManager(t, "new", load("new", AlgES256, writePKCS8(t, newKey)))
+	if _, err := dropped.Verify(oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token signed with a removed key: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	rsaKey := newRSAKey(t, 2048)
+	publicPath := writePublic(t, &rsaKey.PublicKey)
+	rsaPublic, err := LoadKey("rsa", AlgRS256, publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacKey := newTestHMACKey(t, "k1", "s3cret")
+	manager := newTestManager(t, "k1", hmacKey, rsaPublic)
+
+	now := time.Now()
+	valid := func() *Claims {
+		return &Claims{
+			UserID: 1,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "jti-1",
+				Issuer:    testIssuer,
+				Audience:  jwt.ClaimStrings{testAudience},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+		}
+	}
+	sign := func(method jwt.SigningMethod, kid string, key any, claims *Claims) string {
+		t.Helper()
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	with := func(change func(*Claims)) *Claims {
+		claims := valid()
+		change(claims)
+		return claims
+	}
+	pemBytes := func() []byte {
+		t.Helper()
+		key, err := LoadKey("pem", AlgHS256, publicPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key.signing.([]byte)
+	}
+
+	if _, err := manager.Verify(sign(jwt.SigningMethodHS256, "k1", []byte("s3cret"), valid())); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "no kid", token: sign(jwt.SigningMethodHS256, "", []byte("s3cret"), valid())},
+		{name: "unknown kid", token: sign(jwt.SigningMethodHS256, "k2", []byte("s3cret"), valid())},
+		{name: "wrong secret", token: sign(jwt.SigningMethodHS256, "k1", []byte("guess"), valid())},
+		{name: "HS256 signed with the RSA public key", token: sign(jwt.SigningMethodHS256, "rsa", pemBytes(), valid())},
+		{name: "none algorithm", token: sign(jwt.SigningMethodNone, "k1", jwt.UnsafeAllowNoneSignatureType, valid())},
+		{name: "wrong issuer", token: sign(jwt.SigningMethodHS256, "k1", []byte("s3cret"), with(func(c *Claims) { c.Issuer = "someone-else" }))},
+		{name: "wrong audience", token: sign(jwt.SigningMethodHS256, "k1", []byte("s3cret"), with(func(c *Claims) { c.Audience = jwt.ClaimStrings{"other"} }))},
+		{name: "expired", token: sign(jwt.SigningMethodHS256, "k1", []byte("s3cret"), with(func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) }))},
+		{name: "no expiry", token: sign(jwt.SigningMethodHS256, "k1", []byte("s3cret"), with(func(c *Claims) { c.ExpiresAt = nil }))},
+		{name: "no jti", token: sign(jwt.SigningMethodHS256, "k1", []byte("s3cret"), with(func(c *Claims) { c.ID = "" }))},
+		{name: "no user", token: sign(jwt.SigningMethodHS256, "k1", []byte("s3cret"), with(func(c *Claims) { c.UserID = 0 }))},
+		{name: "no issued at", token: sign(jwt.SigningMethodHS256, "k1", []byte("s3cret"), with(func(c *Claims) { c.IssuedAt = nil }))},
+		{name: "garbage", token: "not.a.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := manager.Verify(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestNewManagerRejectsBadConfig(t *testing.T) {
+	rsaKey := newRSAKey(t, 2048)
+	public, err := LoadKey("public", AlgRS256, writePublic(t, &rsaKey.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		keys         []*Key
+		signingKeyID string
+	}{
+		{name: "no keys", signingKeyID: "k1"},
+		{name: "unknown signing key", keys: []*Key{newTestHMACKey(t, "k1", "a")}, signingKeyID: "k2"},
+		{name: "verify-only signing key", keys: []*Key{public}, signingKeyID: "public"},
+		{name: "duplicate key ID", keys: []*Key{newTestHMACKey(t, "k1", "a"), newTestHMACKey(t, "k1", "b")}, signingKeyID: "k1"},
+		{name: "key without ID", keys: []*Key{newTestHMACKey(t, "", "a")}, signingKeyID: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewManager(Config{Keys: tt.keys, SigningKeyID: tt.signingKeyID}, nil); err == nil {
+				t.Errorf("NewManager succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestAuthenticateChecksRevocation(t *testing.T) {
+	client, _ := redistest.NewClient(t)
+	revocations := revocation.NewStore(client)
+	manager, err := NewManager(Config{
+		Keys:         []*Key{newTestHMACKey(t, "k1", "s3cret")},
+		SigningKeyID: "k1",
+		Issuer:       testIssuer,
+		Audience:     testAudience,
+	}, revocations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	token, claims, err := manager.Issue(1, "session-1", nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Authenticate(ctx, token); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	if err := revocations.RevokeToken(ctx, claims.ID, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Authenticate(ctx, token); !errors.Is(err, ErrRevokedToken) {
+		t.Errorf("revoked token: err = %v, want ErrRevokedToken", err)
+	}
+	// Verify only checks the token itself.
+	if _, err := manager.Verify(token); err != nil {
+		t.Errorf("Verify of a revoked token: %v", err)
+	}
+}
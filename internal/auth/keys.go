@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minRSABits is the smallest RSA modulus accepted for RS256 keys.
+const minRSABits = 2048
+
+// Key is one signing key, identified in token headers by its ID (kid). A key
+// loaded from a public key file can only verify tokens, which is how retired
+// keys are kept around until the tokens they signed have expired.
+type Key struct {
+	ID        string
+	Algorithm string
+
+	signing crypto.PrivateKey
+	verify  crypto.PublicKey
+}
+
+// CanSign reports whether the key holds the private part needed to sign.
+func (k *Key) CanSign() bool {
+	return k.signing != nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// NewHMACKey returns an HS256 key for the shared secret.
+func NewHMACKey(id, secret string) (*Key, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("auth: key %q: secret is empty", id)
+	}
+	return &Key{ID: id, Algorithm: AlgHS256, signing: []byte(secret), verify: []byte(secret)}, nil
+}
+
+// LoadKey reads a key for the algorithm from path. HS256 keys are files
+// holding the secret; the others are PEM files with a private key (PKCS#8,
+// or PKCS#1/SEC 1 for RSA/ECDSA) or, for verify-only keys, a public key.
+func LoadKey(id, algorithm, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: key %q: %w", id, err)
+	}
+
+	if algorithm == AlgHS256 {
+		return NewHMACKey(id, strings.TrimSpace(string(data)))
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("auth: key %q: %s is not a PEM file", id, path)
+	}
+
+	key := &Key{ID: id, Algorithm: algorithm}
+	switch block.Type {
+	case "PUBLIC KEY":
+		key.verify, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key.signing, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key.signing, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key.signing, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("auth: key %q: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: key %q: %w", id, err)
+	}
+	if key.signing != nil {
+		signer, ok := key.signing.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("auth: key %q: %s does not hold a signing key", id, path)
+		}
+		key.verify = signer.Public()
+	}
+
+	if err := key.check(); err != nil {
+		return nil, fmt.Errorf("auth: key %q: %w", id, err)
+	}
+	return key, nil
+}
+
+// check makes sure the key material fits the key's algorithm, so a key can
+// never be used with an algorithm it was not meant for.
+func (k *Key) check() error {
+	switch k.Algorithm {
+	case AlgRS256:
+		pub, ok := k.verify.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("RS256 needs an RSA key")
+		}
+		if pub.N.BitLen() < minRSABits {
+			return fmt.Errorf("RSA key must have at least %d bits", minRSABits)
+		}
+	case AlgES256:
+		pub, ok := k.verify.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != elliptic.P256() {
+			return errors.New("ES256 needs an ECDSA P-256 key")
+		}
+	case AlgEdDSA:
+		if _, ok := k.verify.(ed25519.PublicKey); !ok {
+			return errors.New("EdDSA needs an Ed25519 key")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", k.Algorithm)
+	}
+	return nil
+}
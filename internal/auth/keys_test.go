@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writePEM writes a PEM block to a file in the test's temp directory.
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writePKCS8(t *testing.T, key crypto.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "key.pem", "PRIVATE KEY", der)
+}
+
+func writePublic(t *testing.T, key crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "public.pem", "PUBLIC KEY", der)
+}
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newECKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestLoadKey(t *testing.T) {
+	rsaKey := newRSAKey(t, 2048)
+	ecKey := newECKey(t, elliptic.P256())
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey := newEd25519Key(t)
+
+	tests := []struct {
+		name      string
+		algorithm string
+		path      string
+		canSign   bool
+		public    crypto.PublicKey
+	}{
+		{
+			name:      "RSA PKCS#1",
+			algorithm: AlgRS256,
+			path:      writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)),
+			canSign:   true,
+			public:    &rsaKey.PublicKey,
+		},
+		{
+			name:      "RSA PKCS#8",
+			algorithm: AlgRS256,
+			path:      writePKCS8(t, rsaKey),
+			canSign:   true,
+			public:    &rsaKey.PublicKey,
+		},
+		{
+			name:      "RSA public key",
+			algorithm: AlgRS256,
+			path:      writePublic(t, &rsaKey.PublicKey),
+			public:    &rsaKey.PublicKey,
+		},
+		{
+			name:      "EC SEC 1",
+			algorithm: AlgES256,
+			path:      writePEM(t, "ec.pem", "EC PRIVATE KEY", ecDER),
+			canSign:   true,
+			public:    &ecKey.PublicKey,
+		},
+		{
+			name:      "EC public key",
+			algorithm: AlgES256,
+			path:      writePublic(t, &ecKey.PublicKey),
+			public:    &ecKey.PublicKey,
+		},
+		{
+			name:      "Ed25519 PKCS#8",
+			algorithm: AlgEdDSA,
+			path:      writePKCS8(t, edKey),
+			canSign:   true,
+			public:    edKey.Public(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := LoadKey("k1", tt.algorithm, tt.path)
+			if err != nil {
+				t.Fatalf("LoadKey: %v", err)
+			}
+			if key.ID != "k1" || key.Algorithm != tt.algorithm {
+				t.Errorf("key = %s/%s, want k1/%s", key.ID, key.Algorithm, tt.algorithm)
+			}
+			if key.CanSign() != tt.canSign {
+				t.Errorf("CanSign = %v, want %v", key.CanSign(), tt.canSign)
+			}
+			if !reflect.DeepEqual(key.verify, tt.public) {
+				t.Errorf("verification key does not match the public key")
+			}
+		})
+	}
+}
+
+func TestLoadKeyHMAC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("  s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := LoadKey("hmac", AlgHS256, path)
+	if err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	if string(key.signing.([]byte)) != "s3cret" || !key.CanSign() {
+		t.Errorf("secret = %q, want %q", key.signing, "s3cret")
+	}
+}
+
+func TestLoadKeyRejectsMismatchedKeys(t *testing.T) {
+	rsaKey := newRSAKey(t, 2048)
+	emptySecret := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(emptySecret, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	notPEM := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(notPEM, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		algorithm string
+		path      string
+	}{
+		{name: "RSA key for ES256", algorithm: AlgES256, path: writePKCS8(t, rsaKey)},
+		{name: "EC key for RS256", algorithm: AlgRS256, path: writePKCS8(t, newECKey(t, elliptic.P256()))},
+		{name: "P-384 key for ES256", algorithm: AlgES256, path: writePKCS8(t, newECKey(t, elliptic.P384()))},
+		{name: "RSA key for EdDSA", algorithm: AlgEdDSA, path: writePublic(t, &rsaKey.PublicKey)},
+		{name: "short RSA key", algorithm: AlgRS256, path: writePKCS8(t, newRSAKey(t, 1024))},
+		{name: "unsupported algorithm", algorithm: "RS512", path: writePKCS8(t, rsaKey)},
+		{name: "empty HS256 secret", algorithm: AlgHS256, path: emptySecret},
+		{name: "not PEM", algorithm: AlgRS256, path: notPEM},
+		{name: "certificate", algorithm: AlgRS256, path: writePEM(t, "cert.pem", "CERTIFICATE", []byte{1})},
+		{name: "missing file", algorithm: AlgRS256, path: filepath.Join(t.TempDir(), "missing.pem")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadKey("k1", tt.algorithm, tt.path); err == nil {
+				t.Errorf("LoadKey succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey := newRSAKey(t, 2048)
+	ecKey := newECKey(t, elliptic.P256())
+	edKey := newEd25519Key(t)
+
+	load := func(id, algorithm, path string) *Key {
+		t.Helper()
+		key, err := LoadKey(id, algorithm, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	hmac, err := NewHMACKey("a-hmac", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := NewManager(Config{
+		Keys: []*Key{
+			load("c-rsa", AlgRS256, writePKCS8(t, rsaKey)),
+			load("b-ec", AlgES256, writePublic(t, &ecKey.PublicKey)),
+			load("d-ed", AlgEdDSA, writePKCS8(t, edKey)),
+			hmac,
+		},
+		SigningKeyID: "c-rsa",
+		Issuer:       "todo-go",
+		Audience:     "todo-go-api",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := manager.JWKS()
+
+	var ids []string
+	for _, jwk := range set.Keys {
+		ids = append(ids, jwk.KeyID)
+		if jwk.Use != "sig" {
+			t.Errorf("%s: use = %q, want sig", jwk.KeyID, jwk.Use)
+		}
+	}
+	if want := []string{"b-ec", "c-rsa", "d-ed"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("published keys = %v, want %v (sorted, without HS256)", ids, want)
+	}
+
+	ec, rsaJWK, ed := set.Keys[0], set.Keys[1], set.Keys[2]
+
+	if rsaJWK.KeyType != "RSA" || rsaJWK.Algorithm != AlgRS256 {
+		t.Errorf("RSA JWK = %s/%s", rsaJWK.KeyType, rsaJWK.Algorithm)
+	}
+	if n := new(big.Int).SetBytes(decodeSegment(t, rsaJWK.N)); n.Cmp(rsaKey.N) != 0 {
+		t.Errorf("RSA modulus does not round-trip")
+	}
+	if e := new(big.Int).SetBytes(decodeSegment(t, rsaJWK.E)); e.Int64() != int64(rsaKey.E) {
+		t.Errorf("RSA exponent = %d, want %d", e.Int64(), rsaKey.E)
+	}
+
+	if ec.KeyType != "EC" || ec.Curve != "P-256" || ec.Algorithm != AlgES256 {
+		t.Errorf("EC JWK = %s/%s/%s", ec.KeyType, ec.Curve, ec.Algorithm)
+	}
+	x, y := decodeSegment(t, ec.X), decodeSegment(t, ec.Y)
+	if len(x) != 32 || len(y) != 32 {
+		t.Errorf("EC coordinates are %d and %d bytes, want 32", len(x), len(y))
+	}
+	if new(big.Int).SetBytes(x).Cmp(ecKey.X) != 0 || new(big.Int).SetBytes(y).Cmp(ecKey.Y) != 0 {
+		t.Errorf("EC point does not round-trip")
+	}
+
+	if ed.KeyType != "OKP" || ed.Curve != "Ed25519" || ed.Algorithm != AlgEdDSA {
+		t.Errorf("Ed25519 JWK = %s/%s/%s", ed.KeyType, ed.Curve, ed.Algorithm)
+	}
+	if got := decodeSegment(t, ed.X); !reflect.DeepEqual(ed25519.PublicKey(got), edKey.Public()) {
+		t.Errorf("Ed25519 key does not round-trip")
+	}
+}
+
+func decodeSegment(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decoding %q: %v", s, err)
+	}
+	return b
+}
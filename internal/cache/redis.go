@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/cauldnclark/todo-go/internal/redis"
+	goredis "github.com/redis/go-redis/v9"
 )
 
 type RedisCache struct {
@@ -26,9 +27,37 @@ func (r *RedisCache) Set(ctx context.Context, key string, value interface{}, exp
 	return r.client.GetClient().Set(ctx, key, data, expiration).Err()
 }
 
+// SetIfAbsent is Set for keys that act as a lock: it only stores value when
+// the key does not exist yet and reports whether it did.
+func (r *RedisCache) SetIfAbsent(ctx context.Context, key string, value any, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	return r.client.GetClient().SetNX(ctx, key, data, expiration).Result()
+}
+
 func (r *RedisCache) Get(ctx context.Context, key string, value any) error {
 	data, err := r.client.GetClient().Get(ctx, key).Bytes()
 	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return ErrCacheMiss
+		}
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+// GetDelete is Get for values that may only be read once: the key is
+// deleted in the same step, so concurrent callers cannot both see it.
+func (r *RedisCache) GetDelete(ctx context.Context, key string, value any) error {
+	data, err := r.client.GetClient().GetDel(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return ErrCacheMiss
+		}
 		return err
 	}
 
@@ -39,6 +68,19 @@ func (r *RedisCache) Delete(ctx context.Context, key string) error {
 	return r.client.GetClient().Del(ctx, key).Err()
 }
 
+// DeletePattern deletes every key matching the glob pattern. It walks the
+// keyspace with SCAN, so it is meant for rare housekeeping, not hot paths.
+func (r *RedisCache) DeletePattern(ctx context.Context, pattern string) error {
+	client := r.client.GetClient()
+	iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		if err := client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
 func (r *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
 	exists, err := r.client.GetClient().Exists(ctx, key).Result()
 	if err != nil {
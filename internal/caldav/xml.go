@@ -0,0 +1,381 @@
+// Package caldav holds the WebDAV and CalDAV (RFC 4918, 4791, 6578) XML
+// request parsing and multistatus responses used by the CalDAV handler.
+package caldav
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	NamespaceDAV            = "DAV:"
+	NamespaceCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	NamespaceCalendarServer = "http://calendarserver.org/ns/"
+	NamespaceAppleICal      = "http://apple.com/ns/ical/"
+
+	syncTokenPrefix = "https://todo-go/ns/sync/"
+)
+
+var (
+	ErrInvalidBody      = errors.New("malformed XML request body")
+	ErrInvalidSyncToken = errors.New("invalid sync token")
+)
+
+// Well-known element names.
+var (
+	PropResourceType            = xml.Name{Space: NamespaceDAV, Local: "resourcetype"}
+	PropDisplayName             = xml.Name{Space: NamespaceDAV, Local: "displayname"}
+	PropGetETag                 = xml.Name{Space: NamespaceDAV, Local: "getetag"}
+	PropGetContentType          = xml.Name{Space: NamespaceDAV, Local: "getcontenttype"}
+	PropGetLastModified         = xml.Name{Space: NamespaceDAV, Local: "getlastmodified"}
+	PropCurrentUserPrincipal    = xml.Name{Space: NamespaceDAV, Local: "current-user-principal"}
+	PropPrincipalURL            = xml.Name{Space: NamespaceDAV, Local: "principal-URL"}
+	PropOwner                   = xml.Name{Space: NamespaceDAV, Local: "owner"}
+	PropSyncToken               = xml.Name{Space: NamespaceDAV, Local: "sync-token"}
+	PropCurrentUserPrivilegeSet = xml.Name{Space: NamespaceDAV, Local: "current-user-privilege-set"}
+	PropSupportedReportSet      = xml.Name{Space: NamespaceDAV, Local: "supported-report-set"}
+	PropCalendarHomeSet         = xml.Name{Space: NamespaceCalDAV, Local: "calendar-home-set"}
+	PropCalendarUserAddressSet  = xml.Name{Space: NamespaceCalDAV, Local: "calendar-user-address-set"}
+	PropCalendarData            = xml.Name{Space: NamespaceCalDAV, Local: "calendar-data"}
+	PropCalendarDescription     = xml.Name{Space: NamespaceCalDAV, Local: "calendar-description"}
+	PropSupportedComponentSet   = xml.Name{Space: NamespaceCalDAV, Local: "supported-calendar-component-set"}
+	PropGetCTag                 = xml.Name{Space: NamespaceCalendarServer, Local: "getctag"}
+	PropCalendarColor           = xml.Name{Space: NamespaceAppleICal, Local: "calendar-color"}
+
+	ReportCalendarMultiget = xml.Name{Space: NamespaceCalDAV, Local: "calendar-multiget"}
+	ReportCalendarQuery    = xml.Name{Space: NamespaceCalDAV, Local: "calendar-query"}
+	ReportSyncCollection   = xml.Name{Space: NamespaceDAV, Local: "sync-collection"}
+)
+
+var prefixes = map[string]string{
+	NamespaceDAV:            "d",
+	NamespaceCalDAV:         "c",
+	NamespaceCalendarServer: "cs",
+	NamespaceAppleICal:      "ical",
+}
+
+// propNames collects the names of the child elements of a <prop> element.
+type propNames []xml.Name
+
+func (p *propNames) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			*p = append(*p, t.Name)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// PropFind is a PROPFIND request. An empty body asks for all properties.
+type PropFind struct {
+	AllProp  bool
+	PropName bool
+	Props    []xml.Name
+}
+
+func ParsePropFind(r io.Reader) (*PropFind, error) {
+	var body struct {
+		AllProp  *struct{} `xml:"DAV: allprop"`
+		PropName *struct{} `xml:"DAV: propname"`
+		Prop     propNames `xml:"DAV: prop"`
+	}
+	if err := xml.NewDecoder(r).Decode(&body); err != nil {
+		if errors.Is(err, io.EOF) {
+			return &PropFind{AllProp: true}, nil
+		}
+		return nil, ErrInvalidBody
+	}
+
+	return &PropFind{
+		AllProp:  body.AllProp != nil || (body.PropName == nil && len(body.Prop) == 0),
+		PropName: body.PropName != nil,
+		Props:    body.Prop,
+	}, nil
+}
+
+// ParsePropPatch returns the names of the properties a PROPPATCH request sets
+// or removes.
+func ParsePropPatch(r io.Reader) ([]xml.Name, error) {
+	var body struct {
+		Set []struct {
+			Prop propNames `xml:"DAV: prop"`
+		} `xml:"DAV: set"`
+		Remove []struct {
+			Prop propNames `xml:"DAV: prop"`
+		} `xml:"DAV: remove"`
+	}
+	if err := xml.NewDecoder(r).Decode(&body); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	var names []xml.Name
+	for _, set := range body.Set {
+		names = append(names, set.Prop...)
+	}
+	for _, remove := range body.Remove {
+		names = append(names, remove.Prop...)
+	}
+	return names, nil
+}
+
+type compFilter struct {
+	Name        string       `xml:"name,attr"`
+	CompFilters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	PropFilters []struct {
+		Name         string    `xml:"name,attr"`
+		IsNotDefined *struct{} `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	} `xml:"urn:ietf:params:xml:ns:caldav prop-filter"`
+}
+
+// Report is a REPORT request. Only the parts of calendar-query filters that
+// clients use to pick components are understood: the component name and
+// "COMPLETED is not defined" for open todos. Everything else is ignored and
+// the client filters the (larger) result itself.
+type Report struct {
+	Type      xml.Name
+	PropFind  PropFind
+	Hrefs     []string
+	SyncToken string
+	// Component is the component a calendar-query asks for, empty if any.
+	Component string
+	OnlyOpen  bool
+}
+
+func ParseReport(r io.Reader) (*Report, error) {
+	var body struct {
+		XMLName   xml.Name
+		AllProp   *struct{} `xml:"DAV: allprop"`
+		Prop      propNames `xml:"DAV: prop"`
+		Hrefs     []string  `xml:"DAV: href"`
+		SyncToken string    `xml:"DAV: sync-token"`
+		Filter    *struct {
+			CompFilter compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+		} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+	}
+	if err := xml.NewDecoder(r).Decode(&body); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	report := &Report{
+		Type: body.XMLName,
+		PropFind: PropFind{
+			AllProp: body.AllProp != nil || len(body.Prop) == 0,
+			Props:   body.Prop,
+		},
+		Hrefs:     body.Hrefs,
+		SyncToken: strings.TrimSpace(body.SyncToken),
+	}
+	if body.Filter != nil {
+		for _, comp := range body.Filter.CompFilter.CompFilters {
+			report.Component = strings.ToUpper(comp.Name)
+			for _, prop := range comp.PropFilters {
+				if strings.EqualFold(prop.Name, "COMPLETED") && prop.IsNotDefined != nil {
+					report.OnlyOpen = true
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// Property is a property value; Value is already XML and may use the d, c, cs
+// and ical namespace prefixes.
+type Property struct {
+	Name  xml.Name
+	Value string
+}
+
+func Text(name xml.Name, value string) Property {
+	return Property{Name: name, Value: escape(value)}
+}
+
+func Href(name xml.Name, href string) Property {
+	return Property{Name: name, Value: "<d:href>" + escape(href) + "</d:href>"}
+}
+
+func Raw(name xml.Name, value string) Property {
+	return Property{Name: name, Value: value}
+}
+
+// Select picks the requested properties out of the available ones. Names that
+// are not available are returned separately so they can be reported as 404.
+// For allprop, the properties listed in expensive are left out unless asked
+// for explicitly, as RFC 4791 does for calendar-data.
+func Select(available []Property, pf *PropFind, expensive ...xml.Name) ([]Property, []xml.Name) {
+	if pf.AllProp || pf.PropName {
+		found := make([]Property, 0, len(available))
+		for _, prop := range available {
+			if containsName(expensive, prop.Name) {
+				continue
+			}
+			if pf.PropName {
+				prop.Value = ""
+			}
+			found = append(found, prop)
+		}
+		return found, nil
+	}
+
+	var (
+		found   []Property
+		missing []xml.Name
+	)
+	for _, name := range pf.Props {
+		prop, ok := findProperty(available, name)
+		if ok {
+			found = append(found, prop)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	return found, missing
+}
+
+// Wants reports whether the request asks for the property by name.
+func (pf *PropFind) Wants(name xml.Name) bool {
+	return containsName(pf.Props, name)
+}
+
+func findProperty(props []Property, name xml.Name) (Property, bool) {
+	for _, prop := range props {
+		if prop.Name == name {
+			return prop, true
+		}
+	}
+	return Property{}, false
+}
+
+func containsName(names []xml.Name, name xml.Name) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Multistatus builds a 207 Multi-Status response body.
+type Multistatus struct {
+	b strings.Builder
+}
+
+func NewMultistatus() *Multistatus {
+	m := &Multistatus{}
+	m.b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	m.b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/" xmlns:ical="http://apple.com/ns/ical/">`)
+	return m
+}
+
+// AddProps adds a response with the found properties under 200 OK and the
+// missing ones under 404 Not Found.
+func (m *Multistatus) AddProps(href string, found []Property, missing []xml.Name) {
+	m.b.WriteString("<d:response><d:href>" + escape(href) + "</d:href>")
+	if len(found) > 0 || len(missing) == 0 {
+		m.b.WriteString("<d:propstat><d:prop>")
+		for _, prop := range found {
+			m.writeElement(prop.Name, prop.Value)
+		}
+		m.b.WriteString("</d:prop>" + statusLine(http.StatusOK) + "</d:propstat>")
+	}
+	m.addPropStatus(missing, http.StatusNotFound)
+	m.b.WriteString("</d:response>")
+}
+
+// AddPropStatus adds a response giving every named property the same status.
+func (m *Multistatus) AddPropStatus(href string, names []xml.Name, status int) {
+	m.b.WriteString("<d:response><d:href>" + escape(href) + "</d:href>")
+	m.addPropStatus(names, status)
+	m.b.WriteString("</d:response>")
+}
+
+// AddStatus adds a response for a resource as a whole, such as a 404 for a
+// member removed since the last sync.
+func (m *Multistatus) AddStatus(href string, status int) {
+	m.b.WriteString("<d:response><d:href>" + escape(href) + "</d:href>" + statusLine(status) + "</d:response>")
+}
+
+func (m *Multistatus) SetSyncToken(token string) {
+	m.b.WriteString("<d:sync-token>" + escape(token) + "</d:sync-token>")
+}
+
+func (m *Multistatus) WriteTo(w http.ResponseWriter) error {
+	m.b.WriteString("</d:multistatus>")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, err := io.WriteString(w, m.b.String())
+	return err
+}
+
+func (m *Multistatus) addPropStatus(names []xml.Name, status int) {
+	if len(names) == 0 {
+		return
+	}
+	m.b.WriteString("<d:propstat><d:prop>")
+	for _, name := range names {
+		m.writeElement(name, "")
+	}
+	m.b.WriteString("</d:prop>" + statusLine(status) + "</d:propstat>")
+}
+
+func (m *Multistatus) writeElement(name xml.Name, value string) {
+	tag, attrs := name.Local, ""
+	if prefix, ok := prefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		attrs = ` xmlns="` + escape(name.Space) + `"`
+	}
+	if value == "" {
+		m.b.WriteString("<" + tag + attrs + "/>")
+		return
+	}
+	m.b.WriteString("<" + tag + attrs + ">" + value + "</" + tag + ">")
+}
+
+// WriteError writes a DAV:error body naming the failed precondition.
+func WriteError(w http.ResponseWriter, status int, condition xml.Name) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"+`<d:error xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><%s:%s/></d:error>`,
+		prefixes[condition.Space], condition.Local)
+}
+
+// FormatSyncToken and ParseSyncToken convert between change sequence numbers
+// and the URIs RFC 6578 requires sync tokens to be.
+func FormatSyncToken(seq int64) string {
+	return syncTokenPrefix + strconv.FormatInt(seq, 10)
+}
+
+func ParseSyncToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseInt(strings.TrimPrefix(token, syncTokenPrefix), 10, 64)
+	if err != nil || seq < 0 || !strings.HasPrefix(token, syncTokenPrefix) {
+		return 0, ErrInvalidSyncToken
+	}
+	return seq, nil
+}
+
+func statusLine(status int) string {
+	return "<d:status>HTTP/1.1 " + strconv.Itoa(status) + " " + http.StatusText(status) + "</d:status>"
+}
+
+func escape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
@@ -3,15 +3,22 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
-	Redis    RedisConfig
-	Google   GoogleConfig
+	Database  DatabaseConfig
+	Server    ServerConfig
+	Redis     RedisConfig
+	Google    GoogleConfig
+	GitHub    GitHubConfig
+	Microsoft MicrosoftConfig
+	// OIDC are further OpenID Connect providers users can sign in with.
+	OIDC []OIDCProviderConfig
 }
 type DatabaseConfig struct {
 	Host        string
@@ -26,6 +33,32 @@ type ServerConfig struct {
 	Env       string
 	IsProd    bool
 	JWTSecret string
+	// JWTKeys are the access token signing keys, loaded from files. When set
+	// they replace JWTSecret. Tokens are signed with JWTSigningKeyID, the
+	// first key by default; the others stay valid for verification.
+	JWTKeys         []JWTKeyConfig
+	JWTSigningKeyID string
+	// JWTIssuer and JWTAudience are written into every access token and
+	// required when verifying one.
+	JWTIssuer   string
+	JWTAudience string
+	// JWTClockSkew is the leeway allowed when checking token timestamps.
+	JWTClockSkew time.Duration
+	// OAuthStateSecret signs the state of sign-in requests. It defaults to
+	// JWTSecret.
+	OAuthStateSecret string
+	PublicURL        string
+	// DefaultAPIVersion is the response format for clients that do not send
+	// an API-Version header. It stays at 1, the legacy format, so existing
+	// clients keep working; new clients opt in with "API-Version: 2".
+	DefaultAPIVersion int
+}
+
+// JWTKeyConfig names a signing key file and the algorithm it is used with.
+type JWTKeyConfig struct {
+	ID        string
+	Algorithm string
+	Path      string
 }
 type RedisConfig struct {
 	Host     string
@@ -36,6 +69,32 @@ type GoogleConfig struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURL  string
+	// IssuerURL is where Google's OpenID Connect discovery document is
+	// served; the sign-in endpoints and keys are read from it.
+	IssuerURL string
+}
+type GitHubConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+type MicrosoftConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Tenant is the directory users sign in from: a tenant ID, or "common",
+	// "organizations" or "consumers".
+	Tenant string
+}
+
+// OIDCProviderConfig is an OpenID Connect provider users sign in with under
+// Name.
+type OIDCProviderConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
 }
 
 func Load() (*Config, error) {
@@ -53,10 +112,18 @@ func Load() (*Config, error) {
 			DatabaseURL: os.Getenv("DATABASE_URL"),
 		},
 		Server: ServerConfig{
-			Port:      os.Getenv("PORT"),
-			Env:       os.Getenv("ENV"),
-			IsProd:    os.Getenv("ENV") == "production",
-			JWTSecret: os.Getenv("JWT_SECRET"),
+			Port:             os.Getenv("PORT"),
+			Env:              os.Getenv("ENV"),
+			IsProd:           os.Getenv("ENV") == "production",
+			JWTSecret:        os.Getenv("JWT_SECRET"),
+			JWTSigningKeyID:  os.Getenv("JWT_SIGNING_KEY_ID"),
+			OAuthStateSecret: getEnv("OAUTH_STATE_SECRET", os.Getenv("JWT_SECRET")),
+			JWTIssuer:        getEnv("JWT_ISSUER", "todo-go"),
+			JWTAudience:      getEnv("JWT_AUDIENCE", "todo-go-api"),
+			JWTClockSkew:     30 * time.Second,
+			PublicURL:        os.Getenv("PUBLIC_URL"),
+
+			DefaultAPIVersion: 1,
 		},
 		Redis: RedisConfig{
 			Host:     os.Getenv("REDIS_HOST"),
@@ -67,8 +134,106 @@ func Load() (*Config, error) {
 			ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
 			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
 			RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
+			IssuerURL:    getEnv("GOOGLE_ISSUER_URL", "https://accounts.google.com"),
+		},
+		GitHub: GitHubConfig{
+			ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
+			ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("GITHUB_REDIRECT_URL"),
+		},
+		Microsoft: MicrosoftConfig{
+			ClientID:     os.Getenv("MICROSOFT_CLIENT_ID"),
+			ClientSecret: os.Getenv("MICROSOFT_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("MICROSOFT_REDIRECT_URL"),
+			Tenant:       getEnv("MICROSOFT_TENANT", "common"),
 		},
 	}
 
+	if version, err := strconv.Atoi(os.Getenv("DEFAULT_API_VERSION")); err == nil {
+		config.Server.DefaultAPIVersion = version
+	}
+	if value := os.Getenv("JWT_CLOCK_SKEW"); value != "" {
+		skew, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_CLOCK_SKEW: %w", err)
+		}
+		config.Server.JWTClockSkew = skew
+	}
+	if value := os.Getenv("JWT_KEYS"); value != "" {
+		keys, err := parseJWTKeys(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_KEYS: %w", err)
+		}
+		config.Server.JWTKeys = keys
+		if config.Server.JWTSigningKeyID == "" {
+			config.Server.JWTSigningKeyID = keys[0].ID
+		}
+	}
+	if config.Server.JWTSecret == "" && len(config.Server.JWTKeys) == 0 {
+		return nil, fmt.Errorf("JWT_SECRET or JWT_KEYS is required")
+	}
+	if config.Server.OAuthStateSecret == "" {
+		return nil, fmt.Errorf("OAUTH_STATE_SECRET is required when JWT_SECRET is not set")
+	}
+	if value := os.Getenv("OIDC_PROVIDERS"); value != "" {
+		providers, err := loadOIDCProviders(value)
+		if err != nil {
+			return nil, err
+		}
+		config.OIDC = providers
+	}
+
 	return config, nil
 }
+
+// parseJWTKeys parses a comma-separated list of id:algorithm:path entries.
+func parseJWTKeys(value string) ([]JWTKeyConfig, error) {
+	var keys []JWTKeyConfig
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("%q is not id:algorithm:path", entry)
+		}
+		keys = append(keys, JWTKeyConfig{ID: parts[0], Algorithm: parts[1], Path: parts[2]})
+	}
+	return keys, nil
+}
+
+// reservedProviderNames are taken by the built-in providers or by routes
+// under /auth.
+var reservedProviderNames = map[string]bool{
+	"google": true, "github": true, "microsoft": true,
+	"providers": true, "refresh": true, "logout": true,
+}
+
+// loadOIDCProviders reads the providers named in the comma-separated list
+// from OIDC_<NAME>_ISSUER_URL, _CLIENT_ID, _CLIENT_SECRET and _REDIRECT_URL.
+func loadOIDCProviders(names string) ([]OIDCProviderConfig, error) {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    os.Getenv(prefix + "ISSUER_URL"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		}
+		if reservedProviderNames[name] {
+			return nil, fmt.Errorf("OIDC provider name %q is reserved", name)
+		}
+		if name == "" || provider.IssuerURL == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %q needs %sISSUER_URL and %sCLIENT_ID", name, prefix, prefix)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
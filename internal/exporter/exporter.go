@@ -0,0 +1,218 @@
+// Package exporter streams todos as CSV, JSON, Markdown or iCalendar, one
+// todo at a time.
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/ical"
+	"github.com/cauldnclark/todo-go/internal/models"
+)
+
+const (
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatMarkdown = "md"
+	FormatICS      = "ics"
+)
+
+var ErrUnknownFormat = errors.New("format must be one of: csv, json, md, ics")
+
+// Writer receives todos between Begin and End.
+type Writer interface {
+	Begin() error
+	Write(todo *models.Todo) error
+	End() error
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatMarkdown:
+		return &markdownWriter{w: w}, nil
+	case FormatICS:
+		return &icsWriter{w: ical.NewWriter(w), stamp: time.Now()}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// ContentType returns the MIME type and file extension for a format.
+func ContentType(format string) (string, string) {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8", "csv"
+	case FormatJSON:
+		return "application/json", "json"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8", "md"
+	case FormatICS:
+		return "text/calendar; charset=utf-8", "ics"
+	}
+	return "application/octet-stream", "bin"
+}
+
+// csvWriter uses the same columns the CSV importer understands.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Begin() error {
+	return c.w.Write([]string{"id", "title", "description", "completed", "due_date", "priority", "recurrence", "labels", "created_at", "updated_at"})
+}
+
+func (c *csvWriter) Write(todo *models.Todo) error {
+	dueDate := ""
+	if todo.DueDate != nil {
+		dueDate = todo.DueDate.Format(time.RFC3339)
+	}
+	return c.w.Write([]string{
+		strconv.Itoa(todo.ID),
+		todo.Title,
+		todo.Description,
+		strconv.FormatBool(todo.Completed),
+		dueDate,
+		todo.Priority,
+		todo.Recurrence,
+		strings.Join(todo.Labels, ";"),
+		todo.CreatedAt.Format(time.RFC3339),
+		todo.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+func (c *csvWriter) End() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonWriter produces a models.ExportDocument, writing the todos array
+// element by element.
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) Begin() error {
+	var b strings.Builder
+	b.WriteString("{")
+	fields := []struct {
+		name  string
+		value interface{}
+	}{
+		{"format", models.ExportFormat},
+		{"version", models.ExportVersion},
+		{"exported_at", time.Now().UTC()},
+		{"schema", models.ExportSchema},
+	}
+	for _, field := range fields {
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return err
+		}
+		b.WriteString(strconv.Quote(field.name) + ":")
+		b.Write(value)
+		b.WriteString(",")
+	}
+	// The todos array stays open until End.
+	b.WriteString(`"todos":[`)
+	_, err := io.WriteString(j.w, b.String())
+	return err
+}
+
+func (j *jsonWriter) Write(todo *models.Todo) error {
+	data, err := json.Marshal(models.NewExportTodo(todo))
+	if err != nil {
+		return err
+	}
+	if j.count > 0 {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+	j.count++
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonWriter) End() error {
+	_, err := io.WriteString(j.w, "]}\n")
+	return err
+}
+
+type markdownWriter struct {
+	w io.Writer
+}
+
+func (m *markdownWriter) Begin() error {
+	_, err := io.WriteString(m.w, "# Todos\n\n")
+	return err
+}
+
+func (m *markdownWriter) Write(todo *models.Todo) error {
+	var b strings.Builder
+	if todo.Completed {
+		b.WriteString("- [x] ")
+	} else {
+		b.WriteString("- [ ] ")
+	}
+	b.WriteString(strings.ReplaceAll(todo.Title, "\n", " "))
+
+	var meta []string
+	if todo.DueDate != nil {
+		meta = append(meta, "due "+todo.DueDate.Format("2006-01-02 15:04 MST"))
+	}
+	if todo.Priority != "" && todo.Priority != models.PriorityNone {
+		meta = append(meta, "!"+todo.Priority)
+	}
+	if todo.Recurrence != "" {
+		meta = append(meta, "repeats "+todo.Recurrence)
+	}
+	for _, label := range todo.Labels {
+		meta = append(meta, "#"+label)
+	}
+	if len(meta) > 0 {
+		b.WriteString(" (" + strings.Join(meta, ", ") + ")")
+	}
+	b.WriteString("\n")
+
+	if description := strings.TrimSpace(todo.Description); description != "" {
+		for _, line := range strings.Split(description, "\n") {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+
+	_, err := io.WriteString(m.w, b.String())
+	return err
+}
+
+func (m *markdownWriter) End() error {
+	return nil
+}
+
+type icsWriter struct {
+	w     *ical.Writer
+	stamp time.Time
+}
+
+func (i *icsWriter) Begin() error {
+	i.w.BeginCalendar("Todos")
+	return i.w.Err()
+}
+
+func (i *icsWriter) Write(todo *models.Todo) error {
+	i.w.WriteTodo(todo, i.stamp)
+	return i.w.Err()
+}
+
+func (i *icsWriter) End() error {
+	i.w.EndCalendar()
+	return i.w.Err()
+}
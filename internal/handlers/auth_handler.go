@@ -2,67 +2,156 @@ package handlers
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/auth"
 	"github.com/cauldnclark/todo-go/internal/middleware"
 	"github.com/cauldnclark/todo-go/internal/models"
 	"github.com/cauldnclark/todo-go/internal/service"
-	"github.com/go-playground/validator/v10"
+	"github.com/go-chi/chi/v5"
 )
 
 type AuthHandler struct {
-	userService *service.UserService
-	validator   *validator.Validate
+	userService    *service.UserService
+	sessionService *service.SessionService
+	tokens         *auth.Manager
 }
 
-func NewAuthHandler(userService *service.UserService) *AuthHandler {
+func NewAuthHandler(userService *service.UserService, sessionService *service.SessionService, tokens *auth.Manager) *AuthHandler {
 	return &AuthHandler{
-		userService: userService,
-		validator:   validator.New(),
+		userService:    userService,
+		sessionService: sessionService,
+		tokens:         tokens,
 	}
 }
 
-func (h *AuthHandler) GoogleSignIn(w http.ResponseWriter, r *http.Request) {
+// JWKS publishes the public keys access tokens can be verified with, so
+// other services can check them without sharing a secret.
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(h.tokens.JWKS()); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+		return
+	}
+}
+
+// GetProviders lists the providers users can sign in with.
+func (h *AuthHandler) GetProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.userService.GetProviders()); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+		return
+	}
+}
+
+// StartSignIn returns the URL that starts a sign-in with the provider. The
+// client must keep the returned state and check that the provider sends the
+// same one back.
+func (h *AuthHandler) StartSignIn(w http.ResponseWriter, r *http.Request) {
+	start, err := h.userService.StartSignIn(r.Context(), chi.URLParam(r, "provider"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(start); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+		return
+	}
+}
+
+func (h *AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 	var req models.AuthRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	if err := h.validator.Struct(&req); err != nil {
-		http.Error(w, "Validation failed"+err.Error(), http.StatusBadRequest)
+	authResp, err := h.userService.SignIn(r.Context(), chi.URLParam(r, "provider"), req.Code, req.State, sessionClient(r))
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	authResp, err := h.userService.AuthenticateWithGoogle(r.Context(), req.Code)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(authResp); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+		return
+	}
+}
+
+// Refresh trades a refresh token for a new access token and refresh token.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshTokenRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	authResp, err := h.sessionService.Refresh(r.Context(), req.RefreshToken, sessionClient(r))
 	if err != nil {
-		http.Error(w, "Authentication failed"+err.Error(), http.StatusUnauthorized)
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
 	if err := json.NewEncoder(w).Encode(authResp); err != nil {
-		http.Error(w, "Failed to encode response"+err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+		return
+	}
+}
+
+// Logout ends the session the refresh token belongs to.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshTokenRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if err := h.sessionService.Logout(r.Context(), req.RefreshToken); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to log out", err))
 		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *AuthHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	user, err := h.userService.GetUserByID(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "Failed to get user"+err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("Failed to get user", err))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(user); err != nil {
-		http.Error(w, "Failed to encode response"+err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
 		return
 	}
 }
+
+// sessionClient describes the device a request comes from. RemoteAddr has
+// already been replaced with the client address by the RealIP middleware.
+func sessionClient(r *http.Request) models.SessionClient {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return models.SessionClient{
+		UserAgent: r.UserAgent(),
+		IPAddress: ip,
+	}
+}
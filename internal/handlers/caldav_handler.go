@@ -0,0 +1,629 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/caldav"
+	"github.com/cauldnclark/todo-go/internal/ical"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	caldavPrefix       = "/caldav"
+	caldavCalendarName = "todos"
+	maxCalendarObject  = 1 << 20
+)
+
+type caldavContextKey string
+
+const caldavUserContextKey caldavContextKey = "caldavUser"
+
+type davKind int
+
+const (
+	davRoot davKind = iota
+	davPrincipal
+	davHome
+	davCalendar
+	davTodo
+)
+
+// davResource is a resolved CalDAV path. The server exposes one principal
+// and one calendar home per user, holding a single calendar of all todos;
+// todos are not grouped into projects, so there is no calendar per project.
+type davResource struct {
+	kind davKind
+	name string
+}
+
+// CalDAVHandler serves the user's todos to native clients (Apple Reminders,
+// Thunderbird, DAVx5) as VTODOs:
+//
+//	/caldav/                                  service root
+//	/caldav/principals/{userID}/              principal
+//	/caldav/calendars/{userID}/               calendar home
+//	/caldav/calendars/{userID}/todos/         the calendar
+//	/caldav/calendars/{userID}/todos/{name}   one todo
+type CalDAVHandler struct {
+	caldavService *service.CalDAVService
+}
+
+func NewCalDAVHandler(caldavService *service.CalDAVService) *CalDAVHandler {
+	return &CalDAVHandler{
+		caldavService: caldavService,
+	}
+}
+
+// Authenticate accepts HTTP Basic auth with the account email and an app
+// password, since CalDAV clients cannot do the Google sign-in flow.
+func (h *CalDAVHandler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			caldavChallenge(w)
+			return
+		}
+
+		user, err := h.caldavService.Authenticate(r.Context(), username, password)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidCredentials) {
+				caldavChallenge(w)
+				return
+			}
+			log.Printf("CalDAV authentication failed: %v", err)
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), middleware.UserIdContextKey, user.ID)
+		ctx = context.WithValue(ctx, caldavUserContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func caldavChallenge(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="todo-go", charset="UTF-8"`)
+	http.Error(w, "Authentication required", http.StatusUnauthorized)
+}
+
+func (h *CalDAVHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(caldavUserContextKey).(*models.User)
+	if !ok {
+		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, REPORT")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	res, ok := resolveDAVPath(r, user.ID)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "PROPFIND":
+		h.propfind(w, r, user, res)
+	case "PROPPATCH":
+		h.proppatch(w, r, user, res)
+	case "REPORT":
+		h.report(w, r, user, res)
+	case http.MethodGet, http.MethodHead:
+		h.get(w, r, user, res)
+	case http.MethodPut:
+		h.put(w, r, user, res)
+	case http.MethodDelete:
+		h.delete(w, r, user, res)
+	default:
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, REPORT")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// WellKnown points clients doing service discovery (RFC 6764) at the root.
+func (h *CalDAVHandler) WellKnown(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, caldavPrefix+"/", http.StatusMovedPermanently)
+}
+
+func resolveDAVPath(r *http.Request, userID int) (davResource, bool) {
+	path := strings.TrimPrefix(r.URL.EscapedPath(), caldavPrefix)
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return davResource{}, false
+		}
+		segments = append(segments, unescaped)
+	}
+
+	if len(segments) == 0 {
+		return davResource{kind: davRoot}, true
+	}
+	if len(segments) < 2 || segments[1] != strconv.Itoa(userID) {
+		return davResource{}, false
+	}
+
+	switch {
+	case segments[0] == "principals" && len(segments) == 2:
+		return davResource{kind: davPrincipal}, true
+	case segments[0] != "calendars":
+		return davResource{}, false
+	case len(segments) == 2:
+		return davResource{kind: davHome}, true
+	case segments[2] != caldavCalendarName:
+		return davResource{}, false
+	case len(segments) == 3:
+		return davResource{kind: davCalendar}, true
+	case len(segments) == 4:
+		return davResource{kind: davTodo, name: segments[3]}, true
+	}
+	return davResource{}, false
+}
+
+func principalHref(userID int) string {
+	return caldavPrefix + "/principals/" + strconv.Itoa(userID) + "/"
+}
+
+func homeHref(userID int) string {
+	return caldavPrefix + "/calendars/" + strconv.Itoa(userID) + "/"
+}
+
+func calendarHref(userID int) string {
+	return homeHref(userID) + caldavCalendarName + "/"
+}
+
+func todoHref(userID int, name string) string {
+	return calendarHref(userID) + url.PathEscape(name)
+}
+
+func (h *CalDAVHandler) propfind(w http.ResponseWriter, r *http.Request, user *models.User, res davResource) {
+	pf, err := caldav.ParsePropFind(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Depth: infinity is treated as 1; nothing here is nested deeper.
+	depthOne := r.Header.Get("Depth") != "0"
+
+	ms := caldav.NewMultistatus()
+	switch res.kind {
+	case davRoot:
+		found, missing := caldav.Select(principalProps(user, "<d:collection/>"), pf)
+		ms.AddProps(caldavPrefix+"/", found, missing)
+	case davPrincipal:
+		found, missing := caldav.Select(principalProps(user, "<d:principal/>"), pf)
+		ms.AddProps(principalHref(user.ID), found, missing)
+	case davHome:
+		found, missing := caldav.Select(homeProps(user), pf)
+		ms.AddProps(homeHref(user.ID), found, missing)
+		if depthOne {
+			if err := h.addCalendar(r.Context(), ms, user, pf); err != nil {
+				log.Printf("CalDAV PROPFIND failed: %v", err)
+				http.Error(w, "Failed to list calendar", http.StatusInternalServerError)
+				return
+			}
+		}
+	case davCalendar:
+		if err := h.addCalendar(r.Context(), ms, user, pf); err != nil {
+			log.Printf("CalDAV PROPFIND failed: %v", err)
+			http.Error(w, "Failed to list calendar", http.StatusInternalServerError)
+			return
+		}
+		if depthOne {
+			withData := pf.Wants(caldav.PropCalendarData)
+			err := h.caldavService.StreamTodos(r.Context(), user.ID, 0, true, func(todo *models.Todo) error {
+				addTodo(ms, user.ID, todo, pf, withData)
+				return nil
+			})
+			if err != nil {
+				log.Printf("CalDAV PROPFIND failed: %v", err)
+				http.Error(w, "Failed to list todos", http.StatusInternalServerError)
+				return
+			}
+		}
+	case davTodo:
+		todo, err := h.caldavService.GetTodo(r.Context(), user.ID, res.name)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Todo not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to get todo", http.StatusInternalServerError)
+			return
+		}
+		addTodo(ms, user.ID, todo, pf, pf.Wants(caldav.PropCalendarData))
+	}
+
+	ms.WriteTo(w)
+}
+
+// proppatch refuses every change: the calendar's name and color are fixed.
+func (h *CalDAVHandler) proppatch(w http.ResponseWriter, r *http.Request, user *models.User, res davResource) {
+	names, err := caldav.ParsePropPatch(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ms := caldav.NewMultistatus()
+	ms.AddPropStatus(r.URL.EscapedPath(), names, http.StatusForbidden)
+	ms.WriteTo(w)
+}
+
+func (h *CalDAVHandler) report(w http.ResponseWriter, r *http.Request, user *models.User, res davResource) {
+	if res.kind != davCalendar {
+		caldav.WriteError(w, http.StatusForbidden, xml.Name{Space: caldav.NamespaceDAV, Local: "supported-report"})
+		return
+	}
+
+	report, err := caldav.ParseReport(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	withData := report.PropFind.Wants(caldav.PropCalendarData)
+
+	ms := caldav.NewMultistatus()
+	switch report.Type {
+	case caldav.ReportCalendarMultiget:
+		for _, href := range report.Hrefs {
+			name, ok := todoNameFromHref(href, user.ID)
+			if !ok {
+				ms.AddStatus(href, http.StatusNotFound)
+				continue
+			}
+			todo, err := h.caldavService.GetTodo(r.Context(), user.ID, name)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					ms.AddStatus(href, http.StatusNotFound)
+					continue
+				}
+				http.Error(w, "Failed to get todo", http.StatusInternalServerError)
+				return
+			}
+			addTodo(ms, user.ID, todo, &report.PropFind, withData)
+		}
+
+	case caldav.ReportCalendarQuery:
+		if report.Component != "" && report.Component != "VTODO" {
+			break
+		}
+		err := h.caldavService.StreamTodos(r.Context(), user.ID, 0, !report.OnlyOpen, func(todo *models.Todo) error {
+			addTodo(ms, user.ID, todo, &report.PropFind, withData)
+			return nil
+		})
+		if err != nil {
+			log.Printf("CalDAV calendar-query failed: %v", err)
+			http.Error(w, "Failed to query todos", http.StatusInternalServerError)
+			return
+		}
+
+	case caldav.ReportSyncCollection:
+		since, err := caldav.ParseSyncToken(report.SyncToken)
+		if err != nil {
+			caldav.WriteError(w, http.StatusForbidden, xml.Name{Space: caldav.NamespaceDAV, Local: "valid-sync-token"})
+			return
+		}
+		// Read the new token first: anything written while the changes
+		// are collected is sent again on the next sync rather than lost.
+		current, err := h.caldavService.ChangeSeq(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "Failed to sync todos", http.StatusInternalServerError)
+			return
+		}
+		err = h.caldavService.StreamTodos(r.Context(), user.ID, since, true, func(todo *models.Todo) error {
+			addTodo(ms, user.ID, todo, &report.PropFind, withData)
+			return nil
+		})
+		if err != nil {
+			log.Printf("CalDAV sync-collection failed: %v", err)
+			http.Error(w, "Failed to sync todos", http.StatusInternalServerError)
+			return
+		}
+		// An initial sync only lists what exists.
+		if since > 0 {
+			tombstones, err := h.caldavService.GetTombstones(r.Context(), user.ID, since)
+			if err != nil {
+				http.Error(w, "Failed to sync todos", http.StatusInternalServerError)
+				return
+			}
+			for _, tombstone := range tombstones {
+				name := tombstone.CalDAVResource
+				if name == "" {
+					name = tombstone.ICalUID + ".ics"
+				}
+				ms.AddStatus(todoHref(user.ID, name), http.StatusNotFound)
+			}
+		}
+		ms.SetSyncToken(caldav.FormatSyncToken(current))
+
+	default:
+		caldav.WriteError(w, http.StatusForbidden, xml.Name{Space: caldav.NamespaceDAV, Local: "supported-report"})
+		return
+	}
+
+	ms.WriteTo(w)
+}
+
+func (h *CalDAVHandler) get(w http.ResponseWriter, r *http.Request, user *models.User, res davResource) {
+	if res.kind != davTodo {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	todo, err := h.caldavService.GetTodo(r.Context(), user.ID, res.name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Todo not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get todo", http.StatusInternalServerError)
+		return
+	}
+
+	etag := service.TodoETag(todo)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", todo.UpdatedAt.UTC().Format(http.TimeFormat))
+	if service.NoneMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data := renderCalendarObject(todo)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.WriteString(w, data)
+}
+
+func (h *CalDAVHandler) put(w http.ResponseWriter, r *http.Request, user *models.User, res davResource) {
+	if res.kind != davTodo {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCalendarObject))
+	if err != nil {
+		http.Error(w, "Calendar object too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	_, created, err := h.caldavService.PutTodo(r.Context(), user.ID, res.name, data, r.Header.Get("If-Match"), r.Header.Get("If-None-Match"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPreconditionFailed):
+			http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+		case errors.Is(err, service.ErrInvalidCalendarData):
+			caldav.WriteError(w, http.StatusForbidden, xml.Name{Space: caldav.NamespaceCalDAV, Local: "valid-calendar-data"})
+		case errors.Is(err, service.ErrEmptyTitle):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrUIDMismatch), errors.Is(err, repository.ErrICalUIDConflict):
+			caldav.WriteError(w, http.StatusConflict, xml.Name{Space: caldav.NamespaceCalDAV, Local: "no-uid-conflict"})
+		case errors.Is(err, repository.ErrStaleVersion):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("CalDAV PUT failed: %v", err)
+			http.Error(w, "Failed to save todo", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// No ETag: the stored todo is not byte-for-byte what the client sent,
+	// so RFC 4791 wants the client to fetch it again.
+	if created {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CalDAVHandler) delete(w http.ResponseWriter, r *http.Request, user *models.User, res davResource) {
+	if res.kind != davTodo {
+		http.Error(w, "Calendar cannot be deleted", http.StatusForbidden)
+		return
+	}
+
+	if err := h.caldavService.DeleteTodo(r.Context(), user.ID, res.name, r.Header.Get("If-Match")); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "Todo not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrPreconditionFailed), errors.Is(err, repository.ErrStaleVersion):
+			http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+		default:
+			http.Error(w, "Failed to delete todo", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CalDAVHandler) addCalendar(ctx context.Context, ms *caldav.Multistatus, user *models.User, pf *caldav.PropFind) error {
+	seq, err := h.caldavService.ChangeSeq(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	found, missing := caldav.Select(calendarProps(user, seq), pf)
+	ms.AddProps(calendarHref(user.ID), found, missing)
+	return nil
+}
+
+func addTodo(ms *caldav.Multistatus, userID int, todo *models.Todo, pf *caldav.PropFind, withData bool) {
+	props := []caldav.Property{
+		caldav.Raw(caldav.PropResourceType, ""),
+		caldav.Text(caldav.PropGetETag, service.TodoETag(todo)),
+		caldav.Text(caldav.PropGetContentType, "text/calendar; charset=utf-8; component=VTODO"),
+		caldav.Text(caldav.PropGetLastModified, todo.UpdatedAt.UTC().Format(http.TimeFormat)),
+	}
+	if withData {
+		props = append(props, caldav.Text(caldav.PropCalendarData, renderCalendarObject(todo)))
+	}
+
+	found, missing := caldav.Select(props, pf, caldav.PropCalendarData)
+	ms.AddProps(todoHref(userID, service.TodoResourceName(todo)), found, missing)
+}
+
+func principalProps(user *models.User, resourceType string) []caldav.Property {
+	return []caldav.Property{
+		caldav.Raw(caldav.PropResourceType, resourceType),
+		caldav.Text(caldav.PropDisplayName, user.Name),
+		caldav.Href(caldav.PropCurrentUserPrincipal, principalHref(user.ID)),
+		caldav.Href(caldav.PropPrincipalURL, principalHref(user.ID)),
+		caldav.Href(caldav.PropCalendarHomeSet, homeHref(user.ID)),
+		caldav.Href(caldav.PropCalendarUserAddressSet, "mailto:"+user.Email),
+	}
+}
+
+func homeProps(user *models.User) []caldav.Property {
+	return []caldav.Property{
+		caldav.Raw(caldav.PropResourceType, "<d:collection/>"),
+		caldav.Text(caldav.PropDisplayName, user.Name),
+		caldav.Href(caldav.PropCurrentUserPrincipal, principalHref(user.ID)),
+		caldav.Href(caldav.PropOwner, principalHref(user.ID)),
+	}
+}
+
+func calendarProps(user *models.User, seq int64) []caldav.Property {
+	return []caldav.Property{
+		caldav.Raw(caldav.PropResourceType, "<d:collection/><c:calendar/>"),
+		caldav.Text(caldav.PropDisplayName, "Todos"),
+		caldav.Text(caldav.PropCalendarDescription, "All todos"),
+		caldav.Raw(caldav.PropSupportedComponentSet, `<c:comp name="VTODO"/>`),
+		caldav.Text(caldav.PropGetCTag, strconv.FormatInt(seq, 10)),
+		caldav.Text(caldav.PropSyncToken, caldav.FormatSyncToken(seq)),
+		caldav.Href(caldav.PropCurrentUserPrincipal, principalHref(user.ID)),
+		caldav.Href(caldav.PropOwner, principalHref(user.ID)),
+		caldav.Raw(caldav.PropCurrentUserPrivilegeSet,
+			"<d:privilege><d:read/></d:privilege>"+
+				"<d:privilege><d:write/></d:privilege>"+
+				"<d:privilege><d:write-content/></d:privilege>"+
+				"<d:privilege><d:bind/></d:privilege>"+
+				"<d:privilege><d:unbind/></d:privilege>"+
+				"<d:privilege><d:read-current-user-privilege-set/></d:privilege>"),
+		caldav.Raw(caldav.PropSupportedReportSet,
+			"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>"+
+				"<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>"+
+				"<d:supported-report><d:report><d:sync-collection/></d:report></d:supported-report>"),
+	}
+}
+
+// todoNameFromHref extracts the todo file name from an href, which clients
+// may send as a path or a full URL.
+func todoNameFromHref(href string, userID int) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	name, ok := strings.CutPrefix(u.EscapedPath(), calendarHref(userID))
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	name, err = url.PathUnescape(name)
+	return name, err == nil
+}
+
+// renderCalendarObject stamps the object with the todo's last change so the
+// body only changes when the ETag does.
+func renderCalendarObject(todo *models.Todo) string {
+	var b strings.Builder
+	writer := ical.NewWriter(&b)
+	writer.BeginCalendar("")
+	writer.WriteTodo(todo, todo.UpdatedAt)
+	writer.EndCalendar()
+	return b.String()
+}
+
+func (h *CalDAVHandler) GetAppPasswords(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	passwords, err := h.caldavService.GetAppPasswords(r.Context(), userID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to get app passwords", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(passwords); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *CalDAVHandler) CreateAppPassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	var req models.CreateAppPasswordRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	password, err := h.caldavService.CreateAppPassword(r.Context(), userID, &req)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to create app password", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(password); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *CalDAVHandler) RevokeAppPassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	passwordID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid app password ID"))
+		return
+	}
+
+	if err := h.caldavService.RevokeAppPassword(r.Context(), userID, passwordID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("App password not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to revoke app password", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
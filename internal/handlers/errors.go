@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/exporter"
+	"github.com/cauldnclark/todo-go/internal/importer"
+	"github.com/cauldnclark/todo-go/internal/patch"
+	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/service"
+)
+
+// domainErrors are the errors from lower layers whose messages are written
+// for clients, with the status and code they are reported as.
+var domainErrors = []struct {
+	err    error
+	status int
+	code   apierror.Code
+}{
+	{service.ErrInvalidPriority, http.StatusBadRequest, "invalid_priority"},
+	{service.ErrInvalidTimezone, http.StatusBadRequest, "invalid_timezone"},
+	{service.ErrEmptyTitle, http.StatusBadRequest, "title_required"},
+	{service.ErrInvalidSort, http.StatusBadRequest, "invalid_sort"},
+	{service.ErrUnsupportedPatch, http.StatusUnsupportedMediaType, "unsupported_patch_type"},
+	{service.ErrInvalidPatchResult, http.StatusUnprocessableEntity, "invalid_patch_result"},
+	{service.ErrInvalidTimeRange, http.StatusBadRequest, "invalid_time_range"},
+	{service.ErrUnsupportedGroupBy, http.StatusBadRequest, "invalid_group_by"},
+	{service.ErrInvalidReportDate, http.StatusBadRequest, "invalid_report_date"},
+	{service.ErrInvalidReportRange, http.StatusBadRequest, "invalid_report_range"},
+	{service.ErrInvalidImportFile, http.StatusBadRequest, "invalid_import_file"},
+	{service.ErrImportInProgress, http.StatusConflict, "import_in_progress"},
+	{service.ErrInvalidTemplate, http.StatusBadRequest, "invalid_template"},
+	{service.ErrInvalidDueTime, http.StatusBadRequest, "invalid_due_time"},
+	{service.ErrInvalidAnchorDate, http.StatusBadRequest, "invalid_anchor_date"},
+	{service.ErrInvalidFeed, http.StatusBadRequest, "invalid_feed_component"},
+	{service.ErrInvalidSyncToken, http.StatusBadRequest, "invalid_sync_token"},
+	{service.ErrInvalidOnConflict, http.StatusBadRequest, "invalid_on_conflict"},
+	{service.ErrTooManyMutations, http.StatusBadRequest, "too_many_mutations"},
+	{service.ErrInvalidRefreshToken, http.StatusUnauthorized, "invalid_refresh_token"},
+	{service.ErrScopeNotGranted, http.StatusForbidden, "scope_not_granted"},
+	{service.ErrInvalidOAuthState, http.StatusBadRequest, "invalid_oauth_state"},
+	{service.ErrEmailNotVerified, http.StatusForbidden, "email_not_verified"},
+	{service.ErrExternalSignInFailed, http.StatusUnauthorized, "external_sign_in_failed"},
+	{service.ErrUnknownProvider, http.StatusNotFound, "unknown_provider"},
+	{service.ErrExportInProgress, http.StatusConflict, "export_in_progress"},
+	{service.ErrExportNotReady, http.StatusConflict, "export_not_ready"},
+	{service.ErrPreconditionFailed, http.StatusPreconditionFailed, apierror.CodePreconditionFailed},
+	{exporter.ErrUnknownFormat, http.StatusBadRequest, "unknown_export_format"},
+	{importer.ErrUnknownFormat, http.StatusBadRequest, "unknown_import_format"},
+	{importer.ErrMalformedFile, http.StatusBadRequest, "malformed_import_file"},
+	{patch.ErrInvalidPatch, http.StatusBadRequest, "invalid_patch"},
+	{patch.ErrCannotApply, http.StatusUnprocessableEntity, "patch_not_applicable"},
+	{patch.ErrTestFailed, http.StatusConflict, "patch_test_failed"},
+	{repository.ErrStaleVersion, http.StatusConflict, "stale_version"},
+	{repository.ErrTimerAlreadyRunning, http.StatusConflict, "timer_already_running"},
+	{repository.ErrICalUIDConflict, http.StatusConflict, "ical_uid_conflict"},
+	{repository.ErrClientIDConflict, http.StatusConflict, "client_id_conflict"},
+	{repository.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused"},
+	{repository.ErrIdentityInUse, http.StatusConflict, "identity_in_use"},
+	{repository.ErrLastIdentity, http.StatusConflict, "last_identity"},
+}
+
+// writeError reports a domain error with its own status and code. Anything
+// else is an internal error and its message is not shown to the client.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	for _, d := range domainErrors {
+		if errors.Is(err, d.err) {
+			apierror.Write(w, r, apierror.New(d.status, d.code, err.Error()))
+			return
+		}
+	}
+	apierror.Write(w, r, err)
+}
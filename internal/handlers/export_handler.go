@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/exporter"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/service"
+	"github.com/cauldnclark/todo-go/internal/validation"
+)
+
+// ExportTimeout bounds an export request. It replaces both the server's write
+// timeout and the usual request timeout, since streaming a large account can
+// take a while.
+const ExportTimeout = 5 * time.Minute
+
+type ExportHandler struct {
+	todoService *service.TodoService
+}
+
+func NewExportHandler(todoService *service.TodoService) *ExportHandler {
+	return &ExportHandler{
+		todoService: todoService,
+	}
+}
+
+// Export streams the user's todos. Query parameters: format (csv, json, md or
+// ics, default json), include_completed, label, due_from and due_to (dates or
+// RFC 3339 timestamps).
+func (h *ExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = exporter.FormatJSON
+	}
+	if _, err := exporter.NewWriter(format, nil); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	contentType, extension := exporter.ContentType(format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="todos-`+time.Now().UTC().Format("20060102")+`.`+extension+`"`)
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(ExportTimeout)); err != nil {
+		log.Printf("Failed to extend export write deadline: %v", err)
+	}
+
+	// Headers are already sent once streaming starts, so a failure part way
+	// through can only be logged.
+	if err := h.todoService.ExportTodos(r.Context(), userID, format, filter, w); err != nil {
+		log.Printf("Export for user %d failed: %v", userID, err)
+	}
+}
+
+func parseTodoFilter(r *http.Request) (models.TodoFilter, error) {
+	query := r.URL.Query()
+	filter := models.TodoFilter{
+		Label: query.Get("label"),
+	}
+
+	if value := query.Get("include_completed"); value != "" {
+		include, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, invalidQueryParam("include_completed", "boolean", "must be true or false")
+		}
+		filter.IncludeCompleted = include
+	}
+
+	var err error
+	if filter.DueFrom, err = parseFilterTime(query.Get("due_from")); err != nil {
+		return filter, invalidQueryParam("due_from", "datetime", "must be a date or RFC 3339 timestamp")
+	}
+	if filter.DueTo, err = parseFilterTime(query.Get("due_to")); err != nil {
+		return filter, invalidQueryParam("due_to", "datetime", "must be a date or RFC 3339 timestamp")
+	}
+
+	return filter, nil
+}
+
+// invalidQueryParam reports a malformed query parameter as a validation
+// error naming it.
+func invalidQueryParam(name, rule, message string) error {
+	return &validation.Error{Fields: []validation.FieldError{{
+		Field:   name,
+		Rule:    rule,
+		Message: message,
+	}}}
+}
+
+func parseFilterTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type FeedHandler struct {
+	feedService *service.FeedService
+}
+
+func NewFeedHandler(feedService *service.FeedService) *FeedHandler {
+	return &FeedHandler{
+		feedService: feedService,
+	}
+}
+
+// ServeFeed is mounted outside the authenticated API: calendar clients cannot
+// send an Authorization header, so the secret token in the URL is the
+// credential.
+func (h *FeedHandler) ServeFeed(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(chi.URLParam(r, "token"), ".ics")
+	if token == "" {
+		apierror.Write(w, r, apierror.NotFound("Feed not found"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+
+	if err := h.feedService.WriteFeed(r.Context(), token, w); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Feed not found"))
+			return
+		}
+		log.Printf("Failed to serve calendar feed: %v", err)
+		apierror.Write(w, r, apierror.Internal("Failed to render feed", err))
+	}
+}
+
+func (h *FeedHandler) GetFeeds(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	feeds, err := h.feedService.GetFeeds(r.Context(), userID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to get feeds", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(feeds); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *FeedHandler) CreateFeed(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	var req models.CreateFeedRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	feed, err := h.feedService.CreateFeed(r.Context(), userID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidFeed) {
+			writeError(w, r, err)
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to create feed", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(feed); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *FeedHandler) RotateFeedToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	feedID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid feed ID"))
+		return
+	}
+
+	feed, err := h.feedService.RotateFeedToken(r.Context(), userID, feedID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Feed not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to rotate feed token", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(feed); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *FeedHandler) RevokeFeed(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	feedID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid feed ID"))
+		return
+	}
+
+	if err := h.feedService.RevokeFeed(r.Context(), userID, feedID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Feed not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to revoke feed", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// IdentityHandler lists, links and unlinks the provider accounts the user
+// can sign in with.
+type IdentityHandler struct {
+	identityService *service.IdentityService
+}
+
+func NewIdentityHandler(identityService *service.IdentityService) *IdentityHandler {
+	return &IdentityHandler{
+		identityService: identityService,
+	}
+}
+
+func (h *IdentityHandler) GetIdentities(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	identities, err := h.identityService.GetIdentities(r.Context(), userID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to get identities", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(identities); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+// StartLink returns the URL where the user signs in to the account to link.
+// The provider sends the user back with a code and state for LinkIdentity.
+func (h *IdentityHandler) StartLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	var req models.StartLinkIdentityRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	start, err := h.identityService.StartLink(r.Context(), userID, req.Provider)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(start); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *IdentityHandler) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	var req models.LinkIdentityRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	identity, err := h.identityService.Link(r.Context(), userID, &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(identity); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *IdentityHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	identityID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid identity ID"))
+		return
+	}
+
+	if err := h.identityService.Unlink(r.Context(), userID, identityID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Identity not found"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
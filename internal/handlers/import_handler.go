@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// MaxImportSize is the largest import file accepted. It is also the largest
+// request body of any route.
+const MaxImportSize = 10 << 20 // 10 MB
+
+type ImportHandler struct {
+	importService *service.ImportService
+}
+
+func NewImportHandler(importService *service.ImportService) *ImportHandler {
+	return &ImportHandler{
+		importService: importService,
+	}
+}
+
+// Import accepts the file either as the raw request body or as the "file"
+// field of a multipart form. The format comes from the format query parameter
+// and is detected from the content when omitted.
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize)
+
+	contentType := r.Header.Get("Content-Type")
+	var body io.Reader = r.Body
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("Missing file field"))
+			return
+		}
+		defer file.Close()
+		body = file
+		contentType = header.Header.Get("Content-Type")
+		if strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
+			contentType = "text/csv"
+		}
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			apierror.Write(w, r, apierror.PayloadTooLarge("Import file is too large"))
+			return
+		}
+		apierror.Write(w, r, apierror.BadRequest("Failed to read import file"))
+		return
+	}
+
+	job, err := h.importService.StartImport(r.Context(), userID, r.URL.Query().Get("format"), contentType, data)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidImportFile) || errors.Is(err, service.ErrImportInProgress) {
+			writeError(w, r, err)
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to start import", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/import/"+job.ID)
+	if job.Status == models.ImportStatusCompleted {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *ImportHandler) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	job, err := h.importService.GetImportJob(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Import not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to get import", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// PersonalAccessTokenHandler manages the user's personal access tokens under
+// /api/me/tokens.
+type PersonalAccessTokenHandler struct {
+	tokenService *service.PersonalAccessTokenService
+}
+
+func NewPersonalAccessTokenHandler(tokenService *service.PersonalAccessTokenService) *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{
+		tokenService: tokenService,
+	}
+}
+
+func (h *PersonalAccessTokenHandler) GetTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	tokens, err := h.tokenService.GetTokens(r.Context(), userID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to get tokens", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+// CreateToken returns the new token with its value, which is not shown again.
+func (h *PersonalAccessTokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	var req models.CreatePersonalAccessTokenRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	token, err := h.tokenService.CreateToken(r.Context(), userID, middleware.GetScopesFromContext(r.Context()), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrScopeNotGranted) {
+			writeError(w, r, err)
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to create token", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(token); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *PersonalAccessTokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	tokenID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid token ID"))
+		return
+	}
+
+	if err := h.tokenService.RevokeToken(r.Context(), userID, tokenID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Token not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to revoke token", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/validation"
+)
+
+const maxBodySize = 1 << 20
+
+// decodeJSON reads a JSON request body into dst, rejecting bodies over
+// maxBodySize, unknown fields and trailing data, and then normalizes and
+// validates dst. Errors are ready to pass to apierror.Write.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	return decodeBody(w, r, dst, false)
+}
+
+// decodeOptionalJSON is decodeJSON for endpoints where the body may be left
+// out; dst is still validated so its defaults apply.
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	return decodeBody(w, r, dst, true)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any, optional bool) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if errors.Is(err, io.EOF) && optional {
+		err = nil
+	} else if err == nil && decoder.More() {
+		err = errors.New("trailing data")
+	}
+	if err != nil {
+		return decodeError(err)
+	}
+
+	return validation.Struct(dst)
+}
+
+func decodeError(err error) error {
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		return apierror.PayloadTooLarge("Request body too large")
+	case errors.Is(err, io.EOF):
+		return apierror.BadRequest("Request body is required")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return apierror.Invalid(http.StatusBadRequest, &validation.Error{Fields: []validation.FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: "must be " + typeErr.Type.Kind().String(),
+		}}})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return apierror.Invalid(http.StatusBadRequest, &validation.Error{Fields: []validation.FieldError{{
+			Field:   field,
+			Rule:    "unknown",
+			Message: "is not a known field",
+		}}})
+	}
+	return apierror.BadRequest("Invalid request payload")
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+)
+
+// versionedBody picks the response body for the client's API version: data
+// and meta wrapped in an envelope, or the legacy shape for version 1 clients.
+func versionedBody(r *http.Request, data, meta, legacy any) any {
+	if middleware.GetAPIVersionFromContext(r.Context()) == middleware.APIVersionLegacy {
+		return legacy
+	}
+	return models.Envelope{Data: data, Meta: meta}
+}
+
+func writeVersioned(w http.ResponseWriter, r *http.Request, status int, data, meta, legacy any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(versionedBody(r, data, meta, legacy)); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
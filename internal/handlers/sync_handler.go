@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/service"
+)
+
+type SyncHandler struct {
+	syncService *service.SyncService
+}
+
+func NewSyncHandler(syncService *service.SyncService) *SyncHandler {
+	return &SyncHandler{
+		syncService: syncService,
+	}
+}
+
+func (h *SyncHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			apierror.Write(w, r, apierror.BadRequest("Invalid limit"))
+			return
+		}
+	}
+
+	changes, err := h.syncService.GetChanges(r.Context(), userID, r.URL.Query().Get("since"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSyncToken) {
+			writeError(w, r, err)
+			return
+		}
+		log.Printf("Failed to get sync changes: %v", err)
+		apierror.Write(w, r, apierror.Internal("Failed to get changes", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(changes); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *SyncHandler) PushChanges(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	var req models.SyncPushRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	resp, err := h.syncService.ApplyMutations(r.Context(), userID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOnConflict) || errors.Is(err, service.ErrTooManyMutations) {
+			writeError(w, r, err)
+			return
+		}
+		log.Printf("Failed to apply sync mutations: %v", err)
+		apierror.Write(w, r, apierror.Internal("Failed to apply changes", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
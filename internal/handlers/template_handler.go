@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type TemplateHandler struct {
+	templateService *service.TemplateService
+}
+
+func NewTemplateHandler(templateService *service.TemplateService) *TemplateHandler {
+	return &TemplateHandler{
+		templateService: templateService,
+	}
+}
+
+func (h *TemplateHandler) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	templates, err := h.templateService.GetTemplates(r.Context(), userID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to get templates", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(templates); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *TemplateHandler) GetTemplateByID(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	templateID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid template ID"))
+		return
+	}
+
+	template, err := h.templateService.GetTemplateByID(r.Context(), userID, templateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Template not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to get template", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *TemplateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	var req models.CreateTemplateRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	template, err := h.templateService.CreateTemplate(r.Context(), userID, &req)
+	if err != nil {
+		if isTemplateInputError(err) {
+			writeError(w, r, err)
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to create template", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *TemplateHandler) SaveTodoAsTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	todoID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid todo ID"))
+		return
+	}
+
+	var req models.SaveTodoAsTemplateRequest
+	if errDecode := decodeOptionalJSON(w, r, &req); errDecode != nil {
+		apierror.Write(w, r, errDecode)
+		return
+	}
+
+	template, err := h.templateService.SaveTodoAsTemplate(r.Context(), userID, todoID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			apierror.Write(w, r, apierror.NotFound("Todo not found"))
+		case isTemplateInputError(err):
+			writeError(w, r, err)
+		default:
+			apierror.Write(w, r, apierror.Internal("Failed to save template", err))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(template); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *TemplateHandler) InstantiateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	templateID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid template ID"))
+		return
+	}
+
+	var req models.InstantiateTemplateRequest
+	if errDecode := decodeOptionalJSON(w, r, &req); errDecode != nil {
+		apierror.Write(w, r, errDecode)
+		return
+	}
+
+	todos, err := h.templateService.Instantiate(r.Context(), userID, templateID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			apierror.Write(w, r, apierror.NotFound("Template not found"))
+		case isTemplateInputError(err):
+			writeError(w, r, err)
+		default:
+			apierror.Write(w, r, apierror.Internal("Failed to instantiate template", err))
+		}
+		return
+	}
+
+	writeVersioned(w, r, http.StatusCreated, todos, nil, todos)
+}
+
+func (h *TemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	templateID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid template ID"))
+		return
+	}
+
+	if err := h.templateService.DeleteTemplate(r.Context(), userID, templateID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Template not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to delete template", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func isTemplateInputError(err error) bool {
+	return errors.Is(err, service.ErrInvalidTemplate) ||
+		errors.Is(err, service.ErrInvalidPriority) ||
+		errors.Is(err, service.ErrInvalidDueTime) ||
+		errors.Is(err, service.ErrInvalidAnchorDate) ||
+		errors.Is(err, service.ErrInvalidTimezone)
+}
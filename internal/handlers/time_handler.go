@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type TimeHandler struct {
+	timeService *service.TimeService
+}
+
+func NewTimeHandler(timeService *service.TimeService) *TimeHandler {
+	return &TimeHandler{
+		timeService: timeService,
+	}
+}
+
+func (h *TimeHandler) StartTimer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	todoID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid todo ID"))
+		return
+	}
+
+	var req models.StartTimerRequest
+	if errDecode := decodeOptionalJSON(w, r, &req); errDecode != nil {
+		apierror.Write(w, r, errDecode)
+		return
+	}
+
+	state, err := h.timeService.StartTimer(r.Context(), userID, todoID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			apierror.Write(w, r, apierror.NotFound("Todo not found"))
+		case errors.Is(err, repository.ErrTimerAlreadyRunning):
+			apierror.Write(w, r, apierror.Conflict("A timer is already running"))
+		default:
+			apierror.Write(w, r, apierror.Internal("Failed to start timer", err))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *TimeHandler) StopTimer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	state, err := h.timeService.StopTimer(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("No timer is running"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to stop timer", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *TimeHandler) GetTimer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	state, err := h.timeService.GetTimerState(r.Context(), userID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.Internal("Failed to get timer", err))
+			return
+		}
+		state = &models.TimerState{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *TimeHandler) CreateTimeEntry(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	todoID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid todo ID"))
+		return
+	}
+
+	var req models.CreateTimeEntryRequest
+	if errDecode := decodeJSON(w, r, &req); errDecode != nil {
+		apierror.Write(w, r, errDecode)
+		return
+	}
+
+	entry, err := h.timeService.CreateTimeEntry(r.Context(), userID, todoID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTimeRange):
+			writeError(w, r, err)
+		case errors.Is(err, sql.ErrNoRows):
+			apierror.Write(w, r, apierror.NotFound("Todo not found"))
+		default:
+			apierror.Write(w, r, apierror.Internal("Failed to create time entry", err))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *TimeHandler) GetTimeEntries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	todoID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid todo ID"))
+		return
+	}
+
+	entries, err := h.timeService.GetTimeEntries(r.Context(), userID, todoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Todo not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to get time entries", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
+
+func (h *TimeHandler) DeleteTimeEntry(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	entryID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid time entry ID"))
+		return
+	}
+
+	if err := h.timeService.DeleteTimeEntry(r.Context(), userID, entryID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.NotFound("Time entry not found"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to delete time entry", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TimeHandler) GetTimeReport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	query := r.URL.Query()
+	report, err := h.timeService.GetTimeReport(r.Context(), userID, query.Get("from"), query.Get("to"), query.Get("group_by"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnsupportedGroupBy),
+			errors.Is(err, service.ErrInvalidReportDate),
+			errors.Is(err, service.ErrInvalidReportRange):
+			writeError(w, r, err)
+		default:
+			apierror.Write(w, r, apierror.Internal("Failed to build time report", err))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+	}
+}
@@ -1,18 +1,31 @@
 package handlers
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 
+	"github.com/cauldnclark/todo-go/internal/apierror"
 	"github.com/cauldnclark/todo-go/internal/middleware"
 	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/patch"
+	"github.com/cauldnclark/todo-go/internal/repository"
 	"github.com/cauldnclark/todo-go/internal/service"
+	"github.com/cauldnclark/todo-go/internal/validation"
 	"github.com/go-chi/chi/v5"
 )
 
+const (
+	acceptPatch  = patch.MediaTypeMergePatch + ", " + patch.MediaTypeJSONPatch
+	maxPatchSize = 1 << 20
+)
+
 type TodoHandler struct {
 	todoService *service.TodoService
 	userService *service.UserService
@@ -28,7 +41,7 @@ func NewTodoHandler(todoService *service.TodoService, userService *service.UserS
 func (h *TodoHandler) GetTodos(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
@@ -54,100 +67,227 @@ func (h *TodoHandler) GetTodos(w http.ResponseWriter, r *http.Request) {
 		completedBool = nil
 	}
 
-	todoPage, err := h.todoService.GetTodos(r.Context(), userID, completedBool, page, limit)
+	todoPage, err := h.todoService.GetTodos(r.Context(), userID, completedBool, page, limit, r.URL.Query().Get("sort"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, service.ErrInvalidSort) {
+			writeError(w, r, err)
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to get todos", err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(todoPage); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if todoPage.Todos == nil {
+		todoPage.Todos = []models.Todo{}
+	}
+
+	// The page has no version of its own, so its ETag is a hash of the body.
+	body, err := json.Marshal(versionedBody(r, todoPage.Todos, todoPage.Meta, todoPage))
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to encode response", err))
+		return
 	}
+	etag := weakETag(body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if service.NoneMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
 }
 
 func (h *TodoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	var req models.CreateTodoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	err := h.todoService.CreateTodo(r.Context(), userID, &req)
+	todo, err := h.todoService.CreateTodo(r.Context(), userID, &req)
 	if err != nil {
-		http.Error(w, "Failed to create todo "+err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, service.ErrInvalidPriority) {
+			writeError(w, r, err)
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to create todo", err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Todo created successfully"}); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	w.Header().Set("Location", todoLocation(todo))
+	w.Header().Set("ETag", service.TodoETag(todo))
+	writeVersioned(w, r, http.StatusCreated, todo, nil, map[string]string{"message": "Todo created successfully"})
+}
+
+func (h *TodoHandler) QuickAddTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
 	}
+
+	var req models.QuickAddRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if r.URL.Query().Get("dry_run") == "true" {
+		req.DryRun = true
+	}
+
+	parsed, todo, err := h.todoService.QuickAdd(r.Context(), userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTimezone), errors.Is(err, service.ErrEmptyTitle):
+			writeError(w, r, err)
+		default:
+			apierror.Write(w, r, apierror.Internal("Failed to create todo", err))
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if todo != nil {
+		status = http.StatusCreated
+		w.Header().Set("Location", todoLocation(todo))
+		w.Header().Set("ETag", service.TodoETag(todo))
+	}
+	response := models.QuickAddResponse{Parsed: parsed, Todo: todo}
+	writeVersioned(w, r, status, response, nil, response)
 }
 
 func (h *TodoHandler) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	todoIDStr := chi.URLParam(r, "id")
 	todoID, err := strconv.Atoi(todoIDStr)
 	if err != nil {
-		http.Error(w, "Invalid todo ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("Invalid todo ID"))
 		return
 	}
 
 	var req models.UpdateTodoRequest
-	if errDecode := json.NewDecoder(r.Body).Decode(&req); errDecode != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if errDecode := decodeJSON(w, r, &req); errDecode != nil {
+		apierror.Write(w, r, errDecode)
 		return
 	}
 
-	todo, err := h.todoService.UpdateTodo(r.Context(), userID, todoID, &req)
+	todo, err := h.todoService.UpdateTodo(r.Context(), todoID, userID, &req, r.Header.Get("If-Match"))
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidPriority) || errors.Is(err, service.ErrEmptyTitle) {
+			writeError(w, r, err)
+			return
+		}
 		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "Todo not found", http.StatusNotFound)
+			apierror.Write(w, r, apierror.NotFound("Todo not found"))
 			return
 		}
-		http.Error(w, "Failed to update todo", http.StatusInternalServerError)
+		if errors.Is(err, service.ErrPreconditionFailed) {
+			apierror.Write(w, r, apierror.PreconditionFailed("Todo has been modified"))
+			return
+		}
+		if errors.Is(err, repository.ErrStaleVersion) {
+			writeError(w, r, err)
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to update todo", err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(todo); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	w.Header().Set("ETag", service.TodoETag(todo))
+	writeVersioned(w, r, http.StatusOK, todo, nil, todo)
+}
+
+// PatchTodo accepts a JSON Merge Patch (application/merge-patch+json or plain
+// application/json) or a JSON Patch (application/json-patch+json).
+func (h *TodoHandler) PatchTodo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	todoID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid todo ID"))
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		apierror.Write(w, r, apierror.PayloadTooLarge("Request body too large"))
+		return
 	}
+
+	todo, err := h.todoService.PatchTodo(r.Context(), todoID, userID, mediaType, body, r.Header.Get("If-Match"))
+	if err != nil {
+		var invalid *validation.Error
+		switch {
+		case errors.Is(err, service.ErrUnsupportedPatch):
+			w.Header().Set("Accept-Patch", acceptPatch)
+			writeError(w, r, err)
+		case errors.As(err, &invalid):
+			// The patch applied, but the result is not a valid todo.
+			apierror.Write(w, r, apierror.Invalid(http.StatusUnprocessableEntity, invalid))
+		case errors.Is(err, patch.ErrInvalidPatch), errors.Is(err, patch.ErrTestFailed),
+			errors.Is(err, patch.ErrCannotApply), errors.Is(err, service.ErrInvalidPatchResult):
+			writeError(w, r, err)
+		case errors.Is(err, sql.ErrNoRows):
+			apierror.Write(w, r, apierror.NotFound("Todo not found"))
+		case errors.Is(err, service.ErrPreconditionFailed):
+			apierror.Write(w, r, apierror.PreconditionFailed("Todo has been modified"))
+		case errors.Is(err, repository.ErrStaleVersion):
+			writeError(w, r, err)
+		default:
+			apierror.Write(w, r, apierror.Internal("Failed to update todo", err))
+		}
+		return
+	}
+
+	w.Header().Set("ETag", service.TodoETag(todo))
+	writeVersioned(w, r, http.StatusOK, todo, nil, todo)
 }
 
 func (h *TodoHandler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	todoIDStr := chi.URLParam(r, "id")
 	todoID, err := strconv.Atoi(todoIDStr)
 	if err != nil {
-		http.Error(w, "Invalid todo ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("Invalid todo ID"))
 		return
 	}
 
-	if err := h.todoService.DeleteTodo(r.Context(), userID, todoID); err != nil {
+	if err := h.todoService.DeleteTodo(r.Context(), todoID, userID, r.Header.Get("If-Match")); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "Todo not found", http.StatusNotFound)
+			apierror.Write(w, r, apierror.NotFound("Todo not found"))
 			return
 		}
-		http.Error(w, "Failed to delete todo", http.StatusInternalServerError)
+		if errors.Is(err, service.ErrPreconditionFailed) {
+			apierror.Write(w, r, apierror.PreconditionFailed("Todo has been modified"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal("Failed to delete todo", err))
 		return
 	}
 
@@ -157,45 +297,62 @@ func (h *TodoHandler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 func (h *TodoHandler) GetTodoByID(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	todoIDStr := chi.URLParam(r, "id")
 	todoID, err := strconv.Atoi(todoIDStr)
 	if err != nil {
-		http.Error(w, "Invalid todo ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("Invalid todo ID"))
 		return
 	}
 
 	todo, err := h.todoService.GetTodoByID(r.Context(), todoID, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "Todo not found", http.StatusNotFound)
+			apierror.Write(w, r, apierror.NotFound("Todo not found"))
 			return
 		}
-		http.Error(w, "Failed to get todo", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("Failed to get todo", err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(todo); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	etag := service.TodoETag(todo)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Accept-Patch", acceptPatch)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if service.NoneMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
+
+	writeVersioned(w, r, http.StatusOK, todo, nil, todo)
 }
 
 func (h *TodoHandler) ClearTodoCache(w http.ResponseWriter, r *http.Request) {
 	todoIDStr := chi.URLParam(r, "id")
 	todoID, err := strconv.Atoi(todoIDStr)
 	if err != nil {
-		http.Error(w, "Invalid todo ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("Invalid todo ID"))
 		return
 	}
 
 	if err := h.todoService.ClearTodoCache(r.Context(), todoID); err != nil {
-		http.Error(w, "Failed to clear todo cache", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Internal("Failed to clear todo cache", err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func todoLocation(todo *models.Todo) string {
+	return "/api/todos/" + strconv.Itoa(todo.ID)
+}
+
+// weakETag derives an entity tag from a response body, for responses that
+// have no version of their own.
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,229 @@
+package ical
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+)
+
+var (
+	ErrNoTodo     = errors.New("calendar data contains no VTODO")
+	ErrMissingUID = errors.New("VTODO has no UID")
+)
+
+const (
+	dateLocal = "20060102T150405"
+	dateOnly  = "20060102"
+)
+
+// Property is a single content line: NAME;PARAM=VALUE:value.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// ParseTodo reads the first VTODO of a calendar object. For recurring todos
+// the master component is used and overridden instances are ignored, as are
+// nested components such as VALARM.
+func ParseTodo(data []byte) (*models.Todo, error) {
+	var (
+		props   []Property
+		current []Property
+		inTodo  bool
+		nested  int
+	)
+
+	for _, line := range unfold(string(data)) {
+		prop, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VTODO") && !inTodo:
+			inTodo = true
+			current = nil
+		case !inTodo:
+		case prop.Name == "BEGIN":
+			nested++
+		case prop.Name == "END" && nested > 0:
+			nested--
+		case prop.Name == "END" && strings.EqualFold(prop.Value, "VTODO"):
+			inTodo = false
+			if props == nil || !hasProperty(current, "RECURRENCE-ID") && hasProperty(props, "RECURRENCE-ID") {
+				props = current
+			}
+		case nested == 0:
+			current = append(current, prop)
+		}
+	}
+	if props == nil {
+		return nil, ErrNoTodo
+	}
+
+	todo := &models.Todo{Priority: models.PriorityNone, Labels: []string{}}
+	for _, prop := range props {
+		switch prop.Name {
+		case "UID":
+			todo.ICalUID = strings.TrimSpace(prop.Value)
+		case "SUMMARY":
+			todo.Title = UnescapeText(prop.Value)
+		case "DESCRIPTION":
+			todo.Description = UnescapeText(prop.Value)
+		case "DUE":
+			due, err := ParseDateTime(prop.Value, prop.Params)
+			if err != nil {
+				return nil, err
+			}
+			todo.DueDate = &due
+		case "STATUS":
+			if strings.EqualFold(prop.Value, "COMPLETED") {
+				todo.Completed = true
+			}
+		case "COMPLETED":
+			todo.Completed = true
+		case "PERCENT-COMPLETE":
+			if prop.Value == "100" {
+				todo.Completed = true
+			}
+		case "PRIORITY":
+			n, _ := strconv.Atoi(prop.Value)
+			todo.Priority = TodoPriority(n)
+		case "CATEGORIES":
+			todo.Labels = append(todo.Labels, splitList(prop.Value)...)
+		case "RRULE":
+			todo.Recurrence = prop.Value
+		}
+	}
+	if todo.ICalUID == "" {
+		return nil, ErrMissingUID
+	}
+
+	return todo, nil
+}
+
+// TodoPriority maps an RFC 5545 priority back onto todo priorities.
+func TodoPriority(priority int) string {
+	switch {
+	case priority >= 1 && priority <= 4:
+		return models.PriorityHigh
+	case priority == 5:
+		return models.PriorityMedium
+	case priority >= 6 && priority <= 9:
+		return models.PriorityLow
+	}
+	return models.PriorityNone
+}
+
+// ParseDateTime reads a DATE or DATE-TIME value. Floating times and times in
+// an unknown TZID are taken as UTC.
+func ParseDateTime(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(dateOnly) {
+		return time.Parse(dateOnly, value)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(dateTimeUTC, value)
+	}
+
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = l
+		}
+	}
+	return time.ParseInLocation(dateLocal, value, loc)
+}
+
+// UnescapeText reverses EscapeText.
+func UnescapeText(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// splitList splits a multi-valued TEXT property on unescaped commas.
+func splitList(value string) []string {
+	var (
+		values []string
+		start  int
+	)
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, UnescapeText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, UnescapeText(value[start:]))
+}
+
+func hasProperty(props []Property, name string) bool {
+	for _, prop := range props {
+		if prop.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// unfold joins continuation lines and splits the data into content lines.
+func unfold(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+	return strings.Split(data, "\n")
+}
+
+// parseLine splits a content line into name, parameters and value. Colons and
+// semicolons inside quoted parameter values are not separators.
+func parseLine(line string) (Property, bool) {
+	var (
+		quoted bool
+		parts  []string
+		start  int
+	)
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				parts = append(parts, line[start:i])
+				start = i + 1
+			}
+		case ':':
+			if quoted {
+				continue
+			}
+			parts = append(parts, line[start:i])
+			prop := Property{
+				Name:   strings.ToUpper(parts[0]),
+				Params: make(map[string]string, len(parts)-1),
+				Value:  line[i+1:],
+			}
+			for _, param := range parts[1:] {
+				key, value, _ := strings.Cut(param, "=")
+				prop.Params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+			}
+			return prop, prop.Name != ""
+		}
+	}
+	return Property{}, false
+}
@@ -0,0 +1,198 @@
+// Package ical reads and writes iCalendar (RFC 5545) data for todos.
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+)
+
+const (
+	ProductID     = "-//todo-go//todo-go//EN"
+	dateTimeUTC   = "20060102T150405Z"
+	maxLineOctets = 75
+)
+
+// Writer emits content lines, folding them at 75 octets as the spec requires.
+// The first write error is kept and returned by Err; later writes are no-ops.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Err() error {
+	return w.err
+}
+
+func (w *Writer) Begin(component string) {
+	w.Line("BEGIN:" + component)
+}
+
+func (w *Writer) End(component string) {
+	w.Line("END:" + component)
+}
+
+// Property writes a TEXT property, escaping the value.
+func (w *Writer) Property(name, value string) {
+	w.Line(name + ":" + EscapeText(value))
+}
+
+// Line writes a raw content line.
+func (w *Writer) Line(line string) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = io.WriteString(w.w, fold(line)+"\r\n")
+}
+
+// BeginCalendar opens a VCALENDAR with the standard headers. name is used as
+// the calendar display name when not empty.
+func (w *Writer) BeginCalendar(name string) {
+	w.Begin("VCALENDAR")
+	w.Line("VERSION:2.0")
+	w.Line("PRODID:" + ProductID)
+	w.Line("CALSCALE:GREGORIAN")
+	if name != "" {
+		w.Property("X-WR-CALNAME", name)
+	}
+}
+
+func (w *Writer) EndCalendar() {
+	w.End("VCALENDAR")
+}
+
+// WriteTodo writes the todo as a VTODO component.
+func (w *Writer) WriteTodo(todo *models.Todo, stamp time.Time) {
+	w.Begin("VTODO")
+	w.writeCommon(todo, TodoUID(todo), stamp)
+	if todo.DueDate != nil {
+		// RRULE expands from DTSTART, so recurring todos need one.
+		if todo.Recurrence != "" {
+			w.Line("DTSTART:" + FormatDateTime(*todo.DueDate))
+		}
+		w.Line("DUE:" + FormatDateTime(*todo.DueDate))
+	}
+	if todo.Completed {
+		w.Line("STATUS:COMPLETED")
+		w.Line("PERCENT-COMPLETE:100")
+		w.Line("COMPLETED:" + FormatDateTime(todo.UpdatedAt))
+	} else {
+		w.Line("STATUS:NEEDS-ACTION")
+	}
+	w.End("VTODO")
+}
+
+// WriteEvent writes a todo with a due date as a 30 minute VEVENT ending at
+// the due time, for calendar apps that do not display VTODOs.
+func (w *Writer) WriteEvent(todo *models.Todo, stamp time.Time) {
+	if todo.DueDate == nil {
+		return
+	}
+	w.Begin("VEVENT")
+	w.writeCommon(todo, fmt.Sprintf("todo-%d-event@todo-go", todo.ID), stamp)
+	w.Line("DTSTART:" + FormatDateTime(todo.DueDate.Add(-30*time.Minute)))
+	w.Line("DTEND:" + FormatDateTime(*todo.DueDate))
+	w.Line("TRANSP:TRANSPARENT")
+	if todo.Completed {
+		w.Line("STATUS:CANCELLED")
+	} else {
+		w.Line("STATUS:CONFIRMED")
+	}
+	w.End("VEVENT")
+}
+
+func (w *Writer) writeCommon(todo *models.Todo, uid string, stamp time.Time) {
+	w.Property("UID", uid)
+	w.Line("DTSTAMP:" + FormatDateTime(stamp))
+	w.Line("CREATED:" + FormatDateTime(todo.CreatedAt))
+	w.Line("LAST-MODIFIED:" + FormatDateTime(todo.UpdatedAt))
+	w.Property("SUMMARY", todo.Title)
+	if todo.Description != "" {
+		w.Property("DESCRIPTION", todo.Description)
+	}
+	if priority := Priority(todo.Priority); priority != 0 {
+		w.Line("PRIORITY:" + strconv.Itoa(priority))
+	}
+	if len(todo.Labels) > 0 {
+		escaped := make([]string, len(todo.Labels))
+		for i, label := range todo.Labels {
+			escaped[i] = EscapeText(label)
+		}
+		w.Line("CATEGORIES:" + strings.Join(escaped, ","))
+	}
+	if todo.Recurrence != "" && todo.DueDate != nil {
+		w.Line("RRULE:" + todo.Recurrence)
+	}
+}
+
+// TodoUID returns the iCalendar UID of a todo. Todos created by calendar
+// clients keep the UID the client gave them.
+func TodoUID(todo *models.Todo) string {
+	if todo.ICalUID != "" {
+		return todo.ICalUID
+	}
+	return fmt.Sprintf("todo-%d@todo-go", todo.ID)
+}
+
+// Priority maps todo priorities onto the 1 (highest) to 9 (lowest) scale of
+// RFC 5545; 0 means undefined.
+func Priority(priority string) int {
+	switch priority {
+	case models.PriorityHigh:
+		return 1
+	case models.PriorityMedium:
+		return 5
+	case models.PriorityLow:
+		return 9
+	}
+	return 0
+}
+
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeUTC)
+}
+
+// EscapeText escapes a TEXT value as described in RFC 5545 section 3.3.11.
+func EscapeText(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return replacer.Replace(value)
+}
+
+// fold splits a content line into chunks of at most 75 octets without
+// breaking UTF-8 sequences; continuation lines start with a space.
+func fold(line string) string {
+	if len(line) <= maxLineOctets {
+		return line
+	}
+
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line)
+	return b.String()
+}
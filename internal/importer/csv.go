@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+)
+
+// parseCSV expects a header row. Recognised columns are title (required),
+// description, completed, due_date, priority, recurrence and labels, where
+// labels are separated by ';' or ','.
+func parseCSV(data []byte) ([]Record, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrMalformedFile
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("csv header must include a title column")
+	}
+
+	var records []Record
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				records = append(records, Record{Row: parseErr.Line, Err: parseErr.Err})
+				continue
+			}
+			return nil, ErrMalformedFile
+		}
+
+		get := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(fields) {
+				return ""
+			}
+			return strings.TrimSpace(fields[i])
+		}
+
+		record := Record{Row: line}
+		record.Todo.Title = get("title")
+		record.Todo.Description = get("description")
+		record.Todo.Completed = parseBool(get("completed"))
+		record.Todo.Priority = strings.ToLower(get("priority"))
+		record.Todo.Recurrence = get("recurrence")
+		record.Todo.Labels = splitLabels(get("labels"))
+		record.Todo.DueDate, record.Err = parseDate(get("due_date"))
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func parseBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "yes", "y", "1", "x", "done", "completed":
+		return true
+	}
+	return false
+}
+
+func splitLabels(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == ','
+	})
+}
@@ -0,0 +1,125 @@
+// Package importer reads todos from CSV files, our own JSON export and
+// Todoist and Trello JSON exports. Problems with individual rows are reported
+// on the affected record instead of failing the whole file.
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatJSON    = "json"
+	FormatTodoist = "todoist"
+	FormatTrello  = "trello"
+)
+
+var (
+	ErrUnknownFormat = errors.New("format must be one of: csv, json, todoist, trello")
+	ErrMalformedFile = errors.New("file could not be parsed")
+)
+
+// Record is one todo read from the source. Row is the 1-based position of the
+// record in the file (the CSV line, or the index in the JSON array).
+type Record struct {
+	Row  int
+	Todo models.ExportTodo
+	Err  error
+}
+
+// Parse reads every record of data in the given format.
+func Parse(format string, data []byte) ([]Record, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(data)
+	case FormatJSON:
+		return parseExport(data)
+	case FormatTodoist:
+		return parseTodoist(data)
+	case FormatTrello:
+		return parseTrello(data)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// Detect guesses the format from the content type and the content itself:
+// anything that is not JSON is treated as CSV. It returns an empty string
+// when it cannot tell, including for JSON arrays, which no supported format
+// uses.
+func Detect(data []byte, contentType string) string {
+	if strings.HasPrefix(contentType, "text/csv") {
+		return FormatCSV
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return ""
+	}
+	if trimmed[0] == '[' {
+		return ""
+	}
+	if trimmed[0] != '{' {
+		return FormatCSV
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &probe); err != nil {
+		return ""
+	}
+	switch {
+	case probe["format"] != nil:
+		return FormatJSON
+	case probe["items"] != nil:
+		return FormatTodoist
+	case probe["cards"] != nil:
+		return FormatTrello
+	}
+	return ""
+}
+
+func parseExport(data []byte) ([]Record, error) {
+	var doc models.ExportDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, ErrMalformedFile
+	}
+	if doc.Format != models.ExportFormat {
+		return nil, errors.New("not a todo-go export document")
+	}
+	if doc.Version > models.ExportVersion {
+		return nil, errors.New("export document version is newer than this server supports")
+	}
+
+	records := make([]Record, len(doc.Todos))
+	for i, todo := range doc.Todos {
+		todo.ID = 0
+		records[i] = Record{Row: i + 1, Todo: todo}
+	}
+	return records, nil
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseDate(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, errors.New("unrecognised date: " + value)
+}
@@ -0,0 +1,91 @@
+package importer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/quickadd"
+)
+
+type todoistBackup struct {
+	Projects []struct {
+		ID   json.RawMessage `json:"id"`
+		Name string          `json:"name"`
+	} `json:"projects"`
+	Items []struct {
+		Content     string          `json:"content"`
+		Description string          `json:"description"`
+		Checked     flexBool        `json:"checked"`
+		Priority    int             `json:"priority"`
+		Labels      []string        `json:"labels"`
+		ProjectID   json.RawMessage `json:"project_id"`
+		Due         *struct {
+			Date        string `json:"date"`
+			IsRecurring bool   `json:"is_recurring"`
+			String      string `json:"string"`
+		} `json:"due"`
+	} `json:"items"`
+}
+
+// flexBool accepts both true/false and the 0/1 integers older Todoist
+// backups use.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true", "1":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// parseTodoist maps a Todoist JSON backup. Todoist priorities run from 1
+// (normal) to 4 (urgent); the project name is kept as a label.
+func parseTodoist(data []byte) ([]Record, error) {
+	var backup todoistBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, ErrMalformedFile
+	}
+
+	projects := make(map[string]string, len(backup.Projects))
+	for _, project := range backup.Projects {
+		projects[strings.Trim(string(project.ID), `"`)] = project.Name
+	}
+
+	records := make([]Record, len(backup.Items))
+	for i, item := range backup.Items {
+		record := Record{Row: i + 1}
+		record.Todo.Title = item.Content
+		record.Todo.Description = item.Description
+		record.Todo.Completed = bool(item.Checked)
+		record.Todo.Priority = todoistPriority(item.Priority)
+		record.Todo.Labels = item.Labels
+		if name, ok := projects[strings.Trim(string(item.ProjectID), `"`)]; ok && name != "" {
+			record.Todo.Labels = append(record.Todo.Labels, name)
+		}
+		if item.Due != nil {
+			record.Todo.DueDate, record.Err = parseDate(item.Due.Date)
+			if item.Due.IsRecurring {
+				record.Todo.Recurrence = quickadd.Parse(item.Due.String, time.Now(), time.UTC).Recurrence
+			}
+		}
+		records[i] = record
+	}
+
+	return records, nil
+}
+
+func todoistPriority(priority int) string {
+	switch priority {
+	case 4:
+		return "high"
+	case 3:
+		return "medium"
+	case 2:
+		return "low"
+	}
+	return "none"
+}
@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type trelloBoard struct {
+	Name  string `json:"name"`
+	Cards []struct {
+		ID          string  `json:"id"`
+		Name        string  `json:"name"`
+		Desc        string  `json:"desc"`
+		Closed      bool    `json:"closed"`
+		Due         *string `json:"due"`
+		DueComplete bool    `json:"dueComplete"`
+		Labels      []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"labels"`
+	} `json:"cards"`
+	Checklists []struct {
+		IDCard     string `json:"idCard"`
+		Name       string `json:"name"`
+		CheckItems []struct {
+			Name  string `json:"name"`
+			State string `json:"state"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+}
+
+// parseTrello maps a Trello board export. Archived cards and cards whose due
+// date is marked complete are imported as completed, the board name is kept
+// as a label, and checklists are appended to the description as Markdown
+// task lists.
+func parseTrello(data []byte) ([]Record, error) {
+	var board trelloBoard
+	if err := json.Unmarshal(data, &board); err != nil {
+		return nil, ErrMalformedFile
+	}
+
+	checklists := make(map[string][]string)
+	for _, checklist := range board.Checklists {
+		lines := []string{"", "### " + checklist.Name}
+		for _, item := range checklist.CheckItems {
+			mark := " "
+			if item.State == "complete" {
+				mark = "x"
+			}
+			lines = append(lines, "- ["+mark+"] "+item.Name)
+		}
+		checklists[checklist.IDCard] = append(checklists[checklist.IDCard], lines...)
+	}
+
+	records := make([]Record, len(board.Cards))
+	for i, card := range board.Cards {
+		record := Record{Row: i + 1}
+		record.Todo.Title = card.Name
+		record.Todo.Description = card.Desc
+		if lines, ok := checklists[card.ID]; ok {
+			record.Todo.Description = strings.TrimSpace(record.Todo.Description + "\n" + strings.Join(lines, "\n"))
+		}
+		record.Todo.Completed = card.Closed || card.DueComplete
+		for _, label := range card.Labels {
+			name := label.Name
+			if name == "" {
+				name = label.Color
+			}
+			record.Todo.Labels = append(record.Todo.Labels, name)
+		}
+		if board.Name != "" {
+			record.Todo.Labels = append(record.Todo.Labels, board.Name)
+		}
+		if card.Due != nil {
+			record.Todo.DueDate, record.Err = parseDate(*card.Due)
+		}
+		records[i] = record
+	}
+
+	return records, nil
+}
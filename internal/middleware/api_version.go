@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+)
+
+const (
+	// APIVersionLegacy returns bare objects, as the API did originally.
+	APIVersionLegacy = 1
+	// APIVersionEnvelope wraps responses in {"data": ..., "meta": ...}.
+	APIVersionEnvelope = 2
+
+	apiVersionHeader                = "API-Version"
+	APIVersionContextKey contextKey = "apiVersion"
+)
+
+// APIVersionMiddleware picks the response format from the API-Version request
+// header, falling back to defaultVersion, and echoes it in the response.
+func APIVersionMiddleware(defaultVersion int) func(http.Handler) http.Handler {
+	if defaultVersion < APIVersionLegacy || defaultVersion > APIVersionEnvelope {
+		defaultVersion = APIVersionLegacy
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version := defaultVersion
+			if value := r.Header.Get(apiVersionHeader); value != "" {
+				v, err := strconv.Atoi(value)
+				if err != nil || v < APIVersionLegacy || v > APIVersionEnvelope {
+					apierror.Write(w, r, apierror.BadRequest("Unsupported API-Version"))
+					return
+				}
+				version = v
+			}
+
+			w.Header().Set(apiVersionHeader, strconv.Itoa(version))
+			ctx := context.WithValue(r.Context(), APIVersionContextKey, version)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetAPIVersionFromContext returns the negotiated API version, or the
+// envelope version when the middleware did not run.
+func GetAPIVersionFromContext(ctx context.Context) int {
+	if version, ok := ctx.Value(APIVersionContextKey).(int); ok {
+		return version
+	}
+	return APIVersionEnvelope
+}
@@ -2,23 +2,41 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/auth"
+	"github.com/cauldnclark/todo-go/internal/models"
 )
 
 type contextKey string
 
-const UserIdContextKey contextKey = "userID"
+const (
+	UserIdContextKey    contextKey = "userID"
+	SessionIDContextKey contextKey = "sessionID"
+
+	codeSessionRequired apierror.Code = "session_required"
+)
+
+// PersonalAccessTokenAuthenticator resolves personal access tokens presented
+// as bearer tokens. Unknown, expired and revoked tokens are reported as
+// sql.ErrNoRows.
+type PersonalAccessTokenAuthenticator interface {
+	AuthenticatePersonalAccessToken(ctx context.Context, token string) (*models.PersonalAccessToken, error)
+}
 
 type AuthMiddleware struct {
-	jwtSecret string
+	tokens         *auth.Manager
+	personalTokens PersonalAccessTokenAuthenticator
 }
 
-func NewAuthMiddleware(jwtSecret string) *AuthMiddleware {
+func NewAuthMiddleware(tokens *auth.Manager, personalTokens PersonalAccessTokenAuthenticator) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtSecret: jwtSecret,
+		tokens:         tokens,
+		personalTokens: personalTokens,
 	}
 }
 
@@ -26,40 +44,77 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Unauthorized("Authorization header required"))
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			http.Error(w, "Bearer token required", http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Unauthorized("Bearer token required"))
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(m.jwtSecret), nil
-		})
+		if strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+			m.authenticatePersonalAccessToken(w, r, next, tokenString)
+			return
+		}
 
+		claims, err := m.tokens.Authenticate(r.Context(), tokenString)
 		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			WriteAuthError(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), UserIdContextKey, claims.UserID)
+		ctx = context.WithValue(ctx, ScopesContextKey, claims.Scopes())
+		if claims.SessionID != "" {
+			ctx = context.WithValue(ctx, SessionIDContextKey, claims.SessionID)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WriteAuthError reports an error from auth.Manager.Authenticate.
+func WriteAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrRevokedToken):
+		apierror.Write(w, r, apierror.Unauthorized("Token has been revoked"))
+	case errors.Is(err, auth.ErrInvalidToken):
+		apierror.Write(w, r, apierror.Unauthorized("Invalid token"))
+	default:
+		apierror.Write(w, r, apierror.Internal("Failed to check token", err))
+	}
+}
+
+// authenticatePersonalAccessToken serves the request as the owner of the
+// personal access token.
+func (m *AuthMiddleware) authenticatePersonalAccessToken(w http.ResponseWriter, r *http.Request, next http.Handler, tokenString string) {
+	token, err := m.personalTokens.AuthenticatePersonalAccessToken(r.Context(), tokenString)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, r, apierror.Unauthorized("Invalid token"))
 			return
 		}
+		apierror.Write(w, r, apierror.Internal("Failed to check token", err))
+		return
+	}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			userID, ok := claims["user_id"].(float64)
-			if !ok {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
-				return
-			}
-			ctx := context.WithValue(r.Context(), UserIdContextKey, int(userID))
-			next.ServeHTTP(w, r.WithContext(ctx))
-		} else {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+	ctx := context.WithValue(r.Context(), UserIdContextKey, token.UserID)
+	ctx = context.WithValue(ctx, ScopesContextKey, token.Scopes)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireSession rejects requests that are not made with a session's access
+// token. Endpoints that manage credentials or the account itself use it, so
+// that a personal access token cannot mint broader tokens or lock out its
+// owner.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := GetSessionIDFromContext(r.Context()); !ok {
+			apierror.Write(w, r, apierror.New(http.StatusForbidden, codeSessionRequired, "This endpoint cannot be used with a personal access token"))
 			return
 		}
+		next.ServeHTTP(w, r)
 	})
 }
 
@@ -67,3 +122,10 @@ func GetUserIDFromContext(ctx context.Context) (int, bool) {
 	userID, ok := ctx.Value(UserIdContextKey).(int)
 	return userID, ok
 }
+
+// GetSessionIDFromContext returns the session the request's token was issued
+// to.
+func GetSessionIDFromContext(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(SessionIDContextKey).(string)
+	return sessionID, ok && sessionID != ""
+}
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/redis"
+)
+
+const (
+	idempotencyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+	// inFlightTTL bounds how long a key stays locked if the server dies
+	// while handling the request; it outlasts the request timeout.
+	inFlightTTL = 2 * time.Minute
+	// maxReplayBody is the largest response kept for replay.
+	maxReplayBody = 1 << 20
+)
+
+// replayedHeaders are the response headers stored with the body.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+type idempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"`
+	Completed   bool        `json:"completed"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyMiddleware makes retried POST, PUT, PATCH and DELETE requests
+// that carry an Idempotency-Key header safe. The first request with a key is
+// processed and its response kept in Redis for ttl; retries with the same key
+// and payload get that response replayed. Reusing a key for a different
+// payload is rejected with 422 and a retry that arrives while the first
+// request is still running gets 409. Keys are scoped to the user, so the
+// middleware must run after authentication, and after the scope checks so a
+// rejection is not replayed once the client has the scope.
+//
+// The body is buffered to fingerprint the request; bodies larger than
+// maxBodySize are rejected with 413.
+type IdempotencyMiddleware struct {
+	client      *redis.Client
+	ttl         time.Duration
+	maxBodySize int64
+}
+
+func NewIdempotencyMiddleware(client *redis.Client, ttl time.Duration, maxBodySize int64) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		client:      client,
+		ttl:         ttl,
+		maxBodySize: maxBodySize,
+	}
+}
+
+func (m *IdempotencyMiddleware) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			apierror.Write(w, r, apierror.BadRequest("Idempotency-Key is too long"))
+			return
+		}
+
+		userID, ok := GetUserIDFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, m.maxBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apierror.Write(w, r, apierror.PayloadTooLarge("Request body too large"))
+				return
+			}
+			apierror.Write(w, r, apierror.BadRequest("Failed to read request body"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		redisKey := fmt.Sprintf("idempotency:%d:%s", userID, key)
+		fingerprint := requestFingerprint(r, body)
+
+		acquired, err := m.lock(r.Context(), redisKey, fingerprint)
+		if err != nil {
+			// Fail open, like the rate limiter: Redis trouble should not
+			// stop writes.
+			log.Printf("Idempotency lock failed: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !acquired {
+			m.replay(w, r, redisKey, fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		// Let clients retry requests that failed on our side or were not
+		// authorized, and do not keep responses too large to replay.
+		ctx := context.WithoutCancel(r.Context())
+		if recorder.status >= http.StatusInternalServerError || recorder.overflow ||
+			recorder.status == http.StatusUnauthorized || recorder.status == http.StatusForbidden {
+			m.client.GetClient().Del(ctx, redisKey)
+			return
+		}
+
+		record := idempotencyRecord{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			Status:      recorder.status,
+			Header:      http.Header{},
+			Body:        recorder.body.Bytes(),
+		}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				record.Header.Set(name, value)
+			}
+		}
+		data, err := json.Marshal(record)
+		if err == nil {
+			err = m.client.GetClient().Set(ctx, redisKey, data, m.ttl).Err()
+		}
+		if err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+		}
+	})
+}
+
+// lock claims the key for this request. It returns false if the key is
+// already in use.
+func (m *IdempotencyMiddleware) lock(ctx context.Context, redisKey, fingerprint string) (bool, error) {
+	data, err := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return false, err
+	}
+	return m.client.GetClient().SetNX(ctx, redisKey, data, inFlightTTL).Result()
+}
+
+func (m *IdempotencyMiddleware) replay(w http.ResponseWriter, r *http.Request, redisKey, fingerprint string) {
+	data, err := m.client.GetClient().Get(r.Context(), redisKey).Bytes()
+	if err != nil {
+		// The key expired or was released between SETNX and GET.
+		w.Header().Set("Retry-After", "1")
+		apierror.Write(w, r, apierror.Conflict("A request with this Idempotency-Key is being processed"))
+		return
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		apierror.Write(w, r, apierror.Internal("Failed to read idempotent response", err))
+		return
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		apierror.Write(w, r, apierror.Unprocessable("Idempotency-Key was already used for a different request"))
+	case !record.Completed:
+		w.Header().Set("Retry-After", "1")
+		apierror.Write(w, r, apierror.Conflict("A request with this Idempotency-Key is being processed"))
+	default:
+		for name, values := range record.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(record.Status)
+		w.Write(record.Body)
+	}
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// requestFingerprint identifies the request a key was first used for.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder passes the response through while keeping a copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if !r.overflow {
+		if r.body.Len()+len(b) > maxReplayBody {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
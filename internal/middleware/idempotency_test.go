@@ -0,0 +1,277 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/redis/redistest"
+)
+
+// countingHandler responds with status and body and counts its calls.
+type countingHandler struct {
+	calls  atomic.Int32
+	status int
+	body   string
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls.Add(1)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/todos/1")
+	w.WriteHeader(h.status)
+	w.Write([]byte(h.body))
+}
+
+func newIdempotencyRequest(userID int, method, target, key, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(idempotencyHeader, key)
+	}
+	if userID > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), UserIdContextKey, userID))
+	}
+	return r
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	client, _ := redistest.NewClient(t)
+	next := &countingHandler{status: http.StatusCreated, body: `{"id":1}`}
+	h := NewIdempotencyMiddleware(client, time.Hour, 1024).Handle(next)
+
+	first := serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{"title":"a"}`))
+	second := serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{"title":"a"}`))
+
+	if calls := next.calls.Load(); calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("first response marked as replayed")
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != `{"id":1}` {
+		t.Errorf("replay = %d %q, want %d %q", second.Code, second.Body.String(), http.StatusCreated, `{"id":1}`)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replay not marked with Idempotent-Replayed")
+	}
+	if got := second.Header().Get("Location"); got != "/api/todos/1" {
+		t.Errorf("replayed Location = %q, want %q", got, "/api/todos/1")
+	}
+}
+
+func TestIdempotencyRejectsKeyReuseForDifferentRequest(t *testing.T) {
+	client, _ := redistest.NewClient(t)
+	next := &countingHandler{status: http.StatusCreated, body: `{"id":1}`}
+	h := NewIdempotencyMiddleware(client, time.Hour, 1024).Handle(next)
+
+	serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{"title":"a"}`))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{name: "different body", method: http.MethodPost, target: "/api/todos", body: `{"title":"b"}`},
+		{name: "different path", method: http.MethodPost, target: "/api/templates", body: `{"title":"a"}`},
+		{name: "different method", method: http.MethodPut, target: "/api/todos", body: `{"title":"a"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, newIdempotencyRequest(1, tt.method, tt.target, "abc", tt.body))
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+			}
+		})
+	}
+	if calls := next.calls.Load(); calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyKeysAreScopedToTheUser(t *testing.T) {
+	client, _ := redistest.NewClient(t)
+	next := &countingHandler{status: http.StatusCreated, body: `{"id":1}`}
+	h := NewIdempotencyMiddleware(client, time.Hour, 1024).Handle(next)
+
+	serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{"title":"a"}`))
+	w := serve(h, newIdempotencyRequest(2, http.MethodPost, "/api/todos", "abc", `{"title":"a"}`))
+
+	if calls := next.calls.Load(); calls != 2 {
+		t.Fatalf("handler called %d times, want 2", calls)
+	}
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("another user's response was replayed")
+	}
+}
+
+func TestIdempotencyDoesNotStoreRetryableResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "server error", status: http.StatusInternalServerError, body: `{"error":"boom"}`},
+		{name: "unavailable", status: http.StatusServiceUnavailable},
+		{name: "unauthorized", status: http.StatusUnauthorized},
+		{name: "forbidden", status: http.StatusForbidden},
+		{name: "too large to replay", status: http.StatusOK, body: strings.Repeat("x", maxReplayBody+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := redistest.NewClient(t)
+			next := &countingHandler{status: tt.status, body: tt.body}
+			h := NewIdempotencyMiddleware(client, time.Hour, 1024).Handle(next)
+
+			first := serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{}`))
+			if first.Code != tt.status || first.Body.Len() != len(tt.body) {
+				t.Errorf("first response = %d with %d bytes, want %d with %d", first.Code, first.Body.Len(), tt.status, len(tt.body))
+			}
+			if keys := server.Keys(); len(keys) != 0 {
+				t.Errorf("stored keys %v, want none", keys)
+			}
+
+			serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{}`))
+			if calls := next.calls.Load(); calls != 2 {
+				t.Errorf("handler called %d times, want 2", calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyStoresClientErrors(t *testing.T) {
+	client, _ := redistest.NewClient(t)
+	next := &countingHandler{status: http.StatusBadRequest, body: `{"error":"invalid"}`}
+	h := NewIdempotencyMiddleware(client, time.Hour, 1024).Handle(next)
+
+	serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{}`))
+	w := serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{}`))
+
+	if calls := next.calls.Load(); calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+	if w.Code != http.StatusBadRequest || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replay = %d replayed=%q, want %d replayed", w.Code, w.Header().Get("Idempotent-Replayed"), http.StatusBadRequest)
+	}
+}
+
+func TestIdempotencyStoredResponseExpires(t *testing.T) {
+	client, server := redistest.NewClient(t)
+	next := &countingHandler{status: http.StatusCreated, body: `{"id":1}`}
+	h := NewIdempotencyMiddleware(client, time.Hour, 1024).Handle(next)
+
+	serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{}`))
+	if ttl := server.TTL("idempotency:1:abc"); ttl <= inFlightTTL || ttl > time.Hour {
+		t.Errorf("stored response TTL = %v, want the configured hour", ttl)
+	}
+
+	server.FastForward(time.Hour)
+	serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{}`))
+	if calls := next.calls.Load(); calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestIdempotencyRejectsRetryWhileInFlight(t *testing.T) {
+	client, _ := redistest.NewClient(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := NewIdempotencyMiddleware(client, time.Hour, 1024).Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{}`))
+	}()
+	<-started
+
+	w := serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", `{}`))
+	close(release)
+	first := <-done
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("retry status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("retry response has no Retry-After")
+	}
+	if first.Code != http.StatusCreated {
+		t.Errorf("first status = %d, want %d", first.Code, http.StatusCreated)
+	}
+}
+
+func TestIdempotencyRejectsOversizedBody(t *testing.T) {
+	client, server := redistest.NewClient(t)
+	next := &countingHandler{status: http.StatusCreated}
+	h := NewIdempotencyMiddleware(client, time.Hour, 16).Handle(next)
+
+	w := serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", "abc", strings.Repeat("x", 17)))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if calls := next.calls.Load(); calls != 0 {
+		t.Errorf("handler called %d times, want 0", calls)
+	}
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Errorf("stored keys %v, want none", keys)
+	}
+}
+
+func TestIdempotencyPassesThrough(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID int
+		method string
+		key    string
+	}{
+		{name: "no key", userID: 1, method: http.MethodPost},
+		{name: "safe method", userID: 1, method: http.MethodGet, key: "abc"},
+		{name: "unauthenticated", method: http.MethodPost, key: "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := redistest.NewClient(t)
+			next := &countingHandler{status: http.StatusOK}
+			h := NewIdempotencyMiddleware(client, time.Hour, 1024).Handle(next)
+
+			serve(h, newIdempotencyRequest(tt.userID, tt.method, "/api/todos", tt.key, `{}`))
+			serve(h, newIdempotencyRequest(tt.userID, tt.method, "/api/todos", tt.key, `{}`))
+
+			if calls := next.calls.Load(); calls != 2 {
+				t.Errorf("handler called %d times, want 2", calls)
+			}
+			if keys := server.Keys(); len(keys) != 0 {
+				t.Errorf("stored keys %v, want none", keys)
+			}
+		})
+	}
+}
+
+func TestIdempotencyRejectsLongKey(t *testing.T) {
+	client, _ := redistest.NewClient(t)
+	next := &countingHandler{status: http.StatusOK}
+	h := NewIdempotencyMiddleware(client, time.Hour, 1024).Handle(next)
+
+	w := serve(h, newIdempotencyRequest(1, http.MethodPost, "/api/todos", strings.Repeat("k", maxIdempotencyKeyLen+1), `{}`))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if calls := next.calls.Load(); calls != 0 {
+		t.Errorf("handler called %d times, want 0", calls)
+	}
+}
@@ -1,11 +1,12 @@
 package middleware
 
 import (
-	"encoding/json"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/cauldnclark/todo-go/internal/apierror"
 	"github.com/cauldnclark/todo-go/internal/ratelimit"
 )
 
@@ -20,30 +21,47 @@ func RateLimitMiddleware(rateLimiter *ratelimit.RateLimiter, maxReq int, window
 			}
 
 			allowed, remaining, resetIn, err := rateLimiter.Allow(r.Context(), userID, maxReq, window)
-			if err != nil {
-				// Fail open — don't block on Redis error
-				next.ServeHTTP(w, r)
-				return
-			}
+			writeRateLimited(w, r, next, maxReq, allowed, remaining, resetIn, err)
+		})
+	}
+}
 
-			// Set headers (RFC 6585)
-			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(maxReq))
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
-			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+resetIn, 10))
-
-			if !allowed {
-				w.Header().Set("Content-Type", "application/json")
-				w.Header().Set("Retry-After", strconv.FormatInt(resetIn, 10))
-				w.WriteHeader(http.StatusTooManyRequests)
-				response := map[string]string{
-					"error":       "Rate limit exceeded",
-					"retry_after": strconv.FormatInt(resetIn, 10) + " seconds",
-				}
-				json.NewEncoder(w).Encode(response)
-				return
+// IPRateLimitMiddleware limits requests by client IP, for public routes with
+// no signed-in user. It relies on chimiddle.RealIP running first when the
+// server sits behind a proxy.
+func IPRateLimitMiddleware(rateLimiter *ratelimit.RateLimiter, maxReq int, window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := r.RemoteAddr
+			if host, _, err := net.SplitHostPort(ip); err == nil {
+				ip = host
 			}
 
-			next.ServeHTTP(w, r)
+			allowed, remaining, resetIn, err := rateLimiter.AllowIP(r.Context(), ip, maxReq, window)
+			writeRateLimited(w, r, next, maxReq, allowed, remaining, resetIn, err)
 		})
 	}
 }
+
+// writeRateLimited sets the rate limit headers and either passes the request
+// on or rejects it.
+func writeRateLimited(w http.ResponseWriter, r *http.Request, next http.Handler, maxReq int, allowed bool, remaining int, resetIn int64, err error) {
+	if err != nil {
+		// Fail open — don't block on Redis error
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	// Set headers (RFC 6585)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(maxReq))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+resetIn, 10))
+
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.FormatInt(resetIn, 10))
+		apierror.Write(w, r, apierror.RateLimited("Rate limit exceeded, retry in "+strconv.FormatInt(resetIn, 10)+" seconds"))
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
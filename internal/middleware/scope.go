@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/cauldnclark/todo-go/internal/apierror"
+)
+
+const (
+	ScopesContextKey contextKey = "scopes"
+
+	codeInsufficientScope apierror.Code = "insufficient_scope"
+)
+
+// RequireScope rejects requests whose token was not granted scope.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r.Context(), scope) {
+				writeInsufficientScope(w, r, scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireReadWriteScope requires readScope for safe methods (GET, HEAD,
+// OPTIONS) and writeScope for everything else.
+func RequireReadWriteScope(readScope, writeScope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope := writeScope
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				scope = readScope
+			}
+			if !HasScope(r.Context(), scope) {
+				writeInsufficientScope(w, r, scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetScopesFromContext returns the scopes granted to the request's token.
+func GetScopesFromContext(ctx context.Context) []string {
+	scopes, _ := ctx.Value(ScopesContextKey).([]string)
+	return scopes
+}
+
+func HasScope(ctx context.Context, scope string) bool {
+	return slices.Contains(GetScopesFromContext(ctx), scope)
+}
+
+// writeInsufficientScope reports the missing scope as RFC 6750 describes.
+func writeInsufficientScope(w http.ResponseWriter, r *http.Request, scope string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+	apierror.Write(w, r, apierror.New(http.StatusForbidden, codeInsufficientScope, "Token lacks the "+scope+" scope"))
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	AccountExportPending   = "pending"
+	AccountExportRunning   = "running"
+	AccountExportCompleted = "completed"
+	AccountExportFailed    = "failed"
+)
+
+// AccountExport tracks the archive of all of a user's data. A user has at
+// most one export; the archive can be downloaded until ExpiresAt.
+type AccountExport struct {
+	UserID     int        `json:"user_id"`
+	Status     string     `json:"status"`
+	Size       int        `json:"size,omitempty"`
+	Message    string     `json:"message,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// AccountDeletion is returned when a user asks for their account to be
+// deleted. Signing in again before DeletionScheduledAt cancels the deletion.
+type AccountDeletion struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
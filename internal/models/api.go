@@ -1,25 +1,50 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type User struct {
-	ID         int       `json:"id" db:"id"`
-	GoogleID   string    `json:"google_id" db:"google_id"`
-	Email      string    `json:"email" db:"email"`
-	Name       string    `json:"name" db:"name"`
-	PictureURL string    `json:"picture_url" db:"picture_url"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	ID         int          `json:"id" db:"id"`
+	Email      string       `json:"email" db:"email"`
+	Name       string       `json:"name" db:"name"`
+	PictureURL string       `json:"picture_url" db:"picture_url"`
+	Settings   UserSettings `json:"settings" db:"-"`
+	// DeletionScheduledAt is when the account will be deleted, if the user
+	// asked for that.
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" db:"deletion_scheduled_at"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+const (
+	PriorityNone   = "none"
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
 type Todo struct {
-	ID          int       `json:"id" db:"id"`
-	UserID      int       `json:"user_id" db:"user_id"`
-	Title       string    `json:"title" db:"title"`
-	Description string    `json:"description" db:"description"`
-	Completed   bool      `json:"completed" db:"completed"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Title       string     `json:"title" db:"title"`
+	Description string     `json:"description" db:"description"`
+	Completed   bool       `json:"completed" db:"completed"`
+	DueDate     *time.Time `json:"due_date" db:"due_date"`
+	Priority    string     `json:"priority" db:"priority"`
+	Recurrence  string     `json:"recurrence" db:"recurrence"`
+	Labels      []string   `json:"labels" db:"labels"`
+	ICalUID     string     `json:"ical_uid" db:"ical_uid"`
+	ClientID    string     `json:"client_id,omitempty" db:"client_id"`
+	Version     int        `json:"version" db:"version"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	// CalDAVResource is the file name a CalDAV client chose for the todo
+	// when it differs from "<ical_uid>.ics".
+	CalDAVResource string `json:"-" db:"caldav_resource"`
+	// ChangeSeq increases on every write, across all todos.
+	ChangeSeq int64 `json:"-" db:"change_seq"`
 }
 
 type MetaPagination struct {
@@ -28,43 +53,119 @@ type MetaPagination struct {
 	Limit int `json:"limit"`
 }
 
+// Envelope is the response body of todo endpoints from API version 2 on.
+type Envelope struct {
+	Data any `json:"data"`
+	Meta any `json:"meta,omitempty"`
+}
+
 type TodosPaginated struct {
 	Todos []Todo         `json:"todos"`
 	Meta  MetaPagination `json:"meta"`
 }
 
 type CreateTodoRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title       string     `json:"title" validate:"required,max=255"`
+	Description string     `json:"description" validate:"max=10000"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    string     `json:"priority" validate:"omitempty,oneof=none low medium high"`
+	Recurrence  string     `json:"recurrence" validate:"max=500"`
+	Labels      []string   `json:"labels" validate:"max=50,dive,max=64"`
+}
+
+func (r *CreateTodoRequest) Normalize() {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Description = strings.TrimSpace(r.Description)
+	r.Priority = strings.ToLower(strings.TrimSpace(r.Priority))
+	r.Recurrence = strings.TrimSpace(r.Recurrence)
 }
 
+// UpdateTodoRequest is the complete editable state of a todo. PUT replaces
+// the todo with it, so fields left out are reset; it is also the document
+// PATCH requests are applied to.
 type UpdateTodoRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   *bool  `json:"completed"`
+	Title       string     `json:"title" validate:"required,max=255"`
+	Description string     `json:"description" validate:"max=10000"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    string     `json:"priority" validate:"omitempty,oneof=none low medium high"`
+	Recurrence  string     `json:"recurrence" validate:"max=500"`
+	Labels      []string   `json:"labels" validate:"max=50,dive,max=64"`
 }
 
-type GoogleTokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	TokenType    string `json:"token_type"`
-	ExpiresIn    int    `json:"expires_in"`
-	RefreshToken string `json:"refresh_token,omitempty"`
-	Scope        string `json:"scope"`
+func (r *UpdateTodoRequest) Normalize() {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Description = strings.TrimSpace(r.Description)
+	r.Priority = strings.ToLower(strings.TrimSpace(r.Priority))
+	r.Recurrence = strings.TrimSpace(r.Recurrence)
+}
+
+type QuickAddRequest struct {
+	Text     string `json:"text" validate:"required,max=1000"`
+	Timezone string `json:"timezone" validate:"omitempty,timezone"`
+	DryRun   bool   `json:"dry_run"`
+}
+
+func (r *QuickAddRequest) Normalize() {
+	r.Text = strings.TrimSpace(r.Text)
+	r.Timezone = strings.TrimSpace(r.Timezone)
 }
 
-type GoogleTokenInfo struct {
-	ID         string `json:"id"`
-	Email      string `json:"email"`
-	Name       string `json:"name"`
-	PictureURL string `json:"picture"`
+// QuickAddResult is how quick-add text was interpreted. Matches lists the
+// recognised pieces of the input in order.
+type QuickAddResult struct {
+	Input      string          `json:"input"`
+	Title      string          `json:"title"`
+	DueDate    *time.Time      `json:"due_date"`
+	HasTime    bool            `json:"has_time"`
+	Recurrence string          `json:"recurrence,omitempty"`
+	Labels     []string        `json:"labels"`
+	Priority   string          `json:"priority"`
+	Timezone   string          `json:"timezone"`
+	Matches    []QuickAddMatch `json:"matches"`
+}
+
+type QuickAddMatch struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+type QuickAddResponse struct {
+	Parsed *QuickAddResult `json:"parsed"`
+	Todo   *Todo           `json:"todo"`
+}
+
+// OAuthTokenResponse is a provider's reply to an authorization code
+// exchange. Some providers report errors with a 200 status and Error set.
+type OAuthTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	Scope            string `json:"scope"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// AuthorizationStart is where to send the user to sign in. State must come
+// back with the authorization code.
+type AuthorizationStart struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
 }
 
 type AuthRequest struct {
-	Code  string `json:"code" validate:"required"`
-	State string `json:"state,omitempty"`
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=256"`
 }
 
+// AuthResponse carries a short-lived access token and the refresh token
+// used to get the next one. ExpiresIn is the access token's lifetime in
+// seconds.
 type AuthResponse struct {
-	User  *User  `json:"user"`
-	Token string `json:"token"`
+	User         *User  `json:"user"`
+	Token        string `json:"token"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
 }
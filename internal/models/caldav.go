@@ -0,0 +1,39 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// AppPassword lets CalDAV clients, which only speak HTTP Basic auth, sign in
+// without the user's Google account. Only a hash is stored; Password is filled
+// in when it is created and is never returned again.
+type AppPassword struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	Password   string     `json:"password,omitempty" db:"-"`
+}
+
+type CreateAppPasswordRequest struct {
+	Name string `json:"name" validate:"max=255"`
+}
+
+func (r *CreateAppPasswordRequest) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+}
+
+// TodoTombstone records a deleted todo so CalDAV and offline sync clients can
+// learn about the deletion.
+type TodoTombstone struct {
+	ID             int       `json:"id" db:"id"`
+	UserID         int       `json:"user_id" db:"user_id"`
+	TodoID         int       `json:"todo_id" db:"todo_id"`
+	ICalUID        string    `json:"ical_uid" db:"ical_uid"`
+	ClientID       string    `json:"client_id,omitempty" db:"client_id"`
+	CalDAVResource string    `json:"-" db:"caldav_resource"`
+	ChangeSeq      int64     `json:"-" db:"change_seq"`
+	DeletedAt      time.Time `json:"deleted_at" db:"deleted_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	FeedComponentTodo  = "vtodo"
+	FeedComponentEvent = "vevent"
+	FeedComponentBoth  = "both"
+)
+
+// CalendarFeed is a secret ICS subscription URL for a user's todos with due
+// dates. Only a hash of the token is stored; URL is filled in when a token is
+// issued and is never returned again.
+type CalendarFeed struct {
+	ID               int        `json:"id" db:"id"`
+	UserID           int        `json:"user_id" db:"user_id"`
+	Name             string     `json:"name" db:"name"`
+	Label            string     `json:"label" db:"label"`
+	IncludeCompleted bool       `json:"include_completed" db:"include_completed"`
+	Component        string     `json:"component" db:"component"`
+	LastAccessedAt   *time.Time `json:"last_accessed_at" db:"last_accessed_at"`
+	RevokedAt        *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+	URL              string     `json:"url,omitempty" db:"-"`
+}
+
+type CreateFeedRequest struct {
+	Name             string `json:"name" validate:"max=255"`
+	Label            string `json:"label" validate:"max=255"`
+	IncludeCompleted bool   `json:"include_completed"`
+	Component        string `json:"component" validate:"omitempty,oneof=vtodo vevent both"`
+}
+
+func (r *CreateFeedRequest) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Label = strings.TrimSpace(r.Label)
+	r.Component = strings.ToLower(strings.TrimSpace(r.Component))
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Identity is an account with an external provider that the user can sign
+// in with. Subject is the provider's ID for the account.
+type Identity struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	Provider   string     `json:"provider" db:"provider"`
+	Subject    string     `json:"subject" db:"subject"`
+	Email      string     `json:"email" db:"email"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ExternalUser is the account a provider says the user signed in as.
+type ExternalUser struct {
+	Subject    string
+	Email      string
+	Name       string
+	PictureURL string
+}
+
+type StartLinkIdentityRequest struct {
+	Provider string `json:"provider" validate:"required,max=50"`
+}
+
+// LinkIdentityRequest finishes linking with the authorization code and state
+// the provider sent back.
+type LinkIdentityRequest struct {
+	Provider string `json:"provider" validate:"required,max=50"`
+	Code     string `json:"code" validate:"required,max=2048"`
+	State    string `json:"state" validate:"required,max=256"`
+}
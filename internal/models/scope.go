@@ -0,0 +1,15 @@
+package models
+
+// Scopes limit what a token may do. Each API route requires the read or the
+// write scope of its area, depending on the request method. Tokens from
+// signing in carry all scopes; personal access tokens carry the scopes they
+// were created with.
+const (
+	ScopeTodosRead    = "todos:read"
+	ScopeTodosWrite   = "todos:write"
+	ScopeAccountRead  = "account:read"
+	ScopeAccountWrite = "account:write"
+)
+
+// AllScopes lists every scope, in the order they are documented.
+var AllScopes = []string{ScopeTodosRead, ScopeTodosWrite, ScopeAccountRead, ScopeAccountWrite}
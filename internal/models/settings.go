@@ -0,0 +1,113 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	WeekStartMonday   = "monday"
+	WeekStartSunday   = "sunday"
+	WeekStartSaturday = "saturday"
+)
+
+// Orders the todo list can be sorted in.
+const (
+	SortCreatedAsc  = "created_asc"
+	SortCreatedDesc = "created_desc"
+	SortDueDate     = "due_date"
+	SortPriority    = "priority"
+	SortTitle       = "title"
+)
+
+// UserSettings are a user's preferences. Timezone and WeekStart are used
+// wherever the server works with calendar dates: quick add, templates and
+// time reports.
+type UserSettings struct {
+	Timezone      string               `json:"timezone"`
+	Locale        string               `json:"locale"`
+	WeekStart     string               `json:"week_start"`
+	DefaultSort   string               `json:"default_sort"`
+	Notifications NotificationSettings `json:"notifications"`
+}
+
+type NotificationSettings struct {
+	DueReminders bool `json:"due_reminders"`
+	// ReminderMinutes is how long before a todo is due the reminder is sent.
+	ReminderMinutes int  `json:"reminder_minutes"`
+	DailyDigest     bool `json:"daily_digest"`
+}
+
+// Location returns the user's time zone, falling back to UTC.
+func (s *UserSettings) Location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// FirstWeekday returns the day weeks start on.
+func (s *UserSettings) FirstWeekday() time.Weekday {
+	switch s.WeekStart {
+	case WeekStartSunday:
+		return time.Sunday
+	case WeekStartSaturday:
+		return time.Saturday
+	}
+	return time.Monday
+}
+
+// UpdateUserSettingsRequest changes the settings that are present and leaves
+// the others alone.
+type UpdateUserSettingsRequest struct {
+	Timezone      *string                            `json:"timezone" validate:"omitnil,min=1,timezone"`
+	Locale        *string                            `json:"locale" validate:"omitnil,bcp47_language_tag"`
+	WeekStart     *string                            `json:"week_start" validate:"omitnil,oneof=monday sunday saturday"`
+	DefaultSort   *string                            `json:"default_sort" validate:"omitnil,oneof=created_asc created_desc due_date priority title"`
+	Notifications *UpdateNotificationSettingsRequest `json:"notifications"`
+}
+
+type UpdateNotificationSettingsRequest struct {
+	DueReminders    *bool `json:"due_reminders"`
+	ReminderMinutes *int  `json:"reminder_minutes" validate:"omitnil,min=0,max=10080"`
+	DailyDigest     *bool `json:"daily_digest"`
+}
+
+func (r *UpdateUserSettingsRequest) Normalize() {
+	for _, value := range []*string{r.Timezone, r.Locale, r.WeekStart, r.DefaultSort} {
+		if value != nil {
+			*value = strings.TrimSpace(*value)
+		}
+	}
+	if r.WeekStart != nil {
+		*r.WeekStart = strings.ToLower(*r.WeekStart)
+	}
+}
+
+// Apply copies the fields present in the request onto settings.
+func (r *UpdateUserSettingsRequest) Apply(settings *UserSettings) {
+	if r.Timezone != nil {
+		settings.Timezone = *r.Timezone
+	}
+	if r.Locale != nil {
+		settings.Locale = *r.Locale
+	}
+	if r.WeekStart != nil {
+		settings.WeekStart = *r.WeekStart
+	}
+	if r.DefaultSort != nil {
+		settings.DefaultSort = *r.DefaultSort
+	}
+	if n := r.Notifications; n != nil {
+		if n.DueReminders != nil {
+			settings.Notifications.DueReminders = *n.DueReminders
+		}
+		if n.ReminderMinutes != nil {
+			settings.Notifications.ReminderMinutes = *n.ReminderMinutes
+		}
+		if n.DailyDigest != nil {
+			settings.Notifications.DailyDigest = *n.DailyDigest
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	SyncOpCreate = "create"
+	SyncOpUpdate = "update"
+	SyncOpDelete = "delete"
+
+	// SyncServerWins rejects a mutation when the todo changed on the server
+	// after the client last saw it; SyncClientWins applies it anyway.
+	SyncServerWins = "server_wins"
+	SyncClientWins = "client_wins"
+
+	SyncStatusApplied  = "applied"
+	SyncStatusConflict = "conflict"
+	SyncStatusNotFound = "not_found"
+	SyncStatusInvalid  = "invalid"
+)
+
+// SyncChanges is everything that changed after a sync token. When HasMore is
+// set the client should ask again with SyncToken straight away. Projects do
+// not exist yet; Labels is the full set of labels currently in use.
+type SyncChanges struct {
+	Todos     []Todo          `json:"todos"`
+	Deleted   []TodoTombstone `json:"deleted"`
+	Labels    []string        `json:"labels"`
+	SyncToken string          `json:"sync_token"`
+	HasMore   bool            `json:"has_more"`
+}
+
+// SyncTodo is the complete state of a todo as an offline client last saw it.
+type SyncTodo struct {
+	Title       string     `json:"title" validate:"required,max=255"`
+	Description string     `json:"description" validate:"max=10000"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    string     `json:"priority" validate:"omitempty,oneof=none low medium high"`
+	Recurrence  string     `json:"recurrence" validate:"max=500"`
+	Labels      []string   `json:"labels" validate:"max=50,dive,max=64"`
+}
+
+func (t *SyncTodo) Normalize() {
+	t.Title = strings.TrimSpace(t.Title)
+	t.Description = strings.TrimSpace(t.Description)
+	t.Priority = strings.ToLower(strings.TrimSpace(t.Priority))
+	t.Recurrence = strings.TrimSpace(t.Recurrence)
+}
+
+// SyncMutation is one change made offline. ClientID is the client-generated
+// ID of the todo; update and delete may use ID instead once the server ID is
+// known. BaseToken is the sync token at which the client last saw the todo.
+type SyncMutation struct {
+	Op        string    `json:"op"`
+	ClientID  string    `json:"client_id"`
+	ID        int       `json:"id"`
+	BaseToken string    `json:"base_token"`
+	Todo      *SyncTodo `json:"todo"`
+}
+
+// SyncPushRequest only checks the batch as a whole; each mutation is
+// validated on its own so one bad mutation does not fail the others.
+type SyncPushRequest struct {
+	Mutations  []SyncMutation `json:"mutations" validate:"max=500"`
+	OnConflict string         `json:"on_conflict" validate:"omitempty,oneof=server_wins client_wins"`
+}
+
+// SyncMutationResult reports what happened to one mutation. On conflict Todo
+// holds the server's current version.
+type SyncMutationResult struct {
+	ClientID string `json:"client_id"`
+	ID       int    `json:"id,omitempty"`
+	Status   string `json:"status"`
+	Todo     *Todo  `json:"todo,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type SyncPushResponse struct {
+	Results   []SyncMutationResult `json:"results"`
+	SyncToken string               `json:"sync_token"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// TemplateItem describes one todo created when a template is instantiated.
+// Due dates are stored relative to the anchor date supplied at that time.
+type TemplateItem struct {
+	Title         string   `json:"title" validate:"required,max=255"`
+	Description   string   `json:"description" validate:"max=10000"`
+	Priority      string   `json:"priority" validate:"omitempty,oneof=none low medium high"`
+	Recurrence    string   `json:"recurrence" validate:"max=500"`
+	Labels        []string `json:"labels" validate:"max=50,dive,max=64"`
+	DueOffsetDays *int     `json:"due_offset_days" validate:"omitempty,min=-3650,max=3650"`
+	DueTime       string   `json:"due_time,omitempty" validate:"omitempty,datetime=15:04"`
+}
+
+func (i *TemplateItem) Normalize() {
+	i.Title = strings.TrimSpace(i.Title)
+	i.Description = strings.TrimSpace(i.Description)
+	i.Priority = strings.ToLower(strings.TrimSpace(i.Priority))
+	i.Recurrence = strings.TrimSpace(i.Recurrence)
+	i.DueTime = strings.TrimSpace(i.DueTime)
+}
+
+// TodoTemplate is a named list of todos created together. Todos have no
+// projects or checklists, so a template with several items takes the place
+// of a project template and a checklist is written as separate items.
+type TodoTemplate struct {
+	ID        int            `json:"id" db:"id"`
+	UserID    int            `json:"user_id" db:"user_id"`
+	Name      string         `json:"name" db:"name"`
+	Items     []TemplateItem `json:"items" db:"items"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+type CreateTemplateRequest struct {
+	Name  string         `json:"name" validate:"required,max=255"`
+	Items []TemplateItem `json:"items" validate:"required,min=1,max=100,dive"`
+}
+
+func (r *CreateTemplateRequest) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	for i := range r.Items {
+		r.Items[i].Normalize()
+	}
+}
+
+type SaveTodoAsTemplateRequest struct {
+	Name string `json:"name" validate:"max=255"`
+	// AnchorDate is the date due offsets are measured from, formatted
+	// YYYY-MM-DD. Defaults to the day the todo was created.
+	AnchorDate string `json:"anchor_date" validate:"omitempty,datetime=2006-01-02"`
+	Timezone   string `json:"timezone" validate:"omitempty,timezone"`
+}
+
+func (r *SaveTodoAsTemplateRequest) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.AnchorDate = strings.TrimSpace(r.AnchorDate)
+	r.Timezone = strings.TrimSpace(r.Timezone)
+}
+
+type InstantiateTemplateRequest struct {
+	AnchorDate string `json:"anchor_date" validate:"omitempty,datetime=2006-01-02"`
+	Timezone   string `json:"timezone" validate:"omitempty,timezone"`
+}
+
+func (r *InstantiateTemplateRequest) Normalize() {
+	r.AnchorDate = strings.TrimSpace(r.AnchorDate)
+	r.Timezone = strings.TrimSpace(r.Timezone)
+}
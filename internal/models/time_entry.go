@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	TimeEntrySourceTimer  = "timer"
+	TimeEntrySourceManual = "manual"
+)
+
+type TimeEntry struct {
+	ID              int        `json:"id" db:"id"`
+	UserID          int        `json:"user_id" db:"user_id"`
+	TodoID          int        `json:"todo_id" db:"todo_id"`
+	StartedAt       time.Time  `json:"started_at" db:"started_at"`
+	EndedAt         *time.Time `json:"ended_at" db:"ended_at"`
+	DurationSeconds int64      `json:"duration_seconds" db:"duration_seconds"`
+	Note            string     `json:"note" db:"note"`
+	Source          string     `json:"source" db:"source"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (e *TimeEntry) Running() bool {
+	return e.EndedAt == nil
+}
+
+type StartTimerRequest struct {
+	Note string `json:"note" validate:"max=1000"`
+}
+
+func (r *StartTimerRequest) Normalize() {
+	r.Note = strings.TrimSpace(r.Note)
+}
+
+type CreateTimeEntryRequest struct {
+	StartedAt time.Time `json:"started_at" validate:"required"`
+	EndedAt   time.Time `json:"ended_at" validate:"required"`
+	Note      string    `json:"note" validate:"max=1000"`
+}
+
+func (r *CreateTimeEntryRequest) Normalize() {
+	r.Note = strings.TrimSpace(r.Note)
+}
+
+type TimerState struct {
+	Running *TimeEntry `json:"running"`
+	Stopped *TimeEntry `json:"stopped,omitempty"`
+}
+
+type TimeReportGroup struct {
+	Key          string `json:"key"`
+	Label        string `json:"label"`
+	TotalSeconds int64  `json:"total_seconds"`
+	Entries      int    `json:"entries"`
+}
+
+type TimeReport struct {
+	From         string            `json:"from"`
+	To           string            `json:"to"`
+	GroupBy      string            `json:"group_by"`
+	TotalSeconds int64             `json:"total_seconds"`
+	Groups       []TimeReportGroup `json:"groups"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// ExportFormat identifies documents produced by the JSON export so they can be
+// recognised and re-imported.
+const (
+	ExportFormat  = "todo-go/export"
+	ExportVersion = 1
+)
+
+type ExportDocument struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Schema     map[string]string `json:"schema,omitempty"`
+	Todos      []ExportTodo      `json:"todos"`
+}
+
+// ExportSchema documents the fields of ExportTodo inside every JSON export.
+var ExportSchema = map[string]string{
+	"id":          "integer; id of the todo on the exporting server, ignored on import",
+	"title":       "string; required, at most 255 characters",
+	"description": "string",
+	"completed":   "boolean",
+	"due_date":    "RFC 3339 timestamp or null",
+	"priority":    "one of none, low, medium, high",
+	"recurrence":  "RFC 5545 RRULE value, empty when the todo does not repeat",
+	"labels":      "array of lowercase strings",
+	"created_at":  "RFC 3339 timestamp",
+	"updated_at":  "RFC 3339 timestamp",
+}
+
+func NewExportTodo(todo *Todo) ExportTodo {
+	createdAt, updatedAt := todo.CreatedAt, todo.UpdatedAt
+	return ExportTodo{
+		ID:          todo.ID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Completed:   todo.Completed,
+		DueDate:     todo.DueDate,
+		Priority:    todo.Priority,
+		Recurrence:  todo.Recurrence,
+		Labels:      todo.Labels,
+		CreatedAt:   &createdAt,
+		UpdatedAt:   &updatedAt,
+	}
+}
+
+// TodoFilter narrows the todos streamed by exports, feeds and sync. Completed
+// todos are left out unless IncludeCompleted is set. ChangedAfter keeps only
+// todos written after that change sequence number.
+type TodoFilter struct {
+	IncludeCompleted bool
+	Label            string
+	DueFrom          *time.Time
+	DueTo            *time.Time
+	OnlyWithDueDate  bool
+	ChangedAfter     int64
+}
+
+type ExportTodo struct {
+	ID          int        `json:"id,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date"`
+	Priority    string     `json:"priority"`
+	Recurrence  string     `json:"recurrence"`
+	Labels      []string   `json:"labels"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportJob struct {
+	ID         string           `json:"id"`
+	UserID     int              `json:"user_id"`
+	Format     string           `json:"format"`
+	Status     string           `json:"status"`
+	Total      int              `json:"total"`
+	Processed  int              `json:"processed"`
+	Imported   int              `json:"imported"`
+	Failed     int              `json:"failed"`
+	Errors     []ImportRowError `json:"errors"`
+	Message    string           `json:"message,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
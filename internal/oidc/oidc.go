@@ -0,0 +1,256 @@
+// Package oidc signs users in with an OpenID Connect provider. The provider's
+// endpoints come from its discovery document, and ID tokens are verified
+// against its published keys, which are cached and refetched when the
+// provider rotates them.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidIDToken = errors.New("oidc: invalid ID token")
+
+const (
+	// metadataTTL is how long a discovery document is used before it is
+	// fetched again.
+	metadataTTL = 24 * time.Hour
+	// keysTTL is how long the provider's keys are cached. A token signed with
+	// an unknown key refetches them sooner, at most once per keysMinRefresh.
+	keysTTL        = time.Hour
+	keysMinRefresh = time.Minute
+
+	clockSkew = time.Minute
+)
+
+// Config configures a provider.
+type Config struct {
+	// IssuerURL is where the discovery document is served, below
+	// /.well-known/openid-configuration.
+	IssuerURL string
+	// ExtraIssuers are accepted in the iss claim besides the issuer from the
+	// discovery document.
+	ExtraIssuers []string
+	HTTPClient   *http.Client
+}
+
+// Metadata is the part of a discovery document this package uses.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDTokenClaims are the claims of a verified ID token.
+type IDTokenClaims struct {
+	Email           string `json:"email"`
+	EmailVerified   Bool   `json:"email_verified"`
+	Name            string `json:"name"`
+	Picture         string `json:"picture"`
+	AuthorizedParty string `json:"azp,omitempty"`
+	TenantID        string `json:"tid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Bool is a boolean claim that some providers send as a string.
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null":
+		*b = false
+	default:
+		return fmt.Errorf("oidc: invalid boolean %s", data)
+	}
+	return nil
+}
+
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu              sync.Mutex
+	metadata        *Metadata
+	metadataFetched time.Time
+	keys            map[string]any
+	keysFetched     time.Time
+}
+
+func NewProvider(cfg Config) *Provider {
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{
+		config: cfg,
+		client: client,
+	}
+}
+
+// Metadata returns the provider's discovery document.
+func (p *Provider) Metadata(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loadMetadata(ctx)
+}
+
+func (p *Provider) loadMetadata(ctx context.Context) (*Metadata, error) {
+	if p.metadata != nil && time.Since(p.metadataFetched) < metadataTTL {
+		return p.metadata, nil
+	}
+
+	discoveryURL := strings.TrimSuffix(p.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	var metadata Metadata
+	if err := p.getJSON(ctx, discoveryURL, &metadata); err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if metadata.Issuer == "" || metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is incomplete")
+	}
+
+	p.metadata = &metadata
+	p.metadataFetched = time.Now()
+	return p.metadata, nil
+}
+
+// VerifyIDToken checks the token's signature against the provider's keys
+// and its issuer, audience and lifetime, and returns its claims. Tokens that
+// fail a check are reported as ErrInvalidIDToken.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawToken, clientID string) (*IDTokenClaims, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithAudience(clientID),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	var claims IDTokenClaims
+	var keyErr error
+	token, err := parser.ParseWithClaims(rawToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := p.key(ctx, kid)
+		if err != nil {
+			keyErr = err
+		}
+		return key, err
+	})
+	if keyErr != nil && !errors.Is(keyErr, ErrInvalidIDToken) {
+		return nil, keyErr
+	}
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidIDToken
+	}
+
+	if !p.trustedIssuer(metadata, &claims) {
+		return nil, ErrInvalidIDToken
+	}
+	if claims.Subject == "" {
+		return nil, ErrInvalidIDToken
+	}
+	// A token issued to several clients must name this one as the party it
+	// was issued for.
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != clientID {
+		return nil, ErrInvalidIDToken
+	}
+	return &claims, nil
+}
+
+func (p *Provider) trustedIssuer(metadata *Metadata, claims *IDTokenClaims) bool {
+	issuer := claims.Issuer
+	if issuer == metadata.Issuer {
+		return true
+	}
+	// Multi-tenant providers such as Microsoft publish an issuer template
+	// that the tenant of the token is filled into.
+	if strings.Contains(metadata.Issuer, "{tenantid}") && claims.TenantID != "" &&
+		issuer == strings.ReplaceAll(metadata.Issuer, "{tenantid}", claims.TenantID) {
+		return true
+	}
+	for _, extra := range p.config.ExtraIssuers {
+		if issuer == extra {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the provider key with the ID, refetching the key set when the
+// cache has expired or does not know the key.
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := p.keys[kid]
+	fresh := time.Since(p.keysFetched) < keysTTL
+	if ok && fresh {
+		return key, nil
+	}
+	if !fresh || time.Since(p.keysFetched) >= keysMinRefresh {
+		if err := p.refreshKeys(ctx); err != nil {
+			// Keep using the cached keys while the provider is unreachable.
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		key, ok = p.keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, kid)
+	}
+	return key, nil
+}
+
+func (p *Provider) refreshKeys(ctx context.Context) error {
+	metadata, err := p.loadMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	var set jsonWebKeySet
+	if err := p.getJSON(ctx, metadata.JWKSURI, &set); err != nil {
+		return fmt.Errorf("oidc: fetching keys: %w", err)
+	}
+	p.keys = set.publicKeys()
+	p.keysFetched = time.Now()
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returned status %d: %s", url, resp.StatusCode, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
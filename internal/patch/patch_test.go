@@ -0,0 +1,272 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Examples from RFC 6902 Appendix A, plus the todo fields PATCH has to tell
+// apart.
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   error
+	}{
+		{
+			name:  "A.1 adding an object member",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:  `{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			name:  "A.2 adding an array element",
+			doc:   `{"foo": ["bar", "baz"]}`,
+			patch: `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			want:  `{"foo": ["bar", "qux", "baz"]}`,
+		},
+		{
+			name:  "A.3 removing an object member",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "remove", "path": "/baz"}]`,
+			want:  `{"foo": "bar"}`,
+		},
+		{
+			name:  "A.4 removing an array element",
+			doc:   `{"foo": ["bar", "qux", "baz"]}`,
+			patch: `[{"op": "remove", "path": "/foo/1"}]`,
+			want:  `{"foo": ["bar", "baz"]}`,
+		},
+		{
+			name:  "A.5 replacing a value",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:  `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			name:  "A.6 moving a value",
+			doc:   `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch: `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			want:  `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{
+			name:  "A.7 moving an array element",
+			doc:   `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch: `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			want:  `{"foo": ["all", "cows", "eat", "grass"]}`,
+		},
+		{
+			name: "A.8 testing a value: success",
+			doc:  `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			patch: `[
+				{"op": "test", "path": "/baz", "value": "qux"},
+				{"op": "test", "path": "/foo/1", "value": 2}
+			]`,
+			want: `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+		},
+		{
+			name:  "A.9 testing a value: error",
+			doc:   `{"baz": "qux"}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "A.10 adding a nested member object",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			want:  `{"foo": "bar", "child": {"grandchild": {}}}`,
+		},
+		{
+			name:  "A.11 ignoring unrecognized elements",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+			want:  `{"foo": "bar", "baz": "qux"}`,
+		},
+		{
+			name:  "A.12 adding to a nonexistent target",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			err:   ErrCannotApply,
+		},
+		{
+			name:  "A.14 ~ escape ordering",
+			doc:   `{"/": 9, "~1": 10}`,
+			patch: `[{"op": "test", "path": "/~01", "value": 10}]`,
+			want:  `{"/": 9, "~1": 10}`,
+		},
+		{
+			name:  "A.15 comparing strings and numbers",
+			doc:   `{"/": 9, "~1": 10}`,
+			patch: `[{"op": "test", "path": "/~01", "value": "10"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "A.16 adding an array value",
+			doc:   `{"foo": ["bar"]}`,
+			patch: `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			want:  `{"foo": ["bar", ["abc", "def"]]}`,
+		},
+		{
+			name:  "copying a value",
+			doc:   `{"title": "a", "labels": ["x"]}`,
+			patch: `[{"op": "copy", "from": "/labels", "path": "/tags"}, {"op": "add", "path": "/tags/-", "value": "y"}]`,
+			want:  `{"title": "a", "labels": ["x"], "tags": ["x", "y"]}`,
+		},
+		{
+			name:  "replacing a description with an empty string",
+			doc:   `{"title": "a", "description": "notes"}`,
+			patch: `[{"op": "replace", "path": "/description", "value": ""}]`,
+			want:  `{"title": "a", "description": ""}`,
+		},
+		{
+			name:  "replacing a due date with null",
+			doc:   `{"title": "a", "due_date": "2025-01-15T10:00:00Z"}`,
+			patch: `[{"op": "replace", "path": "/due_date", "value": null}]`,
+			want:  `{"title": "a", "due_date": null}`,
+		},
+		{
+			name:  "replace without a value",
+			doc:   `{"title": "a"}`,
+			patch: `[{"op": "replace", "path": "/title"}]`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "replacing a missing member",
+			doc:   `{"title": "a"}`,
+			patch: `[{"op": "replace", "path": "/description", "value": "b"}]`,
+			err:   ErrCannotApply,
+		},
+		{
+			name:  "moving a value into itself",
+			doc:   `{"a": {"b": 1}}`,
+			patch: `[{"op": "move", "from": "/a", "path": "/a/c"}]`,
+			err:   ErrCannotApply,
+		},
+		{
+			name:  "array index with a leading zero",
+			doc:   `{"foo": ["a", "b"]}`,
+			patch: `[{"op": "remove", "path": "/foo/01"}]`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "unknown operation",
+			doc:   `{"title": "a"}`,
+			patch: `[{"op": "frobnicate", "path": "/title"}]`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "not an array of operations",
+			doc:   `{"title": "a"}`,
+			patch: `{"op": "remove", "path": "/title"}`,
+			err:   ErrInvalidPatch,
+		},
+		{
+			name:  "a failing operation discards earlier ones",
+			doc:   `{"title": "a"}`,
+			patch: `[{"op": "replace", "path": "/title", "value": "b"}, {"op": "test", "path": "/title", "value": "a"}]`,
+			err:   ErrTestFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+// Examples from RFC 7396 Appendix A, plus the todo fields PATCH has to tell
+// apart.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   error
+	}{
+		{name: "replace member", doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "add member", doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{name: "null removes member", doc: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{name: "null removes one of several", doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{name: "scalar replaces array", doc: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "array replaces scalar", doc: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{name: "nested merge", doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{name: "arrays are not merged", doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{name: "array replaces array document", doc: `["a","b"]`, patch: `["c","d"]`, want: `["c","d"]`},
+		{name: "array replaces object document", doc: `{"a":"b"}`, patch: `["c"]`, want: `["c"]`},
+		{name: "null document", doc: `{"a":"foo"}`, patch: `null`, want: `null`},
+		{name: "string document", doc: `{"a":"foo"}`, patch: `"bar"`, want: `"bar"`},
+		{name: "existing null is kept", doc: `{"e":null}`, patch: `{"a":1}`, want: `{"e":null,"a":1}`},
+		{name: "array becomes object", doc: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{name: "nested null in new object", doc: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+		{
+			name:  "absent fields are left alone",
+			doc:   `{"title":"a","description":"notes","priority":"high"}`,
+			patch: `{"title":"b"}`,
+			want:  `{"title":"b","description":"notes","priority":"high"}`,
+		},
+		{
+			name:  "empty string is kept",
+			doc:   `{"title":"a","description":"notes"}`,
+			patch: `{"description":""}`,
+			want:  `{"title":"a","description":""}`,
+		},
+		{
+			name:  "null clears the due date",
+			doc:   `{"title":"a","due_date":"2025-01-15T10:00:00Z"}`,
+			patch: `{"due_date":null}`,
+			want:  `{"title":"a"}`,
+		},
+		{
+			name:  "numbers round-trip unchanged",
+			doc:   `{"version":12345678901234567890}`,
+			patch: `{"title":"a"}`,
+			want:  `{"version":12345678901234567890,"title":"a"}`,
+		},
+		{name: "malformed patch", doc: `{"a":"b"}`, patch: `{"a":`, err: ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue any
+	if err := unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("result is not JSON: %v", err)
+	}
+	if err := unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("bad expectation %q: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,721 @@
+// Package quickadd turns a single line of free-form text such as
+// "Pay rent every month on the 1st #finance !high" into structured todo
+// fields.
+package quickadd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	KindLabel      = "label"
+	KindPriority   = "priority"
+	KindRecurrence = "recurrence"
+	KindDate       = "date"
+	KindTime       = "time"
+)
+
+type Match struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+type Result struct {
+	Input      string     `json:"input"`
+	Title      string     `json:"title"`
+	DueDate    *time.Time `json:"due_date"`
+	HasTime    bool       `json:"has_time"`
+	Recurrence string     `json:"recurrence,omitempty"`
+	Labels     []string   `json:"labels"`
+	Priority   string     `json:"priority"`
+	Timezone   string     `json:"timezone"`
+	Matches    []Match    `json:"matches"`
+}
+
+var (
+	clockPattern   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	ordinalPattern = regexp.MustCompile(`^(\d{1,2})(st|nd|rd|th)?$`)
+	isoDatePattern = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+	"wednesday": time.Wednesday, "thursday": time.Thursday, "friday": time.Friday,
+	"saturday": time.Saturday,
+}
+
+var weekdayAbbrevs = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wed": time.Wednesday, "thu": time.Thursday, "thur": time.Thursday,
+	"thurs": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January, "feb": time.February,
+	"february": time.February, "mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April, "may": time.May, "jun": time.June,
+	"june": time.June, "jul": time.July, "july": time.July, "aug": time.August,
+	"august": time.August, "sep": time.September, "sept": time.September,
+	"september": time.September, "oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November, "dec": time.December,
+	"december": time.December,
+}
+
+var priorities = map[string]string{
+	"high": "high", "h": "high", "1": "high", "urgent": "high",
+	"medium": "medium", "med": "medium", "m": "medium", "2": "medium",
+	"low": "low", "l": "low", "3": "low",
+}
+
+// connectives are dropped from the title when they directly precede a
+// recognised date, time or recurrence ("due tomorrow", "at 5pm").
+var connectives = map[string]bool{
+	"on": true, "at": true, "by": true, "due": true, "starting": true, "from": true,
+}
+
+var rruleDays = map[time.Weekday]string{
+	time.Sunday: "SU", time.Monday: "MO", time.Tuesday: "TU", time.Wednesday: "WE",
+	time.Thursday: "TH", time.Friday: "FR", time.Saturday: "SA",
+}
+
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+type clock struct {
+	hour   int
+	minute int
+}
+
+type rule struct {
+	freq       string
+	interval   int
+	byDay      []time.Weekday
+	byMonthDay int
+	byMonth    time.Month
+}
+
+type parser struct {
+	words []string
+	lower []string
+	used  []bool
+	now   time.Time
+
+	labels   []string
+	priority string
+	date     *date
+	clock    *clock
+	rule     *rule
+	matches  []Match
+}
+
+// Parse interprets text relative to now in loc. Tokens that are not
+// recognised as labels, priority, dates, times or recurrence become the title.
+func Parse(text string, now time.Time, loc *time.Location) *Result {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	words := strings.Fields(text)
+	p := &parser{
+		words: words,
+		lower: make([]string, len(words)),
+		used:  make([]bool, len(words)),
+		now:   now.In(loc),
+	}
+	for i, word := range words {
+		p.lower[i] = strings.ToLower(strings.TrimRight(word, ",.;"))
+	}
+
+	for i := 0; i < len(words); {
+		if n := p.match(i); n > 0 {
+			i += n
+			continue
+		}
+		i++
+	}
+
+	result := &Result{
+		Input:    text,
+		Title:    p.title(),
+		Labels:   p.labels,
+		Priority: p.priority,
+		Timezone: loc.String(),
+		Matches:  p.matches,
+	}
+	if result.Labels == nil {
+		result.Labels = []string{}
+	}
+	if result.Priority == "" {
+		result.Priority = "none"
+	}
+	if result.Matches == nil {
+		result.Matches = []Match{}
+	}
+	if p.rule != nil {
+		result.Recurrence = p.rule.String()
+	}
+	if due := p.dueDate(); due != nil {
+		result.DueDate = due
+		result.HasTime = p.clock != nil
+	}
+
+	return result
+}
+
+func (p *parser) match(i int) int {
+	word := p.lower[i]
+
+	if strings.HasPrefix(word, "#") && len(word) > 1 {
+		p.labels = append(p.labels, word[1:])
+		p.consume(i, 1, KindLabel)
+		return 1
+	}
+
+	if strings.HasPrefix(word, "!") {
+		if priority, ok := priorities[word[1:]]; ok {
+			p.priority = priority
+			p.consume(i, 1, KindPriority)
+			return 1
+		}
+	}
+
+	if p.rule == nil {
+		if n := p.matchRecurrence(i); n > 0 {
+			p.consumeWithConnective(i, n, KindRecurrence)
+			return n
+		}
+	}
+
+	if p.date == nil {
+		if n := p.matchDate(i); n > 0 {
+			p.consumeWithConnective(i, n, KindDate)
+			return n
+		}
+	}
+
+	if p.clock == nil {
+		if n := p.matchClock(i); n > 0 {
+			p.consumeWithConnective(i, n, KindTime)
+			return n
+		}
+	}
+
+	return 0
+}
+
+func (p *parser) consume(i, n int, kind string) {
+	for j := i; j < i+n; j++ {
+		p.used[j] = true
+	}
+	p.matches = append(p.matches, Match{Kind: kind, Text: strings.Join(p.words[i:i+n], " ")})
+}
+
+func (p *parser) consumeWithConnective(i, n int, kind string) {
+	if i > 0 && !p.used[i-1] && connectives[p.lower[i-1]] {
+		i--
+		n++
+	}
+	p.consume(i, n, kind)
+}
+
+func (p *parser) word(i int) string {
+	if i < 0 || i >= len(p.lower) || p.used[i] {
+		return ""
+	}
+	return p.lower[i]
+}
+
+func (p *parser) title() string {
+	var parts []string
+	for i, word := range p.words {
+		if !p.used[i] {
+			parts = append(parts, word)
+		}
+	}
+	for len(parts) > 0 && connectives[strings.ToLower(parts[len(parts)-1])] {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+func (p *parser) matchRecurrence(i int) int {
+	switch p.word(i) {
+	case "daily":
+		p.rule = &rule{freq: "DAILY"}
+		return 1
+	case "weekly":
+		p.rule = &rule{freq: "WEEKLY"}
+		return 1 + p.matchRuleQualifier(i+1)
+	case "monthly":
+		p.rule = &rule{freq: "MONTHLY"}
+		return 1 + p.matchRuleQualifier(i+1)
+	case "yearly", "annually":
+		p.rule = &rule{freq: "YEARLY"}
+		return 1
+	case "every":
+	default:
+		return 0
+	}
+
+	j := i + 1
+	interval := 1
+	if p.word(j) == "other" {
+		interval = 2
+		j++
+	} else if n, err := strconv.Atoi(p.word(j)); err == nil && n > 0 {
+		interval = n
+		j++
+	}
+
+	word := p.word(j)
+	if freq, ok := unitFrequency(word); ok {
+		p.rule = &rule{freq: freq, interval: interval}
+		return j - i + 1 + p.matchRuleQualifier(j+1)
+	}
+
+	if interval != 1 {
+		return 0
+	}
+
+	if word == "weekday" || word == "weekdays" {
+		p.rule = &rule{freq: "WEEKLY", byDay: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}}
+		return j - i + 1
+	}
+
+	if days, n := p.matchWeekdayList(j); n > 0 {
+		p.rule = &rule{freq: "WEEKLY", byDay: days}
+		return j - i + n
+	}
+
+	if day, ok := parseOrdinal(word, true); ok {
+		p.rule = &rule{freq: "MONTHLY", byMonthDay: day}
+		return j - i + 1
+	}
+
+	if d, n := p.matchMonthDay(j); n > 0 {
+		p.rule = &rule{freq: "YEARLY", byMonth: d.month, byMonthDay: d.day}
+		return j - i + n
+	}
+
+	return 0
+}
+
+// matchRuleQualifier consumes "on the 1st" after a monthly rule or
+// "on monday" after a weekly one.
+func (p *parser) matchRuleQualifier(i int) int {
+	j := i
+	if p.word(j) == "on" {
+		j++
+	}
+
+	switch p.rule.freq {
+	case "MONTHLY":
+		if p.word(j) == "the" {
+			j++
+		}
+		if day, ok := parseOrdinal(p.word(j), j > i); ok {
+			p.rule.byMonthDay = day
+			return j - i + 1
+		}
+	case "WEEKLY":
+		if days, n := p.matchWeekdayList(j); n > 0 {
+			p.rule.byDay = days
+			return j - i + n
+		}
+	}
+
+	return 0
+}
+
+func (p *parser) matchWeekdayList(i int) ([]time.Weekday, int) {
+	var days []time.Weekday
+	j := i
+	for {
+		day, ok := parseWeekday(p.word(j), true)
+		if !ok {
+			break
+		}
+		days = append(days, day)
+		j++
+		if p.word(j) == "and" || p.word(j) == "&" {
+			if _, next := parseWeekday(p.word(j+1), true); next {
+				j++
+			}
+		}
+	}
+	return days, j - i
+}
+
+func (p *parser) matchDate(i int) int {
+	today := date{year: p.now.Year(), month: p.now.Month(), day: p.now.Day()}
+	word := p.word(i)
+	precededByConnective := i > 0 && connectives[p.word(i-1)]
+
+	switch word {
+	case "today", "tonight":
+		p.date = &today
+		if word == "tonight" && p.clock == nil {
+			p.clock = &clock{hour: 20}
+		}
+		return 1
+	case "tomorrow", "tmr", "tmrw":
+		d := today.addDays(1)
+		p.date = &d
+		return 1
+	case "next":
+		switch next := p.word(i + 1); next {
+		case "week":
+			d := today.addDays(daysUntil(p.now.Weekday(), time.Monday, 1))
+			p.date = &d
+			return 2
+		case "month":
+			d := date{year: today.year, month: today.month + 1, day: 1}.normalize()
+			p.date = &d
+			return 2
+		case "year":
+			d := date{year: today.year + 1, month: time.January, day: 1}
+			p.date = &d
+			return 2
+		default:
+			if day, ok := parseWeekday(next, true); ok {
+				d := today.addDays(daysUntil(p.now.Weekday(), day, 1))
+				p.date = &d
+				return 2
+			}
+		}
+		return 0
+	case "in":
+		amount := 0
+		next := p.word(i + 1)
+		if next == "a" || next == "an" || next == "one" {
+			amount = 1
+		} else if n, err := strconv.Atoi(next); err == nil && n > 0 {
+			amount = n
+		}
+		if amount == 0 {
+			return 0
+		}
+		var d date
+		switch strings.TrimSuffix(p.word(i+2), "s") {
+		case "day":
+			d = today.addDays(amount)
+		case "week":
+			d = today.addDays(7 * amount)
+		case "month":
+			d = date{year: today.year, month: today.month + time.Month(amount), day: 1}.normalize()
+			d.day = min(today.day, daysIn(d.month, d.year))
+		default:
+			return 0
+		}
+		p.date = &d
+		return 3
+	case "the":
+		if day, ok := parseOrdinal(p.word(i+1), precededByConnective); ok {
+			d := nextMonthDay(today, day)
+			p.date = &d
+			return 2
+		}
+		return 0
+	}
+
+	if day, ok := parseWeekday(word, precededByConnective); ok {
+		d := today.addDays(daysUntil(p.now.Weekday(), day, 0))
+		p.date = &d
+		return 1
+	}
+
+	if m := isoDatePattern.FindStringSubmatch(word); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		if month >= 1 && month <= 12 && day >= 1 && day <= daysIn(time.Month(month), year) {
+			p.date = &date{year: year, month: time.Month(month), day: day}
+			return 1
+		}
+		return 0
+	}
+
+	if d, n := p.matchMonthDay(i); n > 0 {
+		if year, err := strconv.Atoi(p.word(i + n)); err == nil && year >= 1970 && year <= 9999 {
+			d.year = year
+			n++
+		} else {
+			d = nextMonthAndDay(today, d.month, d.day)
+		}
+		p.date = &d
+		return n
+	}
+
+	if precededByConnective {
+		if day, ok := parseOrdinal(word, false); ok && word != strconv.Itoa(day) {
+			d := nextMonthDay(today, day)
+			p.date = &d
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// matchMonthDay recognises "jan 5", "january 5th" and "5th of january".
+func (p *parser) matchMonthDay(i int) (date, int) {
+	if month, ok := months[p.word(i)]; ok {
+		if day, ok := parseOrdinal(p.word(i+1), true); ok && day <= daysIn(month, 2000) {
+			return date{month: month, day: day}, 2
+		}
+		return date{}, 0
+	}
+
+	if day, ok := parseOrdinal(p.word(i), true); ok {
+		j := i + 1
+		if p.word(j) == "of" {
+			j++
+		}
+		if month, ok := months[p.word(j)]; ok && day <= daysIn(month, 2000) {
+			return date{month: month, day: day}, j - i + 1
+		}
+	}
+
+	return date{}, 0
+}
+
+func (p *parser) matchClock(i int) int {
+	word := p.word(i)
+	explicit := i > 0 && p.word(i-1) == "at"
+
+	switch word {
+	case "noon", "midday":
+		p.clock = &clock{hour: 12}
+		return 1
+	case "midnight":
+		p.clock = &clock{hour: 0}
+		return 1
+	}
+
+	n := 1
+	if next := p.word(i + 1); (next == "am" || next == "pm") && !strings.HasSuffix(word, "am") && !strings.HasSuffix(word, "pm") {
+		word += next
+		n = 2
+	}
+
+	m := clockPattern.FindStringSubmatch(word)
+	if m == nil {
+		return 0
+	}
+	// A bare number is only a time when introduced by "at".
+	if m[2] == "" && m[3] == "" && !explicit {
+		return 0
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	// "at 3" means the afternoon; nobody schedules a todo for 3am by default.
+	if m[2] == "" && m[3] == "" && hour >= 1 && hour <= 7 {
+		hour += 12
+	}
+	switch m[3] {
+	case "am":
+		if hour < 1 || hour > 12 {
+			return 0
+		}
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 1 || hour > 12 {
+			return 0
+		}
+		if hour != 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0
+	}
+
+	p.clock = &clock{hour: hour, minute: minute}
+	return n
+}
+
+func (p *parser) dueDate() *time.Time {
+	loc := p.now.Location()
+	today := date{year: p.now.Year(), month: p.now.Month(), day: p.now.Day()}
+
+	var d date
+	switch {
+	case p.date != nil:
+		d = *p.date
+	case p.rule != nil:
+		d = p.rule.firstOccurrence(today)
+	case p.clock != nil:
+		d = today
+		if !time.Date(d.year, d.month, d.day, p.clock.hour, p.clock.minute, 0, 0, loc).After(p.now) {
+			d = today.addDays(1)
+		}
+	default:
+		return nil
+	}
+
+	hour, minute := 0, 0
+	if p.clock != nil {
+		hour, minute = p.clock.hour, p.clock.minute
+	}
+
+	due := time.Date(d.year, d.month, d.day, hour, minute, 0, 0, loc)
+	return &due
+}
+
+func (r *rule) firstOccurrence(today date) date {
+	switch r.freq {
+	case "WEEKLY":
+		if len(r.byDay) == 0 {
+			return today
+		}
+		weekday := today.time().Weekday()
+		best := 7
+		for _, day := range r.byDay {
+			if ahead := daysUntil(weekday, day, 0); ahead < best {
+				best = ahead
+			}
+		}
+		return today.addDays(best)
+	case "MONTHLY":
+		if r.byMonthDay == 0 {
+			return today
+		}
+		return nextMonthDay(today, r.byMonthDay)
+	case "YEARLY":
+		if r.byMonth == 0 {
+			return today
+		}
+		return nextMonthAndDay(today, r.byMonth, r.byMonthDay)
+	}
+	return today
+}
+
+// String renders the rule as an RFC 5545 RRULE value.
+func (r *rule) String() string {
+	parts := []string{"FREQ=" + r.freq}
+	if r.interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.interval))
+	}
+	if len(r.byDay) > 0 {
+		days := make([]string, len(r.byDay))
+		for i, day := range r.byDay {
+			days[i] = rruleDays[day]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.byMonth != 0 {
+		parts = append(parts, "BYMONTH="+strconv.Itoa(int(r.byMonth)))
+	}
+	if r.byMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.byMonthDay))
+	}
+	return strings.Join(parts, ";")
+}
+
+func unitFrequency(word string) (string, bool) {
+	switch strings.TrimSuffix(word, "s") {
+	case "day":
+		return "DAILY", true
+	case "week":
+		return "WEEKLY", true
+	case "month":
+		return "MONTHLY", true
+	case "year":
+		return "YEARLY", true
+	}
+	return "", false
+}
+
+func parseWeekday(word string, allowAbbrev bool) (time.Weekday, bool) {
+	if day, ok := weekdays[word]; ok {
+		return day, true
+	}
+	if day, ok := weekdays[strings.TrimSuffix(word, "s")]; ok {
+		return day, true
+	}
+	if allowAbbrev {
+		day, ok := weekdayAbbrevs[word]
+		return day, ok
+	}
+	return 0, false
+}
+
+// parseOrdinal accepts "1st", "22nd" and, when allowBare is set, plain
+// numbers between 1 and 31.
+func parseOrdinal(word string, allowBare bool) (int, bool) {
+	m := ordinalPattern.FindStringSubmatch(word)
+	if m == nil || (m[2] == "" && !allowBare) {
+		return 0, false
+	}
+	day, _ := strconv.Atoi(m[1])
+	if day < 1 || day > 31 {
+		return 0, false
+	}
+	return day, true
+}
+
+func daysUntil(from, to time.Weekday, minDays int) int {
+	ahead := (int(to) - int(from) + 7) % 7
+	if ahead < minDays {
+		ahead += 7
+	}
+	return ahead
+}
+
+// nextMonthDay returns the first date on or after from that falls on the given
+// day of the month, skipping months that are too short.
+func nextMonthDay(from date, day int) date {
+	year, month := from.year, from.month
+	if day < from.day {
+		month++
+	}
+	for {
+		d := date{year: year, month: month, day: 1}.normalize()
+		if day <= daysIn(d.month, d.year) {
+			d.day = day
+			return d
+		}
+		year, month = d.year, d.month+1
+	}
+}
+
+func nextMonthAndDay(from date, month time.Month, day int) date {
+	year := from.year
+	if month < from.month || (month == from.month && day < from.day) {
+		year++
+	}
+	for day > daysIn(month, year) {
+		year++
+	}
+	return date{year: year, month: month, day: day}
+}
+
+func daysIn(month time.Month, year int) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func (d date) time() time.Time {
+	return time.Date(d.year, d.month, d.day, 0, 0, 0, 0, time.UTC)
+}
+
+func (d date) addDays(n int) date {
+	t := d.time().AddDate(0, 0, n)
+	return date{year: t.Year(), month: t.Month(), day: t.Day()}
+}
+
+func (d date) normalize() date {
+	t := d.time()
+	return date{year: t.Year(), month: t.Month(), day: t.Day()}
+}
@@ -0,0 +1,193 @@
+package quickadd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	// A Wednesday morning.
+	now := time.Date(2025, time.January, 15, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		input      string
+		title      string
+		due        string // "2006-01-02 15:04", empty for no due date
+		hasTime    bool
+		recurrence string
+		labels     []string
+		priority   string
+	}{
+		{
+			name:       "monthly recurrence with label and priority",
+			input:      "Pay rent every month on the 1st #finance !high",
+			title:      "Pay rent",
+			due:        "2025-02-01 00:00",
+			recurrence: "FREQ=MONTHLY;BYMONTHDAY=1",
+			labels:     []string{"finance"},
+			priority:   "high",
+		},
+		{
+			name:    "relative day and time",
+			input:   "Call mom tomorrow at 5pm",
+			title:   "Call mom",
+			due:     "2025-01-16 17:00",
+			hasTime: true,
+		},
+		{
+			name:     "next weekday and numeric priority",
+			input:    "Submit report next friday !2",
+			title:    "Submit report",
+			due:      "2025-01-17 00:00",
+			priority: "medium",
+		},
+		{
+			name:       "weekdays with a time",
+			input:      "Standup every weekday at 11:30 #work",
+			title:      "Standup",
+			due:        "2025-01-15 11:30",
+			hasTime:    true,
+			recurrence: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+			labels:     []string{"work"},
+		},
+		{
+			name:       "interval",
+			input:      "Water plants every other week",
+			title:      "Water plants",
+			due:        "2025-01-15 00:00",
+			recurrence: "FREQ=WEEKLY;INTERVAL=2",
+		},
+		{
+			name:       "yearly on a date",
+			input:      "Taxes every april 15",
+			title:      "Taxes",
+			due:        "2025-04-15 00:00",
+			recurrence: "FREQ=YEARLY;BYMONTH=4;BYMONTHDAY=15",
+		},
+		{
+			name:  "month and day already passed this year",
+			input: "Dentist jan 5",
+			title: "Dentist",
+			due:   "2026-01-05 00:00",
+		},
+		{
+			name:    "ISO date with noon",
+			input:   "Ship it on 2025-03-10 at noon",
+			title:   "Ship it",
+			due:     "2025-03-10 12:00",
+			hasTime: true,
+		},
+		{
+			name:  "in two weeks",
+			input: "Renew passport in 2 weeks",
+			title: "Renew passport",
+			due:   "2025-01-29 00:00",
+		},
+		{
+			name:    "time already passed today moves to tomorrow",
+			input:   "Buy milk at 9",
+			title:   "Buy milk",
+			due:     "2025-01-16 09:00",
+			hasTime: true,
+		},
+		{
+			name:    "bare afternoon hour",
+			input:   "Meet Sam at 3",
+			title:   "Meet Sam",
+			due:     "2025-01-15 15:00",
+			hasTime: true,
+		},
+		{
+			name:    "tonight",
+			input:   "Take out trash tonight",
+			title:   "Take out trash",
+			due:     "2025-01-15 20:00",
+			hasTime: true,
+		},
+		{
+			name:  "numbers without a connective stay in the title",
+			input: "Read chapter 5",
+			title: "Read chapter 5",
+		},
+		{
+			name:     "unknown priority stays in the title",
+			input:    "Fix bug !asap #dev #urgent",
+			title:    "Fix bug !asap",
+			labels:   []string{"dev", "urgent"},
+			priority: "none",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.input, now, time.UTC)
+
+			if got.Title != tt.title {
+				t.Errorf("title = %q, want %q", got.Title, tt.title)
+			}
+			if due := formatDue(got.DueDate); due != tt.due {
+				t.Errorf("due = %q, want %q", due, tt.due)
+			}
+			if got.HasTime != tt.hasTime {
+				t.Errorf("has_time = %v, want %v", got.HasTime, tt.hasTime)
+			}
+			if got.Recurrence != tt.recurrence {
+				t.Errorf("recurrence = %q, want %q", got.Recurrence, tt.recurrence)
+			}
+			labels := tt.labels
+			if labels == nil {
+				labels = []string{}
+			}
+			if !reflect.DeepEqual(got.Labels, labels) {
+				t.Errorf("labels = %v, want %v", got.Labels, labels)
+			}
+			priority := tt.priority
+			if priority == "" {
+				priority = "none"
+			}
+			if got.Priority != priority {
+				t.Errorf("priority = %q, want %q", got.Priority, priority)
+			}
+		})
+	}
+}
+
+func TestParseUsesLocation(t *testing.T) {
+	// 10:00 UTC is still 05:00 the same day in New York.
+	now := time.Date(2025, time.January, 15, 10, 0, 0, 0, time.UTC)
+	loc := time.FixedZone("EST", -5*60*60)
+
+	got := Parse("Call the bank tomorrow at 9am", now, loc)
+
+	want := time.Date(2025, time.January, 16, 9, 0, 0, 0, loc)
+	if got.DueDate == nil || !got.DueDate.Equal(want) {
+		t.Fatalf("due = %v, want %v", got.DueDate, want)
+	}
+	if got.Timezone != "EST" {
+		t.Errorf("timezone = %q, want %q", got.Timezone, "EST")
+	}
+}
+
+func TestParseMatches(t *testing.T) {
+	now := time.Date(2025, time.January, 15, 10, 0, 0, 0, time.UTC)
+
+	got := Parse("Pay rent every month on the 1st #finance !high", now, time.UTC)
+
+	want := []Match{
+		{Kind: KindRecurrence, Text: "every month on the 1st"},
+		{Kind: KindLabel, Text: "#finance"},
+		{Kind: KindPriority, Text: "!high"},
+	}
+	if !reflect.DeepEqual(got.Matches, want) {
+		t.Errorf("matches = %+v, want %+v", got.Matches, want)
+	}
+}
+
+func formatDue(due *time.Time) string {
+	if due == nil {
+		return ""
+	}
+	return due.Format("2006-01-02 15:04")
+}
@@ -22,7 +22,15 @@ func NewRateLimiter(client *redis.Client, limiter string) *RateLimiter {
 }
 
 func (r *RateLimiter) Allow(ctx context.Context, userID int, maxRequests int, window time.Duration) (bool, int, int64, error) {
-	key := fmt.Sprintf("ratelimit:user:%d", userID)
+	return r.allow(ctx, fmt.Sprintf("ratelimit:user:%d", userID), maxRequests, window)
+}
+
+// AllowIP limits unauthenticated requests by the client's IP address.
+func (r *RateLimiter) AllowIP(ctx context.Context, ip string, maxRequests int, window time.Duration) (bool, int, int64, error) {
+	return r.allow(ctx, "ratelimit:ip:"+ip, maxRequests, window)
+}
+
+func (r *RateLimiter) allow(ctx context.Context, key string, maxRequests int, window time.Duration) (bool, int, int64, error) {
 	windowInSeconds := int64(window.Seconds())
 
 	switch r.limiter {
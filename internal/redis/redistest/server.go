@@ -0,0 +1,392 @@
+// Package redistest runs an in-memory stand-in for Redis so that code built
+// on redis.Client can be tested without a server. It speaks enough of the
+// RESP protocol for go-redis and implements the string, set, key and
+// transaction commands this repository uses.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/redis"
+)
+
+// Server is an in-memory Redis listening on a local port.
+type Server struct {
+	listener net.Listener
+
+	mu     sync.Mutex
+	values map[string]any // string or map[string]struct{}
+	expiry map[string]time.Time
+	now    func() time.Time
+}
+
+// NewClient starts a Server and returns a client connected to it. Both are
+// closed when the test ends.
+func NewClient(t testing.TB) (*redis.Client, *Server) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("redistest: listen: %v", err)
+	}
+	s := &Server{
+		listener: listener,
+		values:   map[string]any{},
+		expiry:   map[string]time.Time{},
+		now:      time.Now,
+	}
+	go s.serve()
+
+	password := ""
+	client, err := redis.NewClient(listener.Addr().String(), &password, 0)
+	if err != nil {
+		listener.Close()
+		t.Fatalf("redistest: %v", err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		listener.Close()
+	})
+	return client, s
+}
+
+// Keys returns the keys currently stored.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key := range s.values {
+		if s.alive(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// TTL returns how long the key has left to live, or 0 if it has no expiry
+// or does not exist.
+func (s *Server) TTL(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if at, ok := s.expiry[key]; ok && s.alive(key) {
+		return at.Sub(s.now())
+	}
+	return 0
+}
+
+// FastForward moves the server's clock, expiring keys as it goes.
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now
+	s.now = func() time.Time { return now().Add(d) }
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		switch name := strings.ToUpper(args[0]); {
+		case name == "MULTI":
+			inMulti, queued = true, nil
+			writeSimple(w, "OK")
+		case name == "EXEC":
+			fmt.Fprintf(w, "*%d\r\n", len(queued))
+			s.mu.Lock()
+			for _, cmd := range queued {
+				s.exec(w, cmd)
+			}
+			s.mu.Unlock()
+			inMulti, queued = false, nil
+		case name == "DISCARD":
+			inMulti, queued = false, nil
+			writeSimple(w, "OK")
+		case inMulti:
+			queued = append(queued, args)
+			writeSimple(w, "QUEUED")
+		default:
+			s.mu.Lock()
+			s.exec(w, args)
+			s.mu.Unlock()
+		}
+
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// exec runs one command with s.mu held.
+func (s *Server) exec(w *bufio.Writer, args []string) {
+	name, args := strings.ToUpper(args[0]), args[1:]
+	switch name {
+	case "PING":
+		writeSimple(w, "PONG")
+	case "SELECT":
+		writeSimple(w, "OK")
+	case "GET":
+		if value, ok := s.getString(args[0]); ok {
+			writeBulk(w, value)
+		} else {
+			writeNull(w)
+		}
+	case "GETDEL":
+		value, ok := s.getString(args[0])
+		s.delete(args[0])
+		if ok {
+			writeBulk(w, value)
+		} else {
+			writeNull(w)
+		}
+	case "SET":
+		s.set(w, args)
+	case "SETNX":
+		if s.alive(args[0]) {
+			writeInt(w, 0)
+			return
+		}
+		s.values[args[0]] = args[1]
+		delete(s.expiry, args[0])
+		writeInt(w, 1)
+	case "DEL":
+		n := 0
+		for _, key := range args {
+			if s.alive(key) {
+				n++
+			}
+			s.delete(key)
+		}
+		writeInt(w, n)
+	case "EXISTS":
+		n := 0
+		for _, key := range args {
+			if s.alive(key) {
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "EXPIRE":
+		seconds, err := strconv.Atoi(args[1])
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			return
+		}
+		if !s.alive(args[0]) {
+			writeInt(w, 0)
+			return
+		}
+		s.expiry[args[0]] = s.now().Add(time.Duration(seconds) * time.Second)
+		writeInt(w, 1)
+	case "SADD":
+		set, ok := s.getSet(args[0], true)
+		if !ok {
+			writeWrongType(w)
+			return
+		}
+		n := 0
+		for _, member := range args[1:] {
+			if _, exists := set[member]; !exists {
+				set[member] = struct{}{}
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "SMEMBERS":
+		set, ok := s.getSet(args[0], false)
+		if !ok {
+			writeWrongType(w)
+			return
+		}
+		fmt.Fprintf(w, "*%d\r\n", len(set))
+		for member := range set {
+			writeBulk(w, member)
+		}
+	default:
+		writeError(w, "ERR unknown command '"+strings.ToLower(name)+"'")
+	}
+}
+
+// set implements SET key value [NX|XX] [EX seconds|PX milliseconds|KEEPTTL].
+func (s *Server) set(w *bufio.Writer, args []string) {
+	key, value := args[0], args[1]
+	var nx, xx, keepTTL bool
+	var ttl time.Duration
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				writeError(w, "ERR syntax error")
+				return
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				writeError(w, "ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Second
+			if strings.ToUpper(args[i]) == "PX" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+
+	exists := s.alive(key)
+	if (nx && exists) || (xx && !exists) {
+		writeNull(w)
+		return
+	}
+	s.values[key] = value
+	switch {
+	case ttl > 0:
+		s.expiry[key] = s.now().Add(ttl)
+	case !keepTTL:
+		delete(s.expiry, key)
+	}
+	writeSimple(w, "OK")
+}
+
+// alive reports whether key exists, dropping it if it has expired.
+func (s *Server) alive(key string) bool {
+	if at, ok := s.expiry[key]; ok && !s.now().Before(at) {
+		s.delete(key)
+	}
+	_, ok := s.values[key]
+	return ok
+}
+
+func (s *Server) delete(key string) {
+	delete(s.values, key)
+	delete(s.expiry, key)
+}
+
+func (s *Server) getString(key string) (string, bool) {
+	if !s.alive(key) {
+		return "", false
+	}
+	value, ok := s.values[key].(string)
+	return value, ok
+}
+
+func (s *Server) getSet(key string, create bool) (map[string]struct{}, bool) {
+	if !s.alive(key) {
+		set := map[string]struct{}{}
+		if create {
+			s.values[key] = set
+		}
+		return set, true
+	}
+	set, ok := s.values[key].(map[string]struct{})
+	return set, ok
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, errors.New("redistest: expected an array")
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 {
+		return nil, errors.New("redistest: bad array length")
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, errors.New("redistest: expected a bulk string")
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, errors.New("redistest: bad bulk string length")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func writeError(w *bufio.Writer, s string) {
+	w.WriteString("-" + s + "\r\n")
+}
+
+func writeWrongType(w *bufio.Writer) {
+	writeError(w, "WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+func writeInt(w *bufio.Writer, n int) {
+	w.WriteString(":" + strconv.Itoa(n) + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func writeNull(w *bufio.Writer) {
+	w.WriteString("$-1\r\n")
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AppPasswordRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAppPasswordRepository(db *pgxpool.Pool) *AppPasswordRepository {
+	return &AppPasswordRepository{db: db}
+}
+
+func (r *AppPasswordRepository) CreateAppPassword(ctx context.Context, password *models.AppPassword, passwordHash string) error {
+	query := `
+		INSERT INTO app_passwords (user_id, name, password_hash, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(ctx, query, password.UserID, password.Name, passwordHash).Scan(&password.ID, &password.CreatedAt)
+}
+
+func (r *AppPasswordRepository) GetAppPasswords(ctx context.Context, userID int) ([]models.AppPassword, error) {
+	query := `
+		SELECT id, user_id, name, last_used_at, created_at
+		FROM app_passwords
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY id`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passwords := []models.AppPassword{}
+	for rows.Next() {
+		var p models.AppPassword
+		if errScan := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.LastUsedAt, &p.CreatedAt); errScan != nil {
+			return nil, errScan
+		}
+		passwords = append(passwords, p)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return passwords, nil
+}
+
+// GetUserIDByPasswordHash returns the owner of an active app password and
+// records the time it was used.
+func (r *AppPasswordRepository) GetUserIDByPasswordHash(ctx context.Context, passwordHash string) (int, error) {
+	query := `
+		UPDATE app_passwords
+		SET last_used_at = NOW()
+		WHERE password_hash = $1 AND revoked_at IS NULL
+		RETURNING user_id`
+
+	var userID int
+	if err := r.db.QueryRow(ctx, query, passwordHash).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, sql.ErrNoRows
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *AppPasswordRepository) RevokeAppPassword(ctx context.Context, id, userID int) error {
+	query := `
+		UPDATE app_passwords
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const feedColumns = `id, user_id, name, label, include_completed, component, last_accessed_at, revoked_at, created_at, updated_at`
+
+type FeedRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFeedRepository(db *pgxpool.Pool) *FeedRepository {
+	return &FeedRepository{db: db}
+}
+
+func scanFeed(row pgx.Row, feed *models.CalendarFeed) error {
+	return row.Scan(
+		&feed.ID,
+		&feed.UserID,
+		&feed.Name,
+		&feed.Label,
+		&feed.IncludeCompleted,
+		&feed.Component,
+		&feed.LastAccessedAt,
+		&feed.RevokedAt,
+		&feed.CreatedAt,
+		&feed.UpdatedAt,
+	)
+}
+
+func (r *FeedRepository) CreateFeed(ctx context.Context, feed *models.CalendarFeed, tokenHash string) error {
+	query := `
+		INSERT INTO calendar_feeds (user_id, name, token_hash, label, include_completed, component, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING ` + feedColumns
+
+	return scanFeed(r.db.QueryRow(ctx, query, feed.UserID, feed.Name, tokenHash, feed.Label, feed.IncludeCompleted, feed.Component), feed)
+}
+
+func (r *FeedRepository) GetFeeds(ctx context.Context, userID int) ([]models.CalendarFeed, error) {
+	query := `SELECT ` + feedColumns + `
+		FROM calendar_feeds
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY id`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []models.CalendarFeed{}
+	for rows.Next() {
+		var feed models.CalendarFeed
+		if errScan := scanFeed(rows, &feed); errScan != nil {
+			return nil, errScan
+		}
+		feeds = append(feeds, feed)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return feeds, nil
+}
+
+// GetFeedByTokenHash returns an active feed and records the access time.
+func (r *FeedRepository) GetFeedByTokenHash(ctx context.Context, tokenHash string) (*models.CalendarFeed, error) {
+	query := `
+		UPDATE calendar_feeds
+		SET last_accessed_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL
+		RETURNING ` + feedColumns
+
+	feed := &models.CalendarFeed{}
+	if err := scanFeed(r.db.QueryRow(ctx, query, tokenHash), feed); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+	return feed, nil
+}
+
+func (r *FeedRepository) RotateToken(ctx context.Context, id, userID int, tokenHash string) (*models.CalendarFeed, error) {
+	query := `
+		UPDATE calendar_feeds
+		SET token_hash = $3, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		RETURNING ` + feedColumns
+
+	feed := &models.CalendarFeed{}
+	if err := scanFeed(r.db.QueryRow(ctx, query, id, userID, tokenHash), feed); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+	return feed, nil
+}
+
+func (r *FeedRepository) RevokeFeed(ctx context.Context, id, userID int) error {
+	query := `
+		UPDATE calendar_feeds
+		SET revoked_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrIdentityInUse = errors.New("this account is already linked to a user")
+	ErrLastIdentity  = errors.New("the last sign-in method of an account cannot be removed")
+)
+
+const identityColumns = `id, user_id, provider, subject, email, last_used_at, created_at`
+
+type IdentityRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewIdentityRepository(db *pgxpool.Pool) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+func scanIdentity(row pgx.Row, identity *models.Identity) error {
+	return row.Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.LastUsedAt,
+		&identity.CreatedAt,
+	)
+}
+
+// CreateIdentity links the identity to its user. It returns ErrIdentityInUse
+// if the provider account is linked already.
+func (r *IdentityRepository) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+	return createIdentity(ctx, r.db, identity)
+}
+
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func createIdentity(ctx context.Context, db rowQuerier, identity *models.Identity) error {
+	query := `
+		INSERT INTO identities (user_id, provider, subject, email, last_used_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING ` + identityColumns
+
+	err := scanIdentity(db.QueryRow(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email), identity)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrIdentityInUse
+	}
+	return err
+}
+
+func (r *IdentityRepository) GetIdentities(ctx context.Context, userID int) ([]models.Identity, error) {
+	query := `SELECT ` + identityColumns + `
+		FROM identities
+		WHERE user_id = $1
+		ORDER BY id`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []models.Identity{}
+	for rows.Next() {
+		var identity models.Identity
+		if errScan := scanIdentity(rows, &identity); errScan != nil {
+			return nil, errScan
+		}
+		identities = append(identities, identity)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return identities, nil
+}
+
+// UseIdentity returns the identity for the provider account, recording the
+// time it was used and the email address the provider reported.
+func (r *IdentityRepository) UseIdentity(ctx context.Context, provider, subject, email string) (*models.Identity, error) {
+	query := `
+		UPDATE identities
+		SET last_used_at = NOW(), email = $3
+		WHERE provider = $1 AND subject = $2
+		RETURNING ` + identityColumns
+
+	var identity models.Identity
+	if err := scanIdentity(r.db.QueryRow(ctx, query, provider, subject, email), &identity); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// DeleteIdentity unlinks one of the user's identities. It returns
+// ErrLastIdentity rather than leave the user with no way to sign in.
+func (r *IdentityRepository) DeleteIdentity(ctx context.Context, userID, id int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the user so concurrent unlinks cannot remove the last two
+	// identities at once.
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return err
+	}
+
+	var exists bool
+	var count int
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(bool_or(id = $2), false), COUNT(*)
+		FROM identities
+		WHERE user_id = $1`, userID, id).Scan(&exists, &count)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	if count == 1 {
+		return ErrLastIdentity
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM identities WHERE id = $1 AND user_id = $2`, id, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const personalAccessTokenColumns = `id, user_id, name, scopes, expires_at, last_used_at, created_at`
+
+type PersonalAccessTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPersonalAccessTokenRepository(db *pgxpool.Pool) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{db: db}
+}
+
+func scanPersonalAccessToken(row pgx.Row, token *models.PersonalAccessToken) error {
+	return row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.Scopes,
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	)
+}
+
+func (r *PersonalAccessTokenRepository) CreateToken(ctx context.Context, token *models.PersonalAccessToken, tokenHash string) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING ` + personalAccessTokenColumns
+
+	return scanPersonalAccessToken(r.db.QueryRow(ctx, query, token.UserID, token.Name, tokenHash, token.Scopes, token.ExpiresAt), token)
+}
+
+// GetTokens returns the user's tokens that are neither revoked nor expired.
+func (r *PersonalAccessTokenRepository) GetTokens(ctx context.Context, userID int) ([]models.PersonalAccessToken, error) {
+	query := `SELECT ` + personalAccessTokenColumns + `
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY id`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.PersonalAccessToken{}
+	for rows.Next() {
+		var token models.PersonalAccessToken
+		if errScan := scanPersonalAccessToken(rows, &token); errScan != nil {
+			return nil, errScan
+		}
+		tokens = append(tokens, token)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return tokens, nil
+}
+
+// UseToken returns the active token with the given hash and records the
+// time it was used.
+func (r *PersonalAccessTokenRepository) UseToken(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	query := `
+		UPDATE personal_access_tokens
+		SET last_used_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		RETURNING ` + personalAccessTokenColumns
+
+	var token models.PersonalAccessToken
+	if err := scanPersonalAccessToken(r.db.QueryRow(ctx, query, tokenHash), &token); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PersonalAccessTokenRepository) RevokeToken(ctx context.Context, id, userID int) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrRefreshTokenReused is returned when a refresh token that was already
+// exchanged is presented again. Its whole family has been revoked by then.
+var ErrRefreshTokenReused = errors.New("refresh token was already used")
+
+const refreshTokenColumns = `id, user_id, family_id, expires_at, used_at, revoked_at, created_at`
+
+type RefreshTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRefreshTokenRepository(db *pgxpool.Pool) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+func scanRefreshToken(row pgx.Row, token *models.RefreshToken) error {
+	return row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+}
+
+func (r *RefreshTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken, tokenHash string) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING ` + refreshTokenColumns
+
+	return scanRefreshToken(r.db.QueryRow(ctx, query, token.UserID, token.FamilyID, tokenHash, token.ExpiresAt), token)
+}
+
+// RotateRefreshToken exchanges the token with the given hash for next, which
+// joins the same family. An unknown, expired or revoked token is reported as
+// sql.ErrNoRows. A token that was already exchanged revokes its family and
+// session and returns ErrRefreshTokenReused.
+func (r *RefreshTokenRepository) RotateRefreshToken(ctx context.Context, tokenHash string, next *models.RefreshToken, nextHash string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var current models.RefreshToken
+	query := `SELECT ` + refreshTokenColumns + ` FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`
+	if err := scanRefreshToken(tx.QueryRow(ctx, query, tokenHash), &current); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sql.ErrNoRows
+		}
+		return err
+	}
+	if current.RevokedAt != nil || !current.ExpiresAt.After(time.Now()) {
+		return sql.ErrNoRows
+	}
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+
+	if current.UsedAt != nil {
+		if err := revokeFamily(ctx, tx, current.FamilyID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, current.FamilyID); err != nil {
+			return err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+		return ErrRefreshTokenReused
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, current.ID); err != nil {
+		return err
+	}
+
+	insert := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING ` + refreshTokenColumns
+	if err := scanRefreshToken(tx.QueryRow(ctx, insert, next.UserID, next.FamilyID, nextHash, next.ExpiresAt), next); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func revokeFamily(ctx context.Context, db execer, familyID string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE family_id = $1 AND revoked_at IS NULL`
+
+	_, err := db.Exec(ctx, query, familyID)
+	return err
+}
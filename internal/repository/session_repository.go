@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const sessionColumns = `id, user_id, user_agent, ip_address, created_at, last_seen_at, revoked_at`
+
+type SessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSessionRepository(db *pgxpool.Pool) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func scanSession(row pgx.Row, session *models.Session) error {
+	return row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RevokedAt,
+	)
+}
+
+func (r *SessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, user_agent, ip_address, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING ` + sessionColumns
+
+	return scanSession(r.db.QueryRow(ctx, query, session.ID, session.UserID, session.UserAgent, session.IPAddress), session)
+}
+
+// GetSessions returns the user's sessions that have not been revoked, most
+// recently used first.
+func (r *SessionRepository) GetSessions(ctx context.Context, userID int) ([]models.Session, error) {
+	query := `SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if errScan := scanSession(rows, &session); errScan != nil {
+			return nil, errScan
+		}
+		sessions = append(sessions, session)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return sessions, nil
+}
+
+// GetSessionIDs returns the IDs of the user's sessions that have not been
+// revoked.
+func (r *SessionRepository) GetSessionIDs(ctx context.Context, userID int) ([]string, error) {
+	rows, err := r.db.Query(ctx, `SELECT id FROM sessions WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if errScan := rows.Scan(&id); errScan != nil {
+			return nil, errScan
+		}
+		ids = append(ids, id)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return ids, nil
+}
+
+// TouchSession records that the session was used just now from client.
+func (r *SessionRepository) TouchSession(ctx context.Context, id string, client models.SessionClient) error {
+	query := `
+		UPDATE sessions
+		SET last_seen_at = NOW(), user_agent = $2, ip_address = $3
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, id, client.UserAgent, client.IPAddress)
+	return err
+}
+
+// RevokeSession ends the session and revokes its refresh tokens.
+func (r *SessionRepository) RevokeSession(ctx context.Context, id string, userID int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	result, err := tx.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+	if err := revokeFamily(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetSessionByRefreshTokenHash returns the session the refresh token belongs
+// to, whether or not the token is still valid.
+func (r *SessionRepository) GetSessionByRefreshTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	query := `SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)`
+
+	var session models.Session
+	if err := scanSession(r.db.QueryRow(ctx, query, tokenHash), &session); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+	return &session, nil
+}
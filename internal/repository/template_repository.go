@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TemplateRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTemplateRepository(db *pgxpool.Pool) *TemplateRepository {
+	return &TemplateRepository{db: db}
+}
+
+func (r *TemplateRepository) CreateTemplate(ctx context.Context, template *models.TodoTemplate) error {
+	query := `
+		INSERT INTO todo_templates (user_id, name, items, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+
+	return r.db.QueryRow(ctx, query, template.UserID, template.Name, template.Items).
+		Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+}
+
+func (r *TemplateRepository) GetTemplates(ctx context.Context, userID int) ([]models.TodoTemplate, error) {
+	query := `
+		SELECT id, user_id, name, items, created_at, updated_at
+		FROM todo_templates
+		WHERE user_id = $1
+		ORDER BY name, id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []models.TodoTemplate{}
+	for rows.Next() {
+		var template models.TodoTemplate
+		errScan := rows.Scan(&template.ID, &template.UserID, &template.Name, &template.Items, &template.CreatedAt, &template.UpdatedAt)
+		if errScan != nil {
+			return nil, errScan
+		}
+		templates = append(templates, template)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return templates, nil
+}
+
+func (r *TemplateRepository) GetTemplateByID(ctx context.Context, id, userID int) (*models.TodoTemplate, error) {
+	query := `
+		SELECT id, user_id, name, items, created_at, updated_at
+		FROM todo_templates
+		WHERE id = $1 AND user_id = $2
+	`
+
+	template := &models.TodoTemplate{}
+	err := r.db.QueryRow(ctx, query, id, userID).
+		Scan(&template.ID, &template.UserID, &template.Name, &template.Items, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func (r *TemplateRepository) DeleteTemplate(ctx context.Context, id, userID int) error {
+	query := `DELETE FROM todo_templates WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
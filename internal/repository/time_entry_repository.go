@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrTimerAlreadyRunning = errors.New("a timer is already running")
+
+const timeEntryColumns = `id, user_id, todo_id, started_at, ended_at,
+	EXTRACT(EPOCH FROM (COALESCE(ended_at, NOW()) - started_at))::BIGINT,
+	note, source, created_at, updated_at`
+
+type TimeEntryRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTimeEntryRepository(db *pgxpool.Pool) *TimeEntryRepository {
+	return &TimeEntryRepository{db: db}
+}
+
+func scanTimeEntry(row pgx.Row, entry *models.TimeEntry) error {
+	return row.Scan(
+		&entry.ID,
+		&entry.UserID,
+		&entry.TodoID,
+		&entry.StartedAt,
+		&entry.EndedAt,
+		&entry.DurationSeconds,
+		&entry.Note,
+		&entry.Source,
+		&entry.CreatedAt,
+		&entry.UpdatedAt,
+	)
+}
+
+// StartTimer stops the user's running timer, if any, and starts a new one in
+// the same transaction. The stopped entry is returned so callers can notify
+// other devices.
+func (r *TimeEntryRepository) StartTimer(ctx context.Context, userID, todoID int, note string) (*models.TimeEntry, *models.TimeEntry, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	stopped, err := stopRunning(ctx, tx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := `
+		INSERT INTO time_entries (user_id, todo_id, started_at, note, source, created_at, updated_at)
+		VALUES ($1, $2, NOW(), $3, $4, NOW(), NOW())
+		RETURNING ` + timeEntryColumns
+
+	started := &models.TimeEntry{}
+	err = scanTimeEntry(tx.QueryRow(ctx, query, userID, todoID, note, models.TimeEntrySourceTimer), started)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, nil, ErrTimerAlreadyRunning
+		}
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return started, stopped, nil
+}
+
+// StopTimer stops the user's running timer. It returns sql.ErrNoRows when no
+// timer is running.
+func (r *TimeEntryRepository) StopTimer(ctx context.Context, userID int) (*models.TimeEntry, error) {
+	stopped, err := stopRunning(ctx, r.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if stopped == nil {
+		return nil, sql.ErrNoRows
+	}
+	return stopped, nil
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func stopRunning(ctx context.Context, q queryRower, userID int) (*models.TimeEntry, error) {
+	query := `
+		UPDATE time_entries
+		SET ended_at = NOW(), updated_at = NOW()
+		WHERE user_id = $1 AND ended_at IS NULL
+		RETURNING ` + timeEntryColumns
+
+	entry := &models.TimeEntry{}
+	err := scanTimeEntry(q.QueryRow(ctx, query, userID), entry)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *TimeEntryRepository) GetRunningTimer(ctx context.Context, userID int) (*models.TimeEntry, error) {
+	query := `SELECT ` + timeEntryColumns + `
+		FROM time_entries
+		WHERE user_id = $1 AND ended_at IS NULL`
+
+	entry := &models.TimeEntry{}
+	err := scanTimeEntry(r.db.QueryRow(ctx, query, userID), entry)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *TimeEntryRepository) CreateTimeEntry(ctx context.Context, entry *models.TimeEntry) error {
+	query := `
+		INSERT INTO time_entries (user_id, todo_id, started_at, ended_at, note, source, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING ` + timeEntryColumns
+
+	return scanTimeEntry(r.db.QueryRow(ctx, query, entry.UserID, entry.TodoID, entry.StartedAt, entry.EndedAt, entry.Note, entry.Source), entry)
+}
+
+func (r *TimeEntryRepository) GetTimeEntriesByTodo(ctx context.Context, todoID, userID int) ([]models.TimeEntry, error) {
+	query := `SELECT ` + timeEntryColumns + `
+		FROM time_entries
+		WHERE todo_id = $1 AND user_id = $2
+		ORDER BY started_at DESC`
+
+	rows, err := r.db.Query(ctx, query, todoID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.TimeEntry{}
+	for rows.Next() {
+		var entry models.TimeEntry
+		if errScan := scanTimeEntry(rows, &entry); errScan != nil {
+			return nil, errScan
+		}
+		entries = append(entries, entry)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return entries, nil
+}
+
+// GetTimeEntries returns all of the user's time entries, newest first.
+func (r *TimeEntryRepository) GetTimeEntries(ctx context.Context, userID int) ([]models.TimeEntry, error) {
+	query := `SELECT ` + timeEntryColumns + `
+		FROM time_entries
+		WHERE user_id = $1
+		ORDER BY started_at DESC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.TimeEntry{}
+	for rows.Next() {
+		var entry models.TimeEntry
+		if errScan := scanTimeEntry(rows, &entry); errScan != nil {
+			return nil, errScan
+		}
+		entries = append(entries, entry)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return entries, nil
+}
+
+func (r *TimeEntryRepository) DeleteTimeEntry(ctx context.Context, id, userID int) error {
+	query := `DELETE FROM time_entries WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetTimeReport sums tracked time per group for entries started within
+// [from, to). Running timers are counted up to now. groupBy must be "day",
+// "week", "todo" or "label"; days are bucketed in the given IANA time zone and
+// weeks, keyed by their first day, start on weekStart. A todo with several
+// labels counts toward each of them, and entries on todos without labels are
+// reported under an empty key labelled "unlabelled".
+func (r *TimeEntryRepository) GetTimeReport(ctx context.Context, userID int, from, to time.Time, groupBy, timezone string, weekStart time.Weekday) ([]models.TimeReportGroup, error) {
+	var query string
+	args := []any{userID, from, to}
+	switch groupBy {
+	case "day":
+		query = `
+			SELECT to_char(te.started_at AT TIME ZONE $4, 'YYYY-MM-DD') AS key,
+				to_char(te.started_at AT TIME ZONE $4, 'YYYY-MM-DD') AS label,
+				SUM(EXTRACT(EPOCH FROM (COALESCE(te.ended_at, NOW()) - te.started_at)))::BIGINT,
+				COUNT(*)
+			FROM time_entries te
+			WHERE te.user_id = $1 AND te.started_at >= $2 AND te.started_at < $3
+			GROUP BY 1, 2
+			ORDER BY 1`
+		args = append(args, timezone)
+	case "week":
+		// date_trunc weeks start on Monday; shift by the days between the
+		// user's week start and Monday, truncate, and shift back.
+		query = `
+			WITH entries AS (
+				SELECT (date_trunc('week', (te.started_at AT TIME ZONE $4) + make_interval(days => $5))
+					- make_interval(days => $5))::DATE AS week, te.started_at, te.ended_at
+				FROM time_entries te
+				WHERE te.user_id = $1 AND te.started_at >= $2 AND te.started_at < $3
+			)
+			SELECT to_char(week, 'YYYY-MM-DD') AS key,
+				to_char(week, 'YYYY-MM-DD') AS label,
+				SUM(EXTRACT(EPOCH FROM (COALESCE(ended_at, NOW()) - started_at)))::BIGINT,
+				COUNT(*)
+			FROM entries
+			GROUP BY 1, 2
+			ORDER BY 1`
+		args = append(args, timezone, (int(time.Monday)-int(weekStart)+7)%7)
+	case "todo":
+		query = `
+			SELECT te.todo_id::TEXT AS key,
+				t.title AS label,
+				SUM(EXTRACT(EPOCH FROM (COALESCE(te.ended_at, NOW()) - te.started_at)))::BIGINT,
+				COUNT(*)
+			FROM time_entries te
+			JOIN todos t ON t.id = te.todo_id
+			WHERE te.user_id = $1 AND te.started_at >= $2 AND te.started_at < $3
+			GROUP BY te.todo_id, t.title
+			ORDER BY 3 DESC`
+	case "label":
+		query = `
+			SELECT COALESCE(l.label, '') AS key,
+				COALESCE(l.label, 'unlabelled') AS label,
+				SUM(EXTRACT(EPOCH FROM (COALESCE(te.ended_at, NOW()) - te.started_at)))::BIGINT,
+				COUNT(*)
+			FROM time_entries te
+			JOIN todos t ON t.id = te.todo_id
+			LEFT JOIN LATERAL unnest(t.labels) AS l(label) ON TRUE
+			WHERE te.user_id = $1 AND te.started_at >= $2 AND te.started_at < $3
+			GROUP BY 1, 2
+			ORDER BY 3 DESC`
+	default:
+		return nil, errors.New("unsupported group_by: " + groupBy)
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.TimeReportGroup{}
+	for rows.Next() {
+		var group models.TimeReportGroup
+		if errScan := rows.Scan(&group.Key, &group.Label, &group.TotalSeconds, &group.Entries); errScan != nil {
+			return nil, errScan
+		}
+		groups = append(groups, group)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return groups, nil
+}
+
+// GetTrackedSeconds returns the total time tracked in entries started within
+// [from, to), counting running timers up to now.
+func (r *TimeEntryRepository) GetTrackedSeconds(ctx context.Context, userID int, from, to time.Time) (int64, error) {
+	var total int64
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(EXTRACT(EPOCH FROM (COALESCE(ended_at, NOW()) - started_at))), 0)::BIGINT
+		FROM time_entries
+		WHERE user_id = $1 AND started_at >= $2 AND started_at < $3`,
+		userID, from, to,
+	).Scan(&total)
+	return total, err
+}
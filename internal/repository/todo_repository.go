@@ -7,9 +7,26 @@ import (
 
 	"github.com/cauldnclark/todo-go/internal/models"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	ErrICalUIDConflict  = errors.New("a todo with this UID already exists")
+	ErrClientIDConflict = errors.New("a todo with this client ID already exists")
+	ErrStaleVersion     = errors.New("todo was modified by another request")
+)
+
+const todoColumns = `id, user_id, title, description, completed, due_date, priority, recurrence, labels, ical_uid, COALESCE(caldav_resource, ''), COALESCE(client_id, ''), version, change_seq, created_at, updated_at`
+
+// insertTodoQuery leaves ical_uid to the database default unless the todo
+// came from a calendar client with its own UID.
+const insertTodoQuery = `
+	INSERT INTO todos (user_id, title, description, completed, due_date, priority, recurrence, labels, ical_uid, caldav_resource, client_id, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), NOW(), NOW())
+	RETURNING id, ical_uid, version, change_seq, created_at, updated_at
+`
+
 type TodoRepository struct {
 	db *pgxpool.Pool
 }
@@ -18,43 +35,96 @@ func NewTodoRepository(db *pgxpool.Pool) *TodoRepository {
 	return &TodoRepository{db: db}
 }
 
+func scanTodo(row pgx.Row, todo *models.Todo) error {
+	return row.Scan(
+		&todo.ID,
+		&todo.UserID,
+		&todo.Title,
+		&todo.Description,
+		&todo.Completed,
+		&todo.DueDate,
+		&todo.Priority,
+		&todo.Recurrence,
+		&todo.Labels,
+		&todo.ICalUID,
+		&todo.CalDAVResource,
+		&todo.ClientID,
+		&todo.Version,
+		&todo.ChangeSeq,
+		&todo.CreatedAt,
+		&todo.UpdatedAt,
+	)
+}
+
 func (r *TodoRepository) CreateTodo(ctx context.Context, todo *models.Todo) error {
-	query := `
-		INSERT INTO todos (user_id, title, description, completed, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, created_at, updated_at
-	`
+	if todo.Priority == "" {
+		todo.Priority = models.PriorityNone
+	}
+	if todo.Labels == nil {
+		todo.Labels = []string{}
+	}
 
-	err := r.db.QueryRow(ctx, query, todo.UserID, todo.Title, todo.Description, todo.Completed).Scan(&todo.ID, &todo.CreatedAt, &todo.UpdatedAt)
+	err := r.db.QueryRow(ctx, insertTodoQuery, insertTodoArgs(todo)...).
+		Scan(&todo.ID, &todo.ICalUID, &todo.Version, &todo.ChangeSeq, &todo.CreatedAt, &todo.UpdatedAt)
 	if err != nil {
-		return err
+		return mapTodoWriteError(err)
 	}
 	return nil
 }
 
+// CreateTodos inserts all todos in a single transaction.
+func (r *TodoRepository) CreateTodos(ctx context.Context, todos []*models.Todo) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, todo := range todos {
+		if todo.Priority == "" {
+			todo.Priority = models.PriorityNone
+		}
+		if todo.Labels == nil {
+			todo.Labels = []string{}
+		}
+		err := tx.QueryRow(ctx, insertTodoQuery, insertTodoArgs(todo)...).
+			Scan(&todo.ID, &todo.ICalUID, &todo.Version, &todo.ChangeSeq, &todo.CreatedAt, &todo.UpdatedAt)
+		if err != nil {
+			return mapTodoWriteError(err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// todoSortOrders maps the sort orders clients can ask for to ORDER BY
+// clauses; id breaks ties so pages stay stable.
+var todoSortOrders = map[string]string{
+	models.SortCreatedAsc:  "id",
+	models.SortCreatedDesc: "id DESC",
+	models.SortDueDate:     "due_date NULLS LAST, id",
+	models.SortPriority:    "CASE priority WHEN 'high' THEN 0 WHEN 'medium' THEN 1 WHEN 'low' THEN 2 ELSE 3 END, id",
+	models.SortTitle:       "lower(title), id",
+}
+
 // paginated search of todos
-func (r *TodoRepository) GetTodosPaginated(ctx context.Context, userID int, completed *bool, page, limit int) (*models.TodosPaginated, error) {
-	query := `
-			SELECT id, user_id, title, description, completed, created_at, updated_at
-			FROM todos
-			WHERE user_id = $1
-			AND completed = $2
-			ORDER BY id
-			LIMIT $3
-			OFFSET $4
-		`
-	if completed == nil {
-		query = `
-			SELECT id, user_id, title, description, completed, created_at, updated_at
-			FROM todos
-			WHERE user_id = $1
-			ORDER BY id
-			LIMIT $2
-			OFFSET $3
-		`
+func (r *TodoRepository) GetTodosPaginated(ctx context.Context, userID int, completed *bool, page, limit int, sort string) (*models.TodosPaginated, error) {
+	orderBy, ok := todoSortOrders[sort]
+	if !ok {
+		orderBy = todoSortOrders[models.SortCreatedAsc]
 	}
+
 	offset := (page - 1) * limit
-	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE user_id = $1
+		AND ($2::BOOLEAN IS NULL OR completed = $2)
+		ORDER BY ` + orderBy + `
+		LIMIT $3
+		OFFSET $4
+	`
+	rows, err := r.db.Query(ctx, query, userID, completed, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +133,7 @@ func (r *TodoRepository) GetTodosPaginated(ctx context.Context, userID int, comp
 	var todos []models.Todo
 	for rows.Next() {
 		var todo models.Todo
-		errScan := rows.Scan(&todo.ID, &todo.UserID, &todo.Title, &todo.Description, &todo.Completed, &todo.CreatedAt, &todo.UpdatedAt)
+		errScan := scanTodo(rows, &todo)
 		if errScan != nil {
 			return nil, errScan
 		}
@@ -78,7 +148,7 @@ func (r *TodoRepository) GetTodosPaginated(ctx context.Context, userID int, comp
 		SELECT COUNT(*)
 		FROM todos
 		WHERE user_id = $1
-		AND completed = $2
+		AND ($2::BOOLEAN IS NULL OR completed = $2)
 	`
 	var total int
 	err = r.db.QueryRow(ctx, query, userID, completed).Scan(&total)
@@ -96,23 +166,49 @@ func (r *TodoRepository) GetTodosPaginated(ctx context.Context, userID int, comp
 	}, nil
 }
 
+// StreamTodos calls fn for every todo of the user matching filter, in id
+// order, without holding the whole result set in memory.
+func (r *TodoRepository) StreamTodos(ctx context.Context, userID int, filter models.TodoFilter, fn func(*models.Todo) error) error {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE user_id = $1
+		AND ($2 OR completed = FALSE)
+		AND ($3 = '' OR $3 = ANY(labels))
+		AND ($4::TIMESTAMPTZ IS NULL OR due_date >= $4)
+		AND ($5::TIMESTAMPTZ IS NULL OR due_date < $5)
+		AND (NOT $6 OR due_date IS NOT NULL)
+		AND change_seq > $7
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, filter.IncludeCompleted, filter.Label, filter.DueFrom, filter.DueTo, filter.OnlyWithDueDate, filter.ChangedAfter)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var todo models.Todo
+	for rows.Next() {
+		if errScan := scanTodo(rows, &todo); errScan != nil {
+			return errScan
+		}
+		if errFn := fn(&todo); errFn != nil {
+			return errFn
+		}
+	}
+
+	return rows.Err()
+}
+
 func (r *TodoRepository) GetTodoByID(ctx context.Context, id, userID int) (*models.Todo, error) {
 	todo := &models.Todo{}
 	query := `
-		SELECT id, user_id, title, description, completed, created_at, updated_at
+		SELECT ` + todoColumns + `
 		FROM todos
 		WHERE id = $1 AND user_id = $2`
 
-	err := r.db.QueryRow(ctx, query, id, userID).Scan(
-		&todo.ID,
-		&todo.UserID,
-		&todo.Title,
-		&todo.Description,
-		&todo.Completed,
-		&todo.CreatedAt,
-		&todo.UpdatedAt,
-	)
-
+	err := scanTodo(r.db.QueryRow(ctx, query, id, userID), todo)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, sql.ErrNoRows
@@ -123,19 +219,26 @@ func (r *TodoRepository) GetTodoByID(ctx context.Context, id, userID int) (*mode
 	return todo, nil
 }
 
+// UpdateTodo saves the todo if it is still at todo.Version, which then
+// becomes the new version. It returns ErrStaleVersion when someone else
+// updated the todo in the meantime.
 func (r *TodoRepository) UpdateTodo(ctx context.Context, todo *models.Todo) error {
+	if todo.Labels == nil {
+		todo.Labels = []string{}
+	}
+
 	query := `
 		UPDATE todos
-		SET title = $3, description = $4, completed = $5, updated_at = NOW()
-		WHERE id = $1 AND user_id = $2
-		RETURNING updated_at`
+		SET title = $3, description = $4, completed = $5, due_date = $6, priority = $7, recurrence = $8, labels = $9, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND version = $10
+		RETURNING version, change_seq, updated_at`
 
-	err := r.db.QueryRow(ctx, query, todo.ID, todo.UserID, todo.Title, todo.Description, todo.Completed).
-		Scan(&todo.UpdatedAt)
+	err := r.db.QueryRow(ctx, query, todo.ID, todo.UserID, todo.Title, todo.Description, todo.Completed, todo.DueDate, todo.Priority, todo.Recurrence, todo.Labels, todo.Version).
+		Scan(&todo.Version, &todo.ChangeSeq, &todo.UpdatedAt)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return sql.ErrNoRows
+			return r.missingOrStale(ctx, todo.ID, todo.UserID)
 		}
 		return err
 	}
@@ -143,17 +246,219 @@ func (r *TodoRepository) UpdateTodo(ctx context.Context, todo *models.Todo) erro
 	return nil
 }
 
-func (r *TodoRepository) DeleteTodo(ctx context.Context, id, userID int) error {
-	query := `DELETE FROM todos WHERE id = $1 AND user_id = $2`
+// DeleteTodo deletes the todo. A non-zero version makes the delete
+// conditional on the todo still being at that version.
+func (r *TodoRepository) DeleteTodo(ctx context.Context, id, userID, version int) error {
+	query := `DELETE FROM todos WHERE id = $1 AND user_id = $2 AND ($3 = 0 OR version = $3)`
 
-	result, err := r.db.Exec(ctx, query, id, userID)
+	result, err := r.db.Exec(ctx, query, id, userID, version)
 	if err != nil {
 		return err
 	}
 
 	if result.RowsAffected() == 0 {
-		return sql.ErrNoRows
+		if version == 0 {
+			return sql.ErrNoRows
+		}
+		return r.missingOrStale(ctx, id, userID)
 	}
 
 	return nil
 }
+
+// missingOrStale explains why a versioned write matched no rows.
+func (r *TodoRepository) missingOrStale(ctx context.Context, id, userID int) error {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM todos WHERE id = $1 AND user_id = $2)`
+	if err := r.db.QueryRow(ctx, query, id, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrStaleVersion
+	}
+	return sql.ErrNoRows
+}
+
+// GetTodoByResource finds a todo by its CalDAV file name: either the name the
+// client stored it under, or "<ical_uid>.ics".
+func (r *TodoRepository) GetTodoByResource(ctx context.Context, userID int, resource, uid string) (*models.Todo, error) {
+	todo := &models.Todo{}
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE user_id = $1
+		AND (caldav_resource = $2 OR (caldav_resource IS NULL AND ical_uid = $3))`
+
+	err := scanTodo(r.db.QueryRow(ctx, query, userID, resource, uid), todo)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return todo, nil
+}
+
+// GetTodoIDs returns the IDs of all of the user's todos.
+func (r *TodoRepository) GetTodoIDs(ctx context.Context, userID int) ([]int, error) {
+	rows, err := r.db.Query(ctx, `SELECT id FROM todos WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if errScan := rows.Scan(&id); errScan != nil {
+			return nil, errScan
+		}
+		ids = append(ids, id)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return ids, nil
+}
+
+// GetTombstones returns todos deleted after the given change sequence number,
+// oldest first. A limit of 0 returns all of them.
+func (r *TodoRepository) GetTombstones(ctx context.Context, userID int, changedAfter int64, limit int) ([]models.TodoTombstone, error) {
+	query := `
+		SELECT id, user_id, todo_id, ical_uid, COALESCE(caldav_resource, ''), COALESCE(client_id, ''), change_seq, deleted_at
+		FROM todo_tombstones
+		WHERE user_id = $1 AND change_seq > $2
+		ORDER BY change_seq
+		LIMIT NULLIF($3, 0)`
+
+	rows, err := r.db.Query(ctx, query, userID, changedAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tombstones := []models.TodoTombstone{}
+	for rows.Next() {
+		var t models.TodoTombstone
+		errScan := rows.Scan(&t.ID, &t.UserID, &t.TodoID, &t.ICalUID, &t.CalDAVResource, &t.ClientID, &t.ChangeSeq, &t.DeletedAt)
+		if errScan != nil {
+			return nil, errScan
+		}
+		tombstones = append(tombstones, t)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return tombstones, nil
+}
+
+// GetTodosChangedSince returns up to limit todos written after the given
+// change sequence number, in the order they were written.
+func (r *TodoRepository) GetTodosChangedSince(ctx context.Context, userID int, changedAfter int64, limit int) ([]models.Todo, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE user_id = $1 AND change_seq > $2
+		ORDER BY change_seq
+		LIMIT $3`
+
+	rows, err := r.db.Query(ctx, query, userID, changedAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []models.Todo{}
+	for rows.Next() {
+		var todo models.Todo
+		if errScan := scanTodo(rows, &todo); errScan != nil {
+			return nil, errScan
+		}
+		todos = append(todos, todo)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return todos, nil
+}
+
+func (r *TodoRepository) GetTodoByClientID(ctx context.Context, userID int, clientID string) (*models.Todo, error) {
+	todo := &models.Todo{}
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE user_id = $1 AND client_id = $2`
+
+	err := scanTodo(r.db.QueryRow(ctx, query, userID, clientID), todo)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return todo, nil
+}
+
+// GetLabels returns every label in use on the user's todos, sorted.
+func (r *TodoRepository) GetLabels(ctx context.Context, userID int) ([]string, error) {
+	query := `
+		SELECT DISTINCT label
+		FROM todos, unnest(labels) AS label
+		WHERE user_id = $1
+		ORDER BY label`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []string{}
+	for rows.Next() {
+		var label string
+		if errScan := rows.Scan(&label); errScan != nil {
+			return nil, errScan
+		}
+		labels = append(labels, label)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return labels, nil
+}
+
+// GetChangeSeq returns the change sequence number of the user's most recent
+// committed write or deletion, or 0 when there has been none. A user's
+// sequence numbers are taken under a lock on their counter row, so they
+// commit in order and no change can later appear below the returned value.
+func (r *TodoRepository) GetChangeSeq(ctx context.Context, userID int) (int64, error) {
+	query := `
+		SELECT COALESCE((SELECT change_seq FROM todo_change_counters WHERE user_id = $1), 0)`
+
+	var seq int64
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&seq); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+func insertTodoArgs(todo *models.Todo) []any {
+	return []any{todo.UserID, todo.Title, todo.Description, todo.Completed, todo.DueDate, todo.Priority, todo.Recurrence, todo.Labels, todo.ICalUID, todo.CalDAVResource, todo.ClientID}
+}
+
+func mapTodoWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		if pgErr.ConstraintName == "idx_todos_user_client_id" {
+			return ErrClientIDConflict
+		}
+		return ErrICalUIDConflict
+	}
+	return err
+}
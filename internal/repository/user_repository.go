@@ -2,11 +2,21 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"time"
 
 	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const userColumns = `id, email, name, picture_url,
+	timezone, locale, week_start, default_sort, notification_settings,
+	deletion_scheduled_at, created_at, updated_at`
+
+const settingsColumns = `timezone, locale, week_start, default_sort, notification_settings`
+
 type UserRepository struct {
 	db *pgxpool.Pool
 }
@@ -15,56 +25,188 @@ func NewUserRepository(db *pgxpool.Pool) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
-	query := `
-		INSERT INTO users (google_id, email, name, picture_url, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, created_at, updated_at
-	`
-	err := r.db.QueryRow(ctx, query, user.GoogleID, user.Email, user.Name, user.PictureURL).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+func scanUser(row pgx.Row, user *models.User) error {
+	return row.Scan(&user.ID, &user.Email, &user.Name, &user.PictureURL,
+		&user.Settings.Timezone, &user.Settings.Locale, &user.Settings.WeekStart, &user.Settings.DefaultSort, &user.Settings.Notifications,
+		&user.DeletionScheduledAt, &user.CreatedAt, &user.UpdatedAt)
+}
+
+func scanSettings(row pgx.Row, settings *models.UserSettings) error {
+	return row.Scan(&settings.Timezone, &settings.Locale, &settings.WeekStart, &settings.DefaultSort, &settings.Notifications)
+}
+
+// CreateUser creates the user together with the identity they signed up
+// with, which is linked to the new user.
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User, identity *models.Identity) error {
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO users (email, name, picture_url, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING ` + userColumns
+	if err := scanUser(tx.QueryRow(ctx, query, user.Email, user.Name, user.PictureURL), user); err != nil {
+		return err
+	}
+
+	identity.UserID = user.ID
+	if err := createIdentity(ctx, tx, identity); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r *UserRepository) GetUserByGoogleID(ctx context.Context, googleID string) (*models.User, error) {
+func (r *UserRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
 	query := `
-		SELECT id, google_id, email, name, picture_url, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
-		WHERE google_id = $1
+		WHERE id = $1
 	`
 	var user models.User
-	err := r.db.QueryRow(ctx, query, googleID).Scan(&user.ID, &user.GoogleID, &user.Email, &user.Name, &user.PictureURL, &user.CreatedAt, &user.UpdatedAt)
+	err := scanUser(r.db.QueryRow(ctx, query, id), &user)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (r *UserRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
 	query := `
-		SELECT id, google_id, email, name, picture_url, created_at, updated_at
-		FROM users
-		WHERE id = $1
+		UPDATE users
+		SET email = $1, name = $2, picture_url = $3, updated_at = NOW()
+		WHERE id = $4
 	`
-	var user models.User
-	err := r.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.GoogleID, &user.Email, &user.Name, &user.PictureURL, &user.CreatedAt, &user.UpdatedAt)
+	_, err := r.db.Exec(ctx, query, user.Email, user.Name, user.PictureURL, user.ID)
 	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) GetSettings(ctx context.Context, userID int) (*models.UserSettings, error) {
+	query := `SELECT ` + settingsColumns + ` FROM users WHERE id = $1`
+	var settings models.UserSettings
+	if err := scanSettings(r.db.QueryRow(ctx, query, userID), &settings); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
 		return nil, err
 	}
-	return &user, nil
+	return &settings, nil
 }
 
-func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+func (r *UserRepository) UpdateSettings(ctx context.Context, userID int, settings *models.UserSettings) error {
 	query := `
 		UPDATE users
-		SET google_id = $1, email = $2, name = $3, picture_url = $4, updated_at = NOW()
-		WHERE id = $5
+		SET timezone = $1, locale = $2, week_start = $3, default_sort = $4, notification_settings = $5
+		WHERE id = $6
 	`
-	_, err := r.db.Exec(ctx, query, user.GoogleID, user.Email, user.Name, user.PictureURL, user.ID)
+	tag, err := r.db.Exec(ctx, query, settings.Timezone, settings.Locale, settings.WeekStart, settings.DefaultSort, settings.Notifications, userID)
 	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
+
+// ScheduleDeletion marks the account for deletion at the given time and
+// revokes everything that signs in as the user: app passwords, calendar
+// feeds, sessions with their refresh tokens and personal access tokens.
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, userID int, at time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE users SET deletion_scheduled_at = $1, updated_at = NOW() WHERE id = $2`, at, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(ctx, `UPDATE app_passwords SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE calendar_feeds SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *UserRepository) CancelDeletion(ctx context.Context, userID int) error {
+	_, err := r.db.Exec(ctx, `UPDATE users SET deletion_scheduled_at = NULL, updated_at = NOW() WHERE id = $1`, userID)
+	return err
+}
+
+// GetUsersDueForDeletion returns up to limit users whose grace period ended
+// before now.
+func (r *UserRepository) GetUsersDueForDeletion(ctx context.Context, now time.Time, limit int) ([]int, error) {
+	query := `
+		SELECT id
+		FROM users
+		WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= $1
+		ORDER BY deletion_scheduled_at
+		LIMIT $2
+	`
+	rows, err := r.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if errScan := rows.Scan(&id); errScan != nil {
+			return nil, errScan
+		}
+		userIDs = append(userIDs, id)
+	}
+	if errRows := rows.Err(); errRows != nil {
+		return nil, errRows
+	}
+
+	return userIDs, nil
+}
+
+// DeleteUser removes the user and everything they own. Todos go first: their
+// delete trigger writes tombstones that reference the user, which the
+// cascade from users then removes.
+func (r *UserRepository) DeleteUser(ctx context.Context, userID int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM todos WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit(ctx)
+}
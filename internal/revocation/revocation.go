@@ -0,0 +1,108 @@
+// Package revocation records access tokens that must stop working before
+// they expire. Tokens are self-contained JWTs, so the check happens on every
+// request against Redis.
+package revocation
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type Store struct {
+	client *redis.Client
+}
+
+func NewStore(client *redis.Client) *Store {
+	return &Store{client: client}
+}
+
+// RevokeUserTokens invalidates every token issued to the user before the
+// current second.
+// ttl should be the lifetime of a token: after that the tokens expire anyway.
+func (s *Store) RevokeUserTokens(ctx context.Context, userID int, ttl time.Duration) error {
+	return s.client.GetClient().Set(ctx, revokedBeforeKey(userID), time.Now().Unix(), ttl).Err()
+}
+
+// IsRevoked reports whether a token issued to the user at issuedAt has been
+// revoked. Token timestamps only have second precision, so a token issued in
+// the same second as RevokeUserTokens counts as newer: signing in again right
+// after the revocation must work.
+func (s *Store) IsRevoked(ctx context.Context, userID int, issuedAt time.Time) (bool, error) {
+	value, err := s.client.GetClient().Get(ctx, revokedBeforeKey(userID)).Int64()
+	if errors.Is(err, goredis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return issuedAt.Unix() < value, nil
+}
+
+// TrackToken remembers that the token with the given jti was issued to the
+// session, so that RevokeSession can deny it. ttl should be the lifetime of
+// the token.
+func (s *Store) TrackToken(ctx context.Context, sessionID, jti string, ttl time.Duration) error {
+	pipe := s.client.GetClient().TxPipeline()
+	pipe.SAdd(ctx, sessionTokensKey(sessionID), jti)
+	pipe.Expire(ctx, sessionTokensKey(sessionID), ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RevokeToken denies the token with the given jti until ttl has passed.
+func (s *Store) RevokeToken(ctx context.Context, jti string, ttl time.Duration) error {
+	return s.client.GetClient().Set(ctx, deniedTokenKey(jti), 1, ttl).Err()
+}
+
+// RevokeSession denies every unexpired token issued to the session.
+func (s *Store) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	jtis, err := s.client.GetClient().SMembers(ctx, sessionTokensKey(sessionID)).Result()
+	if err != nil {
+		return err
+	}
+	for _, jti := range jtis {
+		if err := s.RevokeToken(ctx, jti, ttl); err != nil {
+			return err
+		}
+	}
+	return s.client.GetClient().Del(ctx, sessionTokensKey(sessionID)).Err()
+}
+
+// IsTokenRevoked reports whether the token with the given jti is denied.
+func (s *Store) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := s.client.GetClient().Exists(ctx, deniedTokenKey(jti)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// IsAccessTokenRevoked reports whether an access token was revoked, either
+// together with all of the user's tokens or on its own by jti.
+func (s *Store) IsAccessTokenRevoked(ctx context.Context, userID int, issuedAt time.Time, jti string) (bool, error) {
+	revoked, err := s.IsRevoked(ctx, userID, issuedAt)
+	if err != nil || revoked {
+		return revoked, err
+	}
+	if jti == "" {
+		return false, nil
+	}
+	return s.IsTokenRevoked(ctx, jti)
+}
+
+func sessionTokensKey(sessionID string) string {
+	return "auth:session_tokens:" + sessionID
+}
+
+func deniedTokenKey(jti string) string {
+	return "auth:denied_jti:" + jti
+}
+
+func revokedBeforeKey(userID int) string {
+	return "auth:revoked_before:" + strconv.Itoa(userID)
+}
@@ -0,0 +1,112 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/redis/redistest"
+)
+
+func TestRevokeSession(t *testing.T) {
+	client, server := redistest.NewClient(t)
+	store := NewStore(client)
+	ctx := context.Background()
+
+	for _, jti := range []string{"a", "b"} {
+		if err := store.TrackToken(ctx, "session-1", jti, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.TrackToken(ctx, "session-2", "c", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RevokeSession(ctx, "session-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	for jti, want := range map[string]bool{"a": true, "b": true, "c": false} {
+		revoked, err := store.IsTokenRevoked(ctx, jti)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked != want {
+			t.Errorf("IsTokenRevoked(%q) = %v, want %v", jti, revoked, want)
+		}
+	}
+
+	// Revoking an unknown or already revoked session is not an error.
+	if err := store.RevokeSession(ctx, "session-1", time.Minute); err != nil {
+		t.Errorf("revoking again: %v", err)
+	}
+	if err := store.RevokeSession(ctx, "unknown", time.Minute); err != nil {
+		t.Errorf("revoking an unknown session: %v", err)
+	}
+
+	// Denials only need to outlive the tokens.
+	server.FastForward(time.Minute)
+	if revoked, _ := store.IsTokenRevoked(ctx, "a"); revoked {
+		t.Errorf("denial outlived its ttl")
+	}
+}
+
+func TestIsAccessTokenRevoked(t *testing.T) {
+	client, _ := redistest.NewClient(t)
+	store := NewStore(client)
+	ctx := context.Background()
+	issuedAt := time.Now().Add(-time.Minute)
+
+	revoked, err := store.IsAccessTokenRevoked(ctx, 1, issuedAt, "jti-1")
+	if err != nil || revoked {
+		t.Fatalf("fresh token: revoked = %v, err = %v", revoked, err)
+	}
+
+	if err := store.RevokeToken(ctx, "jti-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := store.IsAccessTokenRevoked(ctx, 1, issuedAt, "jti-1"); !revoked {
+		t.Errorf("token revoked by jti is still accepted")
+	}
+	if revoked, _ := store.IsAccessTokenRevoked(ctx, 1, issuedAt, "jti-2"); revoked {
+		t.Errorf("revoking one jti revoked another token")
+	}
+	if revoked, _ := store.IsAccessTokenRevoked(ctx, 1, issuedAt, ""); revoked {
+		t.Errorf("token without a jti reported as revoked")
+	}
+
+	if err := store.RevokeUserTokens(ctx, 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := store.IsAccessTokenRevoked(ctx, 1, issuedAt, "jti-2"); !revoked {
+		t.Errorf("token issued before RevokeUserTokens is still accepted")
+	}
+	if revoked, _ := store.IsAccessTokenRevoked(ctx, 2, issuedAt, "jti-3"); revoked {
+		t.Errorf("revoking one user's tokens revoked another user's")
+	}
+}
+
+func TestRevokeUserTokensSameSecond(t *testing.T) {
+	client, _ := redistest.NewClient(t)
+	store := NewStore(client)
+	ctx := context.Background()
+
+	if err := store.RevokeUserTokens(ctx, 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	revokedAt, err := client.GetClient().Get(ctx, revokedBeforeKey(1)).Int64()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Token iat claims are whole seconds.
+	if revoked, _ := store.IsRevoked(ctx, 1, time.Unix(revokedAt-1, 0)); !revoked {
+		t.Errorf("token issued the second before the revocation is still accepted")
+	}
+	if revoked, _ := store.IsRevoked(ctx, 1, time.Unix(revokedAt, 0)); revoked {
+		t.Errorf("token issued in the same second as the revocation is rejected")
+	}
+	if revoked, _ := store.IsRevoked(ctx, 1, time.Unix(revokedAt+1, 0)); revoked {
+		t.Errorf("token issued after the revocation is rejected")
+	}
+}
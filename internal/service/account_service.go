@@ -0,0 +1,377 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/cache"
+	"github.com/cauldnclark/todo-go/internal/exporter"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/revocation"
+	"github.com/cauldnclark/todo-go/internal/websocket"
+)
+
+var (
+	ErrExportInProgress = errors.New("an export is already running")
+	ErrExportNotReady   = errors.New("export is not ready for download")
+	ErrExportTooLarge   = errors.New("account data is too large to export")
+)
+
+const (
+	accountExportTTL = 24 * time.Hour
+	// accountExportLockTTL frees a user's export lock if the server stops
+	// before the export finishes.
+	accountExportLockTTL = 15 * time.Minute
+	// Archives are kept in Redis until they expire, so their size is capped.
+	maxAccountExportSize = 32 << 20
+
+	// deletionGracePeriod is how long a user has to change their mind by
+	// signing in again before the account is deleted for good.
+	deletionGracePeriod = 30 * 24 * time.Hour
+	deletionBatchSize   = 100
+)
+
+const accountExportReadme = `This archive contains all data stored for your todo-go account.
+
+profile.json         your profile and settings
+todos.json           your todos, in the format accepted by POST /api/import
+templates.json       your todo templates
+time_entries.json    your time entries
+calendar_feeds.json  your calendar subscriptions (tokens are not included)
+app_passwords.json   your app passwords (the passwords themselves are not included)
+identities.json      the accounts you sign in with
+history.json         todos you deleted, as kept for syncing clients
+
+todo-go does not store comments or attachments, so there are none to export.
+`
+
+type AccountService struct {
+	userRepo        *repository.UserRepository
+	todoRepo        *repository.TodoRepository
+	templateRepo    *repository.TemplateRepository
+	timeRepo        *repository.TimeEntryRepository
+	feedRepo        *repository.FeedRepository
+	appPasswordRepo *repository.AppPasswordRepository
+	identityRepo    *repository.IdentityRepository
+	cache           *cache.RedisCache
+	hub             *websocket.Hub
+	revocations     *revocation.Store
+}
+
+func NewAccountService(userRepo *repository.UserRepository, todoRepo *repository.TodoRepository, templateRepo *repository.TemplateRepository, timeRepo *repository.TimeEntryRepository, feedRepo *repository.FeedRepository, appPasswordRepo *repository.AppPasswordRepository, identityRepo *repository.IdentityRepository, cache *cache.RedisCache, hub *websocket.Hub, revocations *revocation.Store) *AccountService {
+	return &AccountService{
+		userRepo:        userRepo,
+		todoRepo:        todoRepo,
+		templateRepo:    templateRepo,
+		timeRepo:        timeRepo,
+		feedRepo:        feedRepo,
+		appPasswordRepo: appPasswordRepo,
+		identityRepo:    identityRepo,
+		cache:           cache,
+		hub:             hub,
+		revocations:     revocations,
+	}
+}
+
+// StartExport builds an archive of all of the user's data in the background.
+// The job can be polled with GetExport and reports completion over the
+// websocket. Starting a new export replaces a finished one; while one is
+// still running, another is rejected with ErrExportInProgress.
+func (s *AccountService) StartExport(ctx context.Context, userID int) (*models.AccountExport, error) {
+	// Claim the export atomically so concurrent requests cannot both start
+	// building an archive.
+	locked, err := s.cache.SetIfAbsent(ctx, accountExportLockKey(userID), true, accountExportLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrExportInProgress
+	}
+
+	job := &models.AccountExport{
+		UserID:    userID,
+		Status:    models.AccountExportPending,
+		CreatedAt: time.Now(),
+	}
+	if err := s.saveExport(ctx, job); err != nil {
+		s.unlockExport(ctx, userID)
+		return nil, err
+	}
+	if err := s.cache.Delete(ctx, accountExportArchiveKey(userID)); err != nil {
+		s.unlockExport(ctx, userID)
+		return nil, err
+	}
+
+	snapshot := *job
+	go s.runExport(context.Background(), job)
+	return &snapshot, nil
+}
+
+func (s *AccountService) GetExport(ctx context.Context, userID int) (*models.AccountExport, error) {
+	var job models.AccountExport
+	if err := s.cache.Get(ctx, accountExportKey(userID), &job); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetExportArchive returns the zip built by the user's completed export.
+func (s *AccountService) GetExportArchive(ctx context.Context, userID int) ([]byte, error) {
+	job, err := s.GetExport(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.AccountExportCompleted {
+		return nil, ErrExportNotReady
+	}
+
+	var archive []byte
+	if err := s.cache.Get(ctx, accountExportArchiveKey(userID), &archive); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+	return archive, nil
+}
+
+func (s *AccountService) runExport(ctx context.Context, job *models.AccountExport) {
+	defer s.unlockExport(ctx, job.UserID)
+
+	job.Status = models.AccountExportRunning
+	if err := s.saveExport(ctx, job); err != nil {
+		log.Printf("Failed to save account export for user %d: %v", job.UserID, err)
+	}
+
+	archive, err := s.buildArchive(ctx, job.UserID)
+	if err == nil {
+		err = s.cache.Set(ctx, accountExportArchiveKey(job.UserID), archive, accountExportTTL)
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	event := "export.completed"
+	if err != nil {
+		log.Printf("Account export for user %d failed: %v", job.UserID, err)
+		job.Status = models.AccountExportFailed
+		job.Message = "Failed to build the export"
+		if errors.Is(err, ErrExportTooLarge) {
+			job.Message = err.Error()
+		}
+		event = "export.failed"
+	} else {
+		expiresAt := finishedAt.Add(accountExportTTL)
+		job.Status = models.AccountExportCompleted
+		job.Size = len(archive)
+		job.ExpiresAt = &expiresAt
+	}
+
+	if err := s.saveExport(ctx, job); err != nil {
+		log.Printf("Failed to save account export for user %d: %v", job.UserID, err)
+	}
+	s.hub.Broadcast <- websocket.Message{
+		Event: event,
+		Data:  *job,
+	}
+}
+
+func (s *AccountService) unlockExport(ctx context.Context, userID int) {
+	if err := s.cache.Delete(ctx, accountExportLockKey(userID)); err != nil {
+		log.Printf("Failed to release account export lock for user %d: %v", userID, err)
+	}
+}
+
+func (s *AccountService) buildArchive(ctx context.Context, userID int) ([]byte, error) {
+	files := []struct {
+		name  string
+		write func(f *bytes.Buffer) error
+	}{
+		{"README.txt", func(f *bytes.Buffer) error {
+			_, err := f.WriteString(accountExportReadme)
+			return err
+		}},
+		{"profile.json", writeJSONFile(func() (any, error) {
+			return s.userRepo.GetUserByID(ctx, userID)
+		})},
+		{"todos.json", func(f *bytes.Buffer) error {
+			writer, err := exporter.NewWriter(exporter.FormatJSON, f)
+			if err != nil {
+				return err
+			}
+			if err := writer.Begin(); err != nil {
+				return err
+			}
+			filter := models.TodoFilter{IncludeCompleted: true}
+			if err := s.todoRepo.StreamTodos(ctx, userID, filter, writer.Write); err != nil {
+				return err
+			}
+			return writer.End()
+		}},
+		{"templates.json", writeJSONFile(func() (any, error) {
+			return s.templateRepo.GetTemplates(ctx, userID)
+		})},
+		{"time_entries.json", writeJSONFile(func() (any, error) {
+			return s.timeRepo.GetTimeEntries(ctx, userID)
+		})},
+		{"calendar_feeds.json", writeJSONFile(func() (any, error) {
+			return s.feedRepo.GetFeeds(ctx, userID)
+		})},
+		{"app_passwords.json", writeJSONFile(func() (any, error) {
+			return s.appPasswordRepo.GetAppPasswords(ctx, userID)
+		})},
+		{"identities.json", writeJSONFile(func() (any, error) {
+			return s.identityRepo.GetIdentities(ctx, userID)
+		})},
+		{"history.json", writeJSONFile(func() (any, error) {
+			return s.todoRepo.GetTombstones(ctx, userID, 0, 0)
+		})},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		var f bytes.Buffer
+		if err := file.write(&f); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.name, err)
+		}
+		if buf.Len()+f.Len() > maxAccountExportSize {
+			return nil, ErrExportTooLarge
+		}
+		w, err := zw.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeJSONFile(load func() (any, error)) func(f *bytes.Buffer) error {
+	return func(f *bytes.Buffer) error {
+		v, err := load()
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+}
+
+// ScheduleDeletion marks the account for deletion once the grace period
+// ends. The user is signed out everywhere straight away: their tokens are
+// revoked, app passwords and calendar feeds stop working and open websocket
+// connections are closed.
+func (s *AccountService) ScheduleDeletion(ctx context.Context, userID int) (*models.AccountDeletion, error) {
+	at := time.Now().Add(deletionGracePeriod)
+	if err := s.userRepo.ScheduleDeletion(ctx, userID, at); err != nil {
+		return nil, err
+	}
+
+	if err := s.revocations.RevokeUserTokens(ctx, userID, accessTokenLifetime); err != nil {
+		return nil, err
+	}
+	s.hub.DisconnectUser(userID)
+
+	if err := s.purgeCache(ctx, userID); err != nil {
+		log.Printf("Failed to purge cache for user %d: %v", userID, err)
+	}
+
+	return &models.AccountDeletion{DeletionScheduledAt: at}, nil
+}
+
+// PurgeDueAccounts deletes the accounts whose grace period has ended and
+// returns how many were deleted.
+func (s *AccountService) PurgeDueAccounts(ctx context.Context) (int, error) {
+	userIDs, err := s.userRepo.GetUsersDueForDeletion(ctx, time.Now(), deletionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, userID := range userIDs {
+		if err := s.purgeCache(ctx, userID); err != nil {
+			log.Printf("Failed to purge cache for user %d: %v", userID, err)
+		}
+		if err := s.userRepo.DeleteUser(ctx, userID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return deleted, err
+		}
+		deleted++
+		log.Printf("Deleted account of user %d", userID)
+	}
+	return deleted, nil
+}
+
+// RunDeletionWorker purges due accounts every interval until ctx is done.
+func (s *AccountService) RunDeletionWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.PurgeDueAccounts(ctx); err != nil {
+				log.Printf("Failed to purge accounts due for deletion: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// purgeCache drops everything cached on the user's behalf.
+func (s *AccountService) purgeCache(ctx context.Context, userID int) error {
+	todoIDs, err := s.todoRepo.GetTodoIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, id := range todoIDs {
+		if err := s.cache.Delete(ctx, todoCacheKey(id)); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range []string{accountExportKey(userID), accountExportArchiveKey(userID)} {
+		if err := s.cache.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return s.cache.DeletePattern(ctx, "idempotency:"+strconv.Itoa(userID)+":*")
+}
+
+func (s *AccountService) saveExport(ctx context.Context, job *models.AccountExport) error {
+	return s.cache.Set(ctx, accountExportKey(job.UserID), job, accountExportTTL)
+}
+
+func accountExportKey(userID int) string {
+	return "account_export:" + strconv.Itoa(userID)
+}
+
+func accountExportLockKey(userID int) string {
+	return "account_export_lock:" + strconv.Itoa(userID)
+}
+
+func accountExportArchiveKey(userID int) string {
+	return "account_export_archive:" + strconv.Itoa(userID)
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/cauldnclark/todo-go/internal/cache"
+	"github.com/cauldnclark/todo-go/internal/ical"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/websocket"
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid username or password")
+	ErrInvalidCalendarData = errors.New("invalid calendar data")
+	ErrUIDMismatch         = errors.New("UID of an existing todo cannot be changed")
+)
+
+const appPasswordBytes = 18
+
+type CalDAVService struct {
+	todoRepo        *repository.TodoRepository
+	appPasswordRepo *repository.AppPasswordRepository
+	userRepo        *repository.UserRepository
+	cache           *cache.RedisCache
+	hub             *websocket.Hub
+}
+
+func NewCalDAVService(todoRepo *repository.TodoRepository, appPasswordRepo *repository.AppPasswordRepository, userRepo *repository.UserRepository, cache *cache.RedisCache, hub *websocket.Hub) *CalDAVService {
+	return &CalDAVService{
+		todoRepo:        todoRepo,
+		appPasswordRepo: appPasswordRepo,
+		userRepo:        userRepo,
+		cache:           cache,
+		hub:             hub,
+	}
+}
+
+func (s *CalDAVService) CreateAppPassword(ctx context.Context, userID int, req *models.CreateAppPasswordRequest) (*models.AppPassword, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "CalDAV"
+	}
+
+	password, err := generateToken(appPasswordBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	appPassword := &models.AppPassword{UserID: userID, Name: name}
+	if err := s.appPasswordRepo.CreateAppPassword(ctx, appPassword, hashToken(password)); err != nil {
+		return nil, err
+	}
+
+	appPassword.Password = password
+	return appPassword, nil
+}
+
+func (s *CalDAVService) GetAppPasswords(ctx context.Context, userID int) ([]models.AppPassword, error) {
+	return s.appPasswordRepo.GetAppPasswords(ctx, userID)
+}
+
+func (s *CalDAVService) RevokeAppPassword(ctx context.Context, userID, id int) error {
+	return s.appPasswordRepo.RevokeAppPassword(ctx, id, userID)
+}
+
+// Authenticate checks Basic auth credentials: the username is the account's
+// email address and the password one of its app passwords.
+func (s *CalDAVService) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	userID, err := s.appPasswordRepo.GetUserIDByPasswordHash(ctx, hashToken(password))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, strings.TrimSpace(username)) {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// ChangeSeq returns the user's current change sequence number, which serves
+// as the calendar's sync token and CTag.
+func (s *CalDAVService) ChangeSeq(ctx context.Context, userID int) (int64, error) {
+	return s.todoRepo.GetChangeSeq(ctx, userID)
+}
+
+// StreamTodos calls fn for every todo written after changedAfter.
+func (s *CalDAVService) StreamTodos(ctx context.Context, userID int, changedAfter int64, includeCompleted bool, fn func(*models.Todo) error) error {
+	filter := models.TodoFilter{
+		IncludeCompleted: includeCompleted,
+		ChangedAfter:     changedAfter,
+	}
+	return s.todoRepo.StreamTodos(ctx, userID, filter, fn)
+}
+
+func (s *CalDAVService) GetTombstones(ctx context.Context, userID int, changedAfter int64) ([]models.TodoTombstone, error) {
+	return s.todoRepo.GetTombstones(ctx, userID, changedAfter, 0)
+}
+
+func (s *CalDAVService) GetTodo(ctx context.Context, userID int, resource string) (*models.Todo, error) {
+	return s.todoRepo.GetTodoByResource(ctx, userID, resource, strings.TrimSuffix(resource, ".ics"))
+}
+
+// PutTodo creates or replaces the todo stored under resource with the VTODO
+// in data. ifMatch and ifNoneMatch are the request's conditional headers. It
+// reports whether a new todo was created.
+func (s *CalDAVService) PutTodo(ctx context.Context, userID int, resource string, data []byte, ifMatch, ifNoneMatch string) (*models.Todo, bool, error) {
+	parsed, err := ical.ParseTodo(data)
+	if err != nil {
+		return nil, false, errors.Join(ErrInvalidCalendarData, err)
+	}
+	if strings.TrimSpace(parsed.Title) == "" {
+		return nil, false, ErrEmptyTitle
+	}
+
+	existing, err := s.GetTodo(ctx, userID, resource)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+	if err := checkPreconditions(existing, ifMatch, ifNoneMatch); err != nil {
+		return nil, false, err
+	}
+
+	if existing == nil {
+		todo := parsed
+		todo.UserID = userID
+		todo.Labels = normalizeLabels(todo.Labels)
+		if resource != todo.ICalUID+".ics" {
+			todo.CalDAVResource = resource
+		}
+		if err := s.todoRepo.CreateTodo(ctx, todo); err != nil {
+			return nil, false, err
+		}
+
+		s.hub.Broadcast <- websocket.Message{
+			Event: "todo.created",
+			Data:  *todo,
+		}
+		return todo, true, nil
+	}
+
+	if parsed.ICalUID != existing.ICalUID {
+		return nil, false, ErrUIDMismatch
+	}
+	existing.Title = parsed.Title
+	existing.Description = parsed.Description
+	existing.Completed = parsed.Completed
+	existing.DueDate = parsed.DueDate
+	existing.Priority = parsed.Priority
+	existing.Recurrence = parsed.Recurrence
+	existing.Labels = normalizeLabels(parsed.Labels)
+	if err := s.todoRepo.UpdateTodo(ctx, existing); err != nil {
+		return nil, false, err
+	}
+	s.cache.Delete(ctx, todoCacheKey(existing.ID))
+
+	s.hub.Broadcast <- websocket.Message{
+		Event: "todo.updated",
+		Data:  *existing,
+	}
+	return existing, false, nil
+}
+
+func (s *CalDAVService) DeleteTodo(ctx context.Context, userID int, resource, ifMatch string) error {
+	todo, err := s.GetTodo(ctx, userID, resource)
+	if err != nil {
+		return err
+	}
+	if err := checkPreconditions(todo, ifMatch, ""); err != nil {
+		return err
+	}
+
+	if err := s.todoRepo.DeleteTodo(ctx, todo.ID, userID, todo.Version); err != nil {
+		return err
+	}
+	s.cache.Delete(ctx, todoCacheKey(todo.ID))
+
+	s.hub.Broadcast <- websocket.Message{
+		Event: "todo.deleted",
+		Data:  *todo,
+	}
+	return nil
+}
+
+// TodoResourceName is the file name of a todo inside the CalDAV calendar.
+func TodoResourceName(todo *models.Todo) string {
+	if todo.CalDAVResource != "" {
+		return todo.CalDAVResource
+	}
+	return ical.TodoUID(todo) + ".ics"
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+)
+
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// TodoETag is the entity tag of a todo, in JSON or as a calendar object. It
+// changes with every update.
+func TodoETag(todo *models.Todo) string {
+	return `"` + strconv.Itoa(todo.Version) + `"`
+}
+
+// NoneMatch reports whether an If-None-Match header value lists etag, using
+// the weak comparison RFC 9110 prescribes for it.
+func NoneMatch(header, etag string) bool {
+	return listsETag(header, etag, true)
+}
+
+// checkPreconditions evaluates If-Match and If-None-Match against the current
+// todo, which is nil when it does not exist.
+func checkPreconditions(todo *models.Todo, ifMatch, ifNoneMatch string) error {
+	if ifNoneMatch != "" && todo != nil && listsETag(ifNoneMatch, TodoETag(todo), true) {
+		return ErrPreconditionFailed
+	}
+	if ifMatch == "" {
+		return nil
+	}
+	if todo == nil || !listsETag(ifMatch, TodoETag(todo), false) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// listsETag reports whether header, a "*" or a comma separated list of entity
+// tags, matches etag. Strong comparison never matches weak tags.
+func listsETag(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if weak {
+		etag = strings.TrimPrefix(etag, "W/")
+	} else if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/ical"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+)
+
+var ErrInvalidFeed = errors.New("component must be one of: vtodo, vevent, both")
+
+const feedTokenBytes = 32
+
+type FeedService struct {
+	feedRepo  *repository.FeedRepository
+	todoRepo  *repository.TodoRepository
+	publicURL string
+}
+
+func NewFeedService(feedRepo *repository.FeedRepository, todoRepo *repository.TodoRepository, publicURL string) *FeedService {
+	return &FeedService{
+		feedRepo:  feedRepo,
+		todoRepo:  todoRepo,
+		publicURL: strings.TrimRight(publicURL, "/"),
+	}
+}
+
+func (s *FeedService) CreateFeed(ctx context.Context, userID int, req *models.CreateFeedRequest) (*models.CalendarFeed, error) {
+	component := req.Component
+	if component == "" {
+		component = models.FeedComponentTodo
+	}
+	if component != models.FeedComponentTodo && component != models.FeedComponentEvent && component != models.FeedComponentBoth {
+		return nil, ErrInvalidFeed
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Todos"
+	}
+
+	token, err := generateToken(feedTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &models.CalendarFeed{
+		UserID:           userID,
+		Name:             name,
+		Label:            strings.ToLower(strings.TrimPrefix(strings.TrimSpace(req.Label), "#")),
+		IncludeCompleted: req.IncludeCompleted,
+		Component:        component,
+	}
+	if err := s.feedRepo.CreateFeed(ctx, feed, hashToken(token)); err != nil {
+		return nil, err
+	}
+
+	feed.URL = s.feedURL(token)
+	return feed, nil
+}
+
+func (s *FeedService) GetFeeds(ctx context.Context, userID int) ([]models.CalendarFeed, error) {
+	return s.feedRepo.GetFeeds(ctx, userID)
+}
+
+// RotateFeedToken replaces the feed's secret; the old URL stops working
+// immediately.
+func (s *FeedService) RotateFeedToken(ctx context.Context, userID, feedID int) (*models.CalendarFeed, error) {
+	token, err := generateToken(feedTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	feed, err := s.feedRepo.RotateToken(ctx, feedID, userID, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	feed.URL = s.feedURL(token)
+	return feed, nil
+}
+
+func (s *FeedService) RevokeFeed(ctx context.Context, userID, feedID int) error {
+	return s.feedRepo.RevokeFeed(ctx, feedID, userID)
+}
+
+// WriteFeed streams the calendar for the feed identified by token. It
+// returns sql.ErrNoRows for unknown or revoked tokens.
+func (s *FeedService) WriteFeed(ctx context.Context, token string, w io.Writer) error {
+	feed, err := s.feedRepo.GetFeedByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+
+	filter := models.TodoFilter{
+		IncludeCompleted: feed.IncludeCompleted,
+		Label:            feed.Label,
+		OnlyWithDueDate:  true,
+	}
+
+	stamp := time.Now()
+	writer := ical.NewWriter(w)
+	writer.BeginCalendar(feed.Name)
+	err = s.todoRepo.StreamTodos(ctx, feed.UserID, filter, func(todo *models.Todo) error {
+		if feed.Component != models.FeedComponentEvent {
+			writer.WriteTodo(todo, stamp)
+		}
+		if feed.Component != models.FeedComponentTodo {
+			writer.WriteEvent(todo, stamp)
+		}
+		return writer.Err()
+	})
+	if err != nil {
+		return err
+	}
+	writer.EndCalendar()
+
+	return writer.Err()
+}
+
+func (s *FeedService) feedURL(token string) string {
+	return s.publicURL + "/feeds/" + token + ".ics"
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/oidc"
+)
+
+var (
+	ErrUnknownProvider      = errors.New("unknown sign-in provider")
+	ErrEmailNotVerified     = errors.New("email address is not verified")
+	ErrExternalSignInFailed = errors.New("signing in with the provider failed")
+)
+
+// IdentityProvider is an external service users sign in with through the
+// OAuth authorization code flow.
+type IdentityProvider interface {
+	// Name identifies the provider in URLs and stored identities.
+	Name() string
+	RedirectURI() string
+	// AuthorizationURL is where to send the user to sign in. It carries the
+	// state and the flow's PKCE challenge.
+	AuthorizationURL(ctx context.Context, flow *OAuthFlow, state string) (string, error)
+	// Exchange redeems an authorization code and returns the account the
+	// user signed in as.
+	Exchange(ctx context.Context, code string, flow *OAuthFlow) (*models.ExternalUser, error)
+}
+
+// IdentityProviders are the configured providers by name.
+type IdentityProviders map[string]IdentityProvider
+
+func NewIdentityProviders(providers ...IdentityProvider) IdentityProviders {
+	byName := make(IdentityProviders, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return byName
+}
+
+func (p IdentityProviders) Get(name string) (IdentityProvider, error) {
+	provider, ok := p[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// Names returns the names of the providers in alphabetical order.
+func (p IdentityProviders) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OAuthClient is this app's registration with a provider.
+type OAuthClient struct {
+	ID          string
+	Secret      string
+	RedirectURI string
+}
+
+var oauthHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// OIDCIdentityProvider signs users in with an OpenID Connect provider such as
+// Google or Microsoft. The user is read from the verified ID token.
+type OIDCIdentityProvider struct {
+	name   string
+	client OAuthClient
+	issuer *oidc.Provider
+	// requireVerifiedEmail rejects users whose email address the provider
+	// has not verified. Providers that never send email_verified, like
+	// Microsoft, must leave it off.
+	requireVerifiedEmail bool
+}
+
+func NewOIDCIdentityProvider(name string, client OAuthClient, issuer *oidc.Provider, requireVerifiedEmail bool) *OIDCIdentityProvider {
+	return &OIDCIdentityProvider{
+		name:                 name,
+		client:               client,
+		issuer:               issuer,
+		requireVerifiedEmail: requireVerifiedEmail,
+	}
+}
+
+func (p *OIDCIdentityProvider) Name() string {
+	return p.name
+}
+
+func (p *OIDCIdentityProvider) RedirectURI() string {
+	return p.client.RedirectURI
+}
+
+func (p *OIDCIdentityProvider) AuthorizationURL(ctx context.Context, flow *OAuthFlow, state string) (string, error) {
+	metadata, err := p.issuer.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	return authorizationURL(metadata.AuthorizationEndpoint, p.client, "openid email profile", flow, state), nil
+}
+
+func (p *OIDCIdentityProvider) Exchange(ctx context.Context, code string, flow *OAuthFlow) (*models.ExternalUser, error) {
+	metadata, err := p.issuer.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokenResp, err := exchangeCode(ctx, metadata.TokenEndpoint, p.client, code, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenResp.IDToken == "" {
+		return nil, oidc.ErrInvalidIDToken
+	}
+	claims, err := p.issuer.VerifyIDToken(ctx, tokenResp.IDToken, p.client.ID)
+	if err != nil {
+		return nil, err
+	}
+	if p.requireVerifiedEmail && !bool(claims.EmailVerified) {
+		return nil, ErrEmailNotVerified
+	}
+
+	return &models.ExternalUser{
+		Subject:    claims.Subject,
+		Email:      claims.Email,
+		Name:       claims.Name,
+		PictureURL: claims.Picture,
+	}, nil
+}
+
+const (
+	gitHubAuthURL  = "https://github.com/login/oauth/authorize"
+	gitHubTokenURL = "https://github.com/login/oauth/access_token"
+	gitHubAPIURL   = "https://api.github.com"
+)
+
+// GitHubIdentityProvider signs users in with GitHub, which speaks plain
+// OAuth rather than OpenID Connect: the user is read from the REST API.
+type GitHubIdentityProvider struct {
+	client OAuthClient
+}
+
+func NewGitHubIdentityProvider(client OAuthClient) *GitHubIdentityProvider {
+	return &GitHubIdentityProvider{client: client}
+}
+
+func (p *GitHubIdentityProvider) Name() string {
+	return "github"
+}
+
+func (p *GitHubIdentityProvider) RedirectURI() string {
+	return p.client.RedirectURI
+}
+
+func (p *GitHubIdentityProvider) AuthorizationURL(ctx context.Context, flow *OAuthFlow, state string) (string, error) {
+	return authorizationURL(gitHubAuthURL, p.client, "read:user user:email", flow, state), nil
+}
+
+// Exchange requires a verified primary email address, since GitHub lets
+// users add addresses they do not own.
+func (p *GitHubIdentityProvider) Exchange(ctx context.Context, code string, flow *OAuthFlow) (*models.ExternalUser, error) {
+	tokenResp, err := exchangeCode(ctx, gitHubTokenURL, p.client, code, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := p.get(ctx, tokenResp.AccessToken, "/user", &profile); err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, tokenResp.AccessToken, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	user := &models.ExternalUser{
+		Subject:    strconv.FormatInt(profile.ID, 10),
+		Name:       profile.Name,
+		PictureURL: profile.AvatarURL,
+	}
+	if user.Name == "" {
+		user.Name = profile.Login
+	}
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			user.Email = email.Email
+		}
+	}
+	if user.Email == "" {
+		return nil, ErrEmailNotVerified
+	}
+	return user, nil
+}
+
+func (p *GitHubIdentityProvider) get(ctx context.Context, accessToken, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gitHubAPIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("github %s request failed with status %d: %s", path, resp.StatusCode, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func authorizationURL(endpoint string, client OAuthClient, scope string, flow *OAuthFlow, state string) string {
+	query := url.Values{}
+	query.Set("client_id", client.ID)
+	query.Set("redirect_uri", flow.RedirectURI)
+	query.Set("response_type", "code")
+	query.Set("scope", scope)
+	query.Set("state", state)
+	query.Set("code_challenge", flow.CodeChallenge())
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + query.Encode()
+}
+
+// exchangeCode redeems an authorization code at the provider's token
+// endpoint, proving with the PKCE verifier that this server started the flow.
+func exchangeCode(ctx context.Context, tokenURL string, client OAuthClient, code string, flow *OAuthFlow) (*models.OAuthTokenResponse, error) {
+	data := url.Values{}
+	data.Set("client_id", client.ID)
+	data.Set("client_secret", client.Secret)
+	data.Set("code", code)
+	data.Set("grant_type", "authorization_code")
+	data.Set("redirect_uri", flow.RedirectURI)
+	data.Set("code_verifier", flow.CodeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		log.Printf("Token exchange with %s failed with status %d: %s", tokenURL, resp.StatusCode, string(body))
+		return nil, fmt.Errorf("token exchange failed with status %d", resp.StatusCode)
+	}
+
+	var tokenResp models.OAuthTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tokenResp.Error != "" {
+		return nil, fmt.Errorf("token exchange failed: %s: %s", tokenResp.Error, tokenResp.ErrorDescription)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, errors.New("token exchange returned no access token")
+	}
+	return &tokenResp, nil
+}
+
+// exchangeCodeWith redeems the code with the provider. Failures other than
+// an unverified email address are logged and reported as
+// ErrExternalSignInFailed, since their details are not meant for clients.
+func exchangeCodeWith(ctx context.Context, provider IdentityProvider, code string, flow *OAuthFlow) (*models.ExternalUser, error) {
+	external, err := provider.Exchange(ctx, code, flow)
+	if err != nil {
+		if errors.Is(err, ErrEmailNotVerified) {
+			return nil, err
+		}
+		log.Printf("Signing in with %s failed: %v", provider.Name(), err)
+		return nil, ErrExternalSignInFailed
+	}
+	return external, nil
+}
+
+// startAuthorization begins a flow with the provider and returns where to
+// send the user. userID is zero for sign-ins.
+func startAuthorization(ctx context.Context, states *OAuthStateStore, provider IdentityProvider, userID int) (*models.AuthorizationStart, error) {
+	flow, state, err := states.Begin(ctx, provider.Name(), provider.RedirectURI(), userID)
+	if err != nil {
+		return nil, err
+	}
+	authURL, err := provider.AuthorizationURL(ctx, flow, state)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuthorizationStart{
+		AuthorizationURL: authURL,
+		State:            state,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+)
+
+// IdentityService manages the provider accounts a user can sign in with.
+type IdentityService struct {
+	identityRepo *repository.IdentityRepository
+	states       *OAuthStateStore
+	providers    IdentityProviders
+}
+
+func NewIdentityService(identityRepo *repository.IdentityRepository, states *OAuthStateStore, providers IdentityProviders) *IdentityService {
+	return &IdentityService{
+		identityRepo: identityRepo,
+		states:       states,
+		providers:    providers,
+	}
+}
+
+func (s *IdentityService) GetIdentities(ctx context.Context, userID int) ([]models.Identity, error) {
+	return s.identityRepo.GetIdentities(ctx, userID)
+}
+
+// StartLink begins linking an account with the provider to the user. The
+// flow is finished by Link, which only this user can do.
+func (s *IdentityService) StartLink(ctx context.Context, userID int, providerName string) (*models.AuthorizationStart, error) {
+	provider, err := s.providers.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return startAuthorization(ctx, s.states, provider, userID)
+}
+
+// Link finishes a flow started by StartLink and links the provider account
+// the user signed in as. It returns repository.ErrIdentityInUse if the
+// account is linked already, to this user or another one.
+func (s *IdentityService) Link(ctx context.Context, userID int, req *models.LinkIdentityRequest) (*models.Identity, error) {
+	provider, err := s.providers.Get(req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	flow, err := s.states.Finish(ctx, provider.Name(), req.State, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	external, err := exchangeCodeWith(ctx, provider, req.Code, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &models.Identity{
+		UserID:   userID,
+		Provider: provider.Name(),
+		Subject:  external.Subject,
+		Email:    external.Email,
+	}
+	if err := s.identityRepo.CreateIdentity(ctx, identity); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// Unlink removes one of the user's identities. The last one cannot be
+// removed.
+func (s *IdentityService) Unlink(ctx context.Context, userID, id int) error {
+	return s.identityRepo.DeleteIdentity(ctx, userID, id)
+}
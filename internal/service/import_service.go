@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/cache"
+	"github.com/cauldnclark/todo-go/internal/importer"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/validation"
+	"github.com/cauldnclark/todo-go/internal/websocket"
+)
+
+var (
+	ErrInvalidImportFile = errors.New("invalid import file")
+	ErrImportInProgress  = errors.New("an import is already running")
+)
+
+const (
+	importJobTTL = 24 * time.Hour
+	// importLockTTL frees a user's import lock if the server stops before
+	// the import finishes.
+	importLockTTL = time.Hour
+	// Imports up to this many records finish before the request returns;
+	// larger files are processed in the background.
+	importInlineLimit   = 100
+	importProgressEvery = 50
+)
+
+type ImportService struct {
+	todoRepo *repository.TodoRepository
+	cache    *cache.RedisCache
+	hub      *websocket.Hub
+}
+
+func NewImportService(todoRepo *repository.TodoRepository, cache *cache.RedisCache, hub *websocket.Hub) *ImportService {
+	return &ImportService{
+		todoRepo: todoRepo,
+		cache:    cache,
+		hub:      hub,
+	}
+}
+
+// StartImport parses data and creates a todo for every valid record. Small
+// files are imported synchronously; larger ones continue in the background
+// and report progress over the websocket. Either way the returned job can be
+// polled with GetImportJob. A user's second import is rejected with
+// ErrImportInProgress until the first one finishes.
+func (s *ImportService) StartImport(ctx context.Context, userID int, format, contentType string, data []byte) (*models.ImportJob, error) {
+	if format == "" {
+		format = importer.Detect(data, contentType)
+	}
+
+	records, err := importer.Parse(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+
+	jobID, err := newImportJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each user runs one import at a time, so uploads cannot pile up
+	// background work.
+	locked, err := s.cache.SetIfAbsent(ctx, importLockKey(userID), jobID, importLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrImportInProgress
+	}
+
+	job := &models.ImportJob{
+		ID:        jobID,
+		UserID:    userID,
+		Format:    format,
+		Status:    models.ImportStatusPending,
+		Total:     len(records),
+		Errors:    []models.ImportRowError{},
+		CreatedAt: time.Now(),
+	}
+	if err := s.saveJob(ctx, job); err != nil {
+		s.unlock(ctx, userID)
+		return nil, err
+	}
+
+	if len(records) <= importInlineLimit {
+		s.runImport(ctx, job, records)
+		return job, nil
+	}
+
+	snapshot := *job
+	go s.runImport(context.Background(), job, records)
+	return &snapshot, nil
+}
+
+func (s *ImportService) GetImportJob(ctx context.Context, userID int, jobID string) (*models.ImportJob, error) {
+	var job models.ImportJob
+	if err := s.cache.Get(ctx, importJobKey(jobID), &job); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+	if job.UserID != userID {
+		return nil, sql.ErrNoRows
+	}
+	return &job, nil
+}
+
+func (s *ImportService) runImport(ctx context.Context, job *models.ImportJob, records []importer.Record) {
+	defer s.unlock(ctx, job.UserID)
+
+	job.Status = models.ImportStatusRunning
+	s.publishProgress(ctx, job, "import.progress")
+
+	for _, record := range records {
+		if err := s.importRecord(ctx, job.UserID, record); err != nil {
+			job.Failed++
+			job.Errors = append(job.Errors, models.ImportRowError{
+				Row:     record.Row,
+				Title:   record.Todo.Title,
+				Message: err.Error(),
+			})
+		} else {
+			job.Imported++
+		}
+		job.Processed++
+
+		if job.Processed%importProgressEvery == 0 && job.Processed < job.Total {
+			s.publishProgress(ctx, job, "import.progress")
+		}
+	}
+
+	finishedAt := time.Now()
+	job.Status = models.ImportStatusCompleted
+	job.FinishedAt = &finishedAt
+	s.publishProgress(ctx, job, "import.completed")
+
+	log.Printf("Import %s for user %d finished: %d imported, %d failed", job.ID, job.UserID, job.Imported, job.Failed)
+}
+
+func (s *ImportService) importRecord(ctx context.Context, userID int, record importer.Record) error {
+	if record.Err != nil {
+		return record.Err
+	}
+
+	// Imported todos follow the same rules as todos created through the API.
+	req := models.CreateTodoRequest{
+		Title:       record.Todo.Title,
+		Description: record.Todo.Description,
+		DueDate:     record.Todo.DueDate,
+		Priority:    record.Todo.Priority,
+		Recurrence:  record.Todo.Recurrence,
+		Labels:      record.Todo.Labels,
+	}
+	if err := validation.Struct(&req); err != nil {
+		return err
+	}
+	if req.Priority == "" {
+		req.Priority = models.PriorityNone
+	}
+
+	todo := &models.Todo{
+		UserID:      userID,
+		Title:       req.Title,
+		Description: req.Description,
+		Completed:   record.Todo.Completed,
+		DueDate:     req.DueDate,
+		Priority:    req.Priority,
+		Recurrence:  req.Recurrence,
+		Labels:      normalizeLabels(req.Labels),
+	}
+
+	if err := s.todoRepo.CreateTodo(ctx, todo); err != nil {
+		log.Printf("Import failed to create todo for user %d: %v", userID, err)
+		return errors.New("failed to save todo")
+	}
+	return nil
+}
+
+func (s *ImportService) publishProgress(ctx context.Context, job *models.ImportJob, event string) {
+	if err := s.saveJob(ctx, job); err != nil {
+		log.Printf("Failed to save import job %s: %v", job.ID, err)
+	}
+
+	s.hub.Broadcast <- websocket.Message{
+		Event: event,
+		Data:  *job,
+	}
+}
+
+func (s *ImportService) saveJob(ctx context.Context, job *models.ImportJob) error {
+	return s.cache.Set(ctx, importJobKey(job.ID), job, importJobTTL)
+}
+
+func (s *ImportService) unlock(ctx context.Context, userID int) {
+	if err := s.cache.Delete(ctx, importLockKey(userID)); err != nil {
+		log.Printf("Failed to release import lock for user %d: %v", userID, err)
+	}
+}
+
+func importJobKey(jobID string) string {
+	return "import_job:" + jobID
+}
+
+func importLockKey(userID int) string {
+	return fmt.Sprintf("import_lock:%d", userID)
+}
+
+func newImportJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
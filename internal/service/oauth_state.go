@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/cache"
+)
+
+var ErrInvalidOAuthState = errors.New("sign-in state is invalid or expired")
+
+// oauthStateTTL is how long a user has to finish signing in with the
+// provider once the flow has started.
+const oauthStateTTL = 10 * time.Minute
+
+// OAuthFlow is what the server keeps about a sign-in between sending the
+// user to the provider and getting the authorization code back.
+// UserID is set when a signed-in user is linking another identity.
+type OAuthFlow struct {
+	Provider     string `json:"provider"`
+	RedirectURI  string `json:"redirect_uri"`
+	CodeVerifier string `json:"code_verifier"`
+	UserID       int    `json:"user_id,omitempty"`
+}
+
+// CodeChallenge is the PKCE S256 challenge for the flow's verifier.
+func (f *OAuthFlow) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(f.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// OAuthStateStore issues the state parameter of an authorization request and
+// checks it when the user comes back. A state is a random nonce signed by the
+// server; the flow it started is kept in Redis and can be finished once.
+type OAuthStateStore struct {
+	cache  *cache.RedisCache
+	secret []byte
+}
+
+func NewOAuthStateStore(cache *cache.RedisCache, secret string) *OAuthStateStore {
+	return &OAuthStateStore{
+		cache:  cache,
+		secret: []byte(secret),
+	}
+}
+
+// Begin starts a flow for the provider with a new PKCE verifier and returns
+// it with the state to send along with the authorization request. userID is
+// zero for sign-ins.
+func (s *OAuthStateStore) Begin(ctx context.Context, provider, redirectURI string, userID int) (*OAuthFlow, string, error) {
+	nonce, err := generateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	verifier, err := generateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	flow := &OAuthFlow{
+		Provider:     provider,
+		RedirectURI:  redirectURI,
+		CodeVerifier: verifier,
+		UserID:       userID,
+	}
+	if err := s.cache.Set(ctx, oauthStateKey(nonce), flow, oauthStateTTL); err != nil {
+		return nil, "", err
+	}
+	return flow, nonce + "." + s.sign(nonce), nil
+}
+
+// Finish checks the state returned by the provider and ends the flow it
+// began. It returns ErrInvalidOAuthState for forged, expired or reused
+// states and for states issued for another provider or user.
+func (s *OAuthStateStore) Finish(ctx context.Context, provider, state string, userID int) (*OAuthFlow, error) {
+	nonce, signature, ok := strings.Cut(state, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(nonce))) {
+		return nil, ErrInvalidOAuthState
+	}
+
+	var flow OAuthFlow
+	if err := s.cache.GetDelete(ctx, oauthStateKey(nonce), &flow); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, ErrInvalidOAuthState
+		}
+		return nil, err
+	}
+	if flow.Provider != provider || flow.UserID != userID {
+		return nil, ErrInvalidOAuthState
+	}
+	return &flow, nil
+}
+
+func (s *OAuthStateStore) sign(nonce string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("oauth-state:" + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func oauthStateKey(nonce string) string {
+	return "oauth_state:" + nonce
+}
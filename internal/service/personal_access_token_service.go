@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+)
+
+var ErrScopeNotGranted = errors.New("a token cannot be given scopes its creator does not have")
+
+const personalAccessTokenBytes = 32
+
+type PersonalAccessTokenService struct {
+	tokenRepo *repository.PersonalAccessTokenRepository
+}
+
+func NewPersonalAccessTokenService(tokenRepo *repository.PersonalAccessTokenRepository) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{
+		tokenRepo: tokenRepo,
+	}
+}
+
+// CreateToken issues a new token with scopes from granted, the scopes of the
+// caller's own token. The returned token is the only time its value is
+// available; only its hash is stored.
+func (s *PersonalAccessTokenService) CreateToken(ctx context.Context, userID int, granted []string, req *models.CreatePersonalAccessTokenRequest) (*models.PersonalAccessToken, error) {
+	for _, scope := range req.Scopes {
+		if !slices.Contains(granted, scope) {
+			return nil, ErrScopeNotGranted
+		}
+	}
+
+	secret, err := generateToken(personalAccessTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+	value := models.PersonalAccessTokenPrefix + secret
+
+	token := &models.PersonalAccessToken{
+		UserID: userID,
+		Name:   req.Name,
+		Scopes: uniqueScopes(req.Scopes),
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := s.tokenRepo.CreateToken(ctx, token, hashToken(value)); err != nil {
+		return nil, err
+	}
+
+	token.Token = value
+	return token, nil
+}
+
+func (s *PersonalAccessTokenService) GetTokens(ctx context.Context, userID int) ([]models.PersonalAccessToken, error) {
+	return s.tokenRepo.GetTokens(ctx, userID)
+}
+
+func (s *PersonalAccessTokenService) RevokeToken(ctx context.Context, userID, id int) error {
+	return s.tokenRepo.RevokeToken(ctx, id, userID)
+}
+
+// AuthenticatePersonalAccessToken resolves a token presented as a bearer
+// token and records that it was used. Unknown, expired and revoked tokens
+// are reported as sql.ErrNoRows.
+func (s *PersonalAccessTokenService) AuthenticatePersonalAccessToken(ctx context.Context, value string) (*models.PersonalAccessToken, error) {
+	if !strings.HasPrefix(value, models.PersonalAccessTokenPrefix) {
+		return nil, sql.ErrNoRows
+	}
+	return s.tokenRepo.UseToken(ctx, hashToken(value))
+}
+
+// uniqueScopes drops duplicate scopes and sorts the rest in documented order.
+func uniqueScopes(scopes []string) []string {
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range models.AllScopes {
+		for _, requested := range scopes {
+			if requested == scope {
+				unique = append(unique, scope)
+				break
+			}
+		}
+	}
+	return unique
+}
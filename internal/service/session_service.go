@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/auth"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/revocation"
+	"github.com/cauldnclark/todo-go/internal/websocket"
+)
+
+var ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+
+const (
+	// Access tokens are short-lived; clients renew them with the refresh
+	// token, which is rotated on every use.
+	accessTokenLifetime  = 15 * time.Minute
+	refreshTokenLifetime = 30 * 24 * time.Hour
+	refreshTokenBytes    = 32
+	sessionIDBytes       = 16
+)
+
+// SessionService issues the tokens for a sign-in and keeps track of the
+// devices they are used on.
+type SessionService struct {
+	sessionRepo *repository.SessionRepository
+	refreshRepo *repository.RefreshTokenRepository
+	userRepo    *repository.UserRepository
+	revocations *revocation.Store
+	hub         *websocket.Hub
+	tokens      *auth.Manager
+}
+
+func NewSessionService(sessionRepo *repository.SessionRepository, refreshRepo *repository.RefreshTokenRepository, userRepo *repository.UserRepository, revocations *revocation.Store, hub *websocket.Hub, tokens *auth.Manager) *SessionService {
+	return &SessionService{
+		sessionRepo: sessionRepo,
+		refreshRepo: refreshRepo,
+		userRepo:    userRepo,
+		revocations: revocations,
+		hub:         hub,
+		tokens:      tokens,
+	}
+}
+
+// StartSession signs the user in on the client's device.
+func (s *SessionService) StartSession(ctx context.Context, user *models.User, client models.SessionClient) (*models.AuthResponse, error) {
+	sessionID, err := generateToken(sessionIDBytes)
+	if err != nil {
+		return nil, err
+	}
+	session := &models.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+	}
+	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	refreshToken, err := generateToken(refreshTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+	refresh := &models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  session.ID,
+		ExpiresAt: time.Now().Add(refreshTokenLifetime),
+	}
+	if err := s.refreshRepo.CreateRefreshToken(ctx, refresh, hashToken(refreshToken)); err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return s.authResponse(ctx, user, session.ID, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Each refresh token works once: presenting it again ends the session
+// it belongs to.
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string, client models.SessionClient) (*models.AuthResponse, error) {
+	nextToken, err := generateToken(refreshTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+	next := &models.RefreshToken{ExpiresAt: time.Now().Add(refreshTokenLifetime)}
+	if err := s.refreshRepo.RotateRefreshToken(ctx, hashToken(refreshToken), next, hashToken(nextToken)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		if errors.Is(err, repository.ErrRefreshTokenReused) {
+			log.Printf("Refresh token reuse detected for user %d, session %s revoked", next.UserID, next.FamilyID)
+			s.endSession(ctx, next.UserID, next.FamilyID)
+		}
+		return nil, err
+	}
+
+	if err := s.sessionRepo.TouchSession(ctx, next.FamilyID, client); err != nil {
+		log.Printf("Failed to update session %s: %v", next.FamilyID, err)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, next.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authResponse(ctx, user, next.FamilyID, nextToken)
+}
+
+// Logout ends the session the refresh token belongs to. Unknown tokens are
+// ignored.
+func (s *SessionService) Logout(ctx context.Context, refreshToken string) error {
+	session, err := s.sessionRepo.GetSessionByRefreshTokenHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+	return s.RevokeSession(ctx, session.UserID, session.ID)
+}
+
+// GetSessions lists the user's active sessions, flagging the one with the
+// given ID as current.
+func (s *SessionService) GetSessions(ctx context.Context, userID int, currentID string) ([]models.Session, error) {
+	sessions, err := s.sessionRepo.GetSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Device = deviceName(sessions[i].UserAgent)
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs the session out immediately: its refresh tokens stop
+// working, its access tokens are denied and its websocket connections are
+// closed.
+func (s *SessionService) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	if err := s.sessionRepo.RevokeSession(ctx, sessionID, userID); err != nil {
+		return err
+	}
+	s.endSession(ctx, userID, sessionID)
+	return nil
+}
+
+// endSession denies the session's access tokens and closes its connections.
+func (s *SessionService) endSession(ctx context.Context, userID int, sessionID string) {
+	if err := s.revocations.RevokeSession(ctx, sessionID, accessTokenLifetime); err != nil {
+		log.Printf("Failed to revoke access tokens of session %s: %v", sessionID, err)
+	}
+	s.hub.DisconnectSession(userID, sessionID)
+}
+
+// authResponse issues an access token for a signed-in user, who may do
+// anything their account can.
+func (s *SessionService) authResponse(ctx context.Context, user *models.User, sessionID, refreshToken string) (*models.AuthResponse, error) {
+	token, claims, err := s.tokens.Issue(user.ID, sessionID, models.AllScopes, accessTokenLifetime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate jwt: %w", err)
+	}
+	if err := s.revocations.TrackToken(ctx, sessionID, claims.ID, accessTokenLifetime); err != nil {
+		return nil, fmt.Errorf("failed to track token: %w", err)
+	}
+
+	return &models.AuthResponse{
+		User:         user,
+		Token:        token,
+		ExpiresIn:    int(accessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// deviceName gives a short description of the browser and operating system
+// in a User-Agent header, such as "Firefox on Linux".
+func deviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			return browser + " on " + o.name
+		}
+	}
+	return browser
+}
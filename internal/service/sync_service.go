@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/cauldnclark/todo-go/internal/cache"
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/validation"
+	"github.com/cauldnclark/todo-go/internal/websocket"
+)
+
+var (
+	ErrInvalidSyncToken  = errors.New("invalid sync token")
+	ErrInvalidOnConflict = errors.New("on_conflict must be one of: server_wins, client_wins")
+	ErrTooManyMutations  = errors.New("too many mutations in one batch")
+)
+
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 1000
+	maxSyncMutations = 500
+)
+
+// SyncService implements delta sync for offline-first clients on top of the
+// todo change sequence. Sync tokens are opaque to clients. Only todos carry
+// changes and tombstones: there are no projects, and labels live on todos,
+// so the full label set is returned with every page instead.
+type SyncService struct {
+	todoRepo *repository.TodoRepository
+	cache    *cache.RedisCache
+	hub      *websocket.Hub
+}
+
+func NewSyncService(todoRepo *repository.TodoRepository, cache *cache.RedisCache, hub *websocket.Hub) *SyncService {
+	return &SyncService{
+		todoRepo: todoRepo,
+		cache:    cache,
+		hub:      hub,
+	}
+}
+
+// GetChanges returns todos written and deleted after since, oldest first and
+// at most limit of them together. An empty since starts a full sync.
+func (s *SyncService) GetChanges(ctx context.Context, userID int, since string, limit int) (*models.SyncChanges, error) {
+	seq, err := parseSyncToken(since)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultSyncLimit
+	}
+	if limit > maxSyncLimit {
+		limit = maxSyncLimit
+	}
+
+	// Read the current position first: anything committed while the changes
+	// are collected gets a higher sequence number and is sent next time.
+	current, err := s.todoRepo.GetChangeSeq(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := s.todoRepo.GetTodosChangedSince(ctx, userID, seq, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	tombstones := []models.TodoTombstone{}
+	// A full sync only needs what exists.
+	if seq > 0 {
+		tombstones, err = s.todoRepo.GetTombstones(ctx, userID, seq, limit+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	labels, err := s.todoRepo.GetLabels(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &models.SyncChanges{
+		Todos:   []models.Todo{},
+		Deleted: []models.TodoTombstone{},
+		Labels:  labels,
+	}
+
+	// Merge both lists in change order and stop at the limit; the token
+	// then points at the last change included.
+	last := current
+	i, j := 0, 0
+	for n := 0; n < limit && (i < len(todos) || j < len(tombstones)); n++ {
+		if j >= len(tombstones) || (i < len(todos) && todos[i].ChangeSeq < tombstones[j].ChangeSeq) {
+			changes.Todos = append(changes.Todos, todos[i])
+			last = todos[i].ChangeSeq
+			i++
+		} else {
+			changes.Deleted = append(changes.Deleted, tombstones[j])
+			last = tombstones[j].ChangeSeq
+			j++
+		}
+	}
+	changes.HasMore = i < len(todos) || j < len(tombstones)
+	if !changes.HasMore && current > last {
+		last = current
+	}
+	changes.SyncToken = formatSyncToken(last)
+
+	return changes, nil
+}
+
+// ApplyMutations applies offline changes in order. Each mutation succeeds or
+// fails on its own:
+//
+//   - create is idempotent on client_id; repeating it returns the todo
+//     created the first time.
+//   - update and delete of a todo changed on the server after base_token
+//     are conflicts unless on_conflict is client_wins. With server_wins the
+//     result carries the server's version for the client to keep.
+//   - update and delete of a todo that no longer exists report not_found.
+func (s *SyncService) ApplyMutations(ctx context.Context, userID int, req *models.SyncPushRequest) (*models.SyncPushResponse, error) {
+	onConflict := req.OnConflict
+	if onConflict == "" {
+		onConflict = models.SyncServerWins
+	}
+	if onConflict != models.SyncServerWins && onConflict != models.SyncClientWins {
+		return nil, ErrInvalidOnConflict
+	}
+	if len(req.Mutations) > maxSyncMutations {
+		return nil, ErrTooManyMutations
+	}
+
+	results := make([]models.SyncMutationResult, 0, len(req.Mutations))
+	for _, mutation := range req.Mutations {
+		result, err := s.applyMutation(ctx, userID, mutation, onConflict)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	current, err := s.todoRepo.GetChangeSeq(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SyncPushResponse{
+		Results:   results,
+		SyncToken: formatSyncToken(current),
+	}, nil
+}
+
+// applyMutation returns an error only for failures that should abort the
+// whole batch, such as the database being unavailable.
+func (s *SyncService) applyMutation(ctx context.Context, userID int, mutation models.SyncMutation, onConflict string) (models.SyncMutationResult, error) {
+	result := models.SyncMutationResult{ClientID: mutation.ClientID, ID: mutation.ID}
+	invalid := func(err error) (models.SyncMutationResult, error) {
+		result.Status = models.SyncStatusInvalid
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	if mutation.Op == models.SyncOpCreate {
+		if mutation.ClientID == "" {
+			return invalid(errors.New("client_id is required"))
+		}
+		if err := validateSyncTodo(mutation.Todo); err != nil {
+			return invalid(err)
+		}
+
+		todo, err := s.todoRepo.GetTodoByClientID(ctx, userID, mutation.ClientID)
+		if err == nil {
+			result.ID, result.Status, result.Todo = todo.ID, models.SyncStatusApplied, todo
+			return result, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return result, err
+		}
+
+		todo = &models.Todo{UserID: userID, ClientID: mutation.ClientID}
+		applySyncTodo(todo, mutation.Todo)
+		if err := s.todoRepo.CreateTodo(ctx, todo); err != nil {
+			// Lost a race with the same mutation sent twice.
+			if errors.Is(err, repository.ErrClientIDConflict) {
+				return s.applyMutation(ctx, userID, mutation, onConflict)
+			}
+			return result, err
+		}
+		s.hub.Broadcast <- websocket.Message{
+			Event: "todo.created",
+			Data:  *todo,
+		}
+
+		result.ID, result.Status, result.Todo = todo.ID, models.SyncStatusApplied, todo
+		return result, nil
+	}
+
+	if mutation.Op != models.SyncOpUpdate && mutation.Op != models.SyncOpDelete {
+		return invalid(errors.New("op must be one of: create, update, delete"))
+	}
+	if mutation.Op == models.SyncOpUpdate {
+		if err := validateSyncTodo(mutation.Todo); err != nil {
+			return invalid(err)
+		}
+	}
+	base, err := parseSyncToken(mutation.BaseToken)
+	if err != nil {
+		return invalid(err)
+	}
+
+	var todo *models.Todo
+	switch {
+	case mutation.ID != 0:
+		todo, err = s.todoRepo.GetTodoByID(ctx, mutation.ID, userID)
+	case mutation.ClientID != "":
+		todo, err = s.todoRepo.GetTodoByClientID(ctx, userID, mutation.ClientID)
+	default:
+		return invalid(errors.New("id or client_id is required"))
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			result.Status = models.SyncStatusNotFound
+			return result, nil
+		}
+		return result, err
+	}
+	result.ID = todo.ID
+
+	if todo.ChangeSeq > base && onConflict == models.SyncServerWins {
+		result.Status, result.Todo = models.SyncStatusConflict, todo
+		return result, nil
+	}
+
+	if mutation.Op == models.SyncOpDelete {
+		if err := s.todoRepo.DeleteTodo(ctx, todo.ID, userID, todo.Version); err != nil {
+			if errors.Is(err, repository.ErrStaleVersion) {
+				return s.concurrentConflict(ctx, userID, result)
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return result, err
+			}
+		}
+		s.cache.Delete(ctx, todoCacheKey(todo.ID))
+		s.hub.Broadcast <- websocket.Message{
+			Event: "todo.deleted",
+			Data:  *todo,
+		}
+
+		result.Status = models.SyncStatusApplied
+		return result, nil
+	}
+
+	applySyncTodo(todo, mutation.Todo)
+	if err := s.todoRepo.UpdateTodo(ctx, todo); err != nil {
+		if errors.Is(err, repository.ErrStaleVersion) {
+			return s.concurrentConflict(ctx, userID, result)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			result.Status = models.SyncStatusNotFound
+			return result, nil
+		}
+		return result, err
+	}
+	s.cache.Delete(ctx, todoCacheKey(todo.ID))
+	s.hub.Broadcast <- websocket.Message{
+		Event: "todo.updated",
+		Data:  *todo,
+	}
+
+	result.Status, result.Todo = models.SyncStatusApplied, todo
+	return result, nil
+}
+
+// concurrentConflict reports a conflict with a write that landed between
+// reading the todo and saving it, whatever the conflict rule.
+func (s *SyncService) concurrentConflict(ctx context.Context, userID int, result models.SyncMutationResult) (models.SyncMutationResult, error) {
+	todo, err := s.todoRepo.GetTodoByID(ctx, result.ID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			result.Status = models.SyncStatusNotFound
+			return result, nil
+		}
+		return result, err
+	}
+	result.Status, result.Todo = models.SyncStatusConflict, todo
+	return result, nil
+}
+
+func validateSyncTodo(todo *models.SyncTodo) error {
+	if todo == nil {
+		return errors.New("todo is required")
+	}
+	return validation.Struct(todo)
+}
+
+// applySyncTodo replaces every field with the client's state.
+func applySyncTodo(todo *models.Todo, state *models.SyncTodo) {
+	todo.Title = state.Title
+	todo.Description = state.Description
+	todo.Completed = state.Completed
+	todo.DueDate = state.DueDate
+	todo.Priority = state.Priority
+	if todo.Priority == "" {
+		todo.Priority = models.PriorityNone
+	}
+	todo.Recurrence = state.Recurrence
+	todo.Labels = normalizeLabels(state.Labels)
+}
+
+func formatSyncToken(seq int64) string {
+	return strconv.FormatInt(seq, 10)
+}
+
+func parseSyncToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseInt(token, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidSyncToken
+	}
+	return seq, nil
+}
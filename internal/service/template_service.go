@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/websocket"
+)
+
+var (
+	ErrInvalidTemplate   = errors.New("template needs a name and at least one item with a title")
+	ErrInvalidDueTime    = errors.New("due_time must be formatted HH:MM")
+	ErrInvalidAnchorDate = errors.New("anchor_date must be formatted YYYY-MM-DD")
+)
+
+const (
+	anchorDateLayout = "2006-01-02"
+	dueTimeLayout    = "15:04"
+)
+
+type TemplateService struct {
+	templateRepo *repository.TemplateRepository
+	todoRepo     *repository.TodoRepository
+	userRepo     *repository.UserRepository
+	hub          *websocket.Hub
+}
+
+func NewTemplateService(templateRepo *repository.TemplateRepository, todoRepo *repository.TodoRepository, userRepo *repository.UserRepository, hub *websocket.Hub) *TemplateService {
+	return &TemplateService{
+		templateRepo: templateRepo,
+		todoRepo:     todoRepo,
+		userRepo:     userRepo,
+		hub:          hub,
+	}
+}
+
+func (s *TemplateService) CreateTemplate(ctx context.Context, userID int, req *models.CreateTemplateRequest) (*models.TodoTemplate, error) {
+	template := &models.TodoTemplate{
+		UserID: userID,
+		Name:   strings.TrimSpace(req.Name),
+		Items:  req.Items,
+	}
+	if err := validateTemplate(template); err != nil {
+		return nil, err
+	}
+
+	if err := s.templateRepo.CreateTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// SaveTodoAsTemplate captures an existing todo as a single-item template. The
+// todo's due date is stored as an offset from the anchor date.
+func (s *TemplateService) SaveTodoAsTemplate(ctx context.Context, userID, todoID int, req *models.SaveTodoAsTemplateRequest) (*models.TodoTemplate, error) {
+	loc, err := userLocation(ctx, s.userRepo, userID, req.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	todo, err := s.todoRepo.GetTodoByID(ctx, todoID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := startOfDay(todo.CreatedAt.In(loc))
+	if req.AnchorDate != "" {
+		anchor, err = time.ParseInLocation(anchorDateLayout, req.AnchorDate, loc)
+		if err != nil {
+			return nil, ErrInvalidAnchorDate
+		}
+	}
+
+	item := models.TemplateItem{
+		Title:       todo.Title,
+		Description: todo.Description,
+		Priority:    todo.Priority,
+		Recurrence:  todo.Recurrence,
+		Labels:      todo.Labels,
+	}
+	if todo.DueDate != nil {
+		due := todo.DueDate.In(loc)
+		offset := daysBetween(anchor, startOfDay(due))
+		item.DueOffsetDays = &offset
+		if due.Hour() != 0 || due.Minute() != 0 {
+			item.DueTime = due.Format(dueTimeLayout)
+		}
+	}
+
+	name := req.Name
+	if strings.TrimSpace(name) == "" {
+		name = todo.Title
+	}
+
+	return s.CreateTemplate(ctx, userID, &models.CreateTemplateRequest{
+		Name:  name,
+		Items: []models.TemplateItem{item},
+	})
+}
+
+func (s *TemplateService) GetTemplates(ctx context.Context, userID int) ([]models.TodoTemplate, error) {
+	return s.templateRepo.GetTemplates(ctx, userID)
+}
+
+func (s *TemplateService) GetTemplateByID(ctx context.Context, userID, templateID int) (*models.TodoTemplate, error) {
+	return s.templateRepo.GetTemplateByID(ctx, templateID, userID)
+}
+
+func (s *TemplateService) DeleteTemplate(ctx context.Context, userID, templateID int) error {
+	return s.templateRepo.DeleteTemplate(ctx, templateID, userID)
+}
+
+// Instantiate creates one todo per template item, resolving relative due
+// dates against the anchor date (today when omitted).
+func (s *TemplateService) Instantiate(ctx context.Context, userID, templateID int, req *models.InstantiateTemplateRequest) ([]*models.Todo, error) {
+	loc, err := userLocation(ctx, s.userRepo, userID, req.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := startOfDay(time.Now().In(loc))
+	if req.AnchorDate != "" {
+		anchor, err = time.ParseInLocation(anchorDateLayout, req.AnchorDate, loc)
+		if err != nil {
+			return nil, ErrInvalidAnchorDate
+		}
+	}
+
+	template, err := s.templateRepo.GetTemplateByID(ctx, templateID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	todos := make([]*models.Todo, 0, len(template.Items))
+	for _, item := range template.Items {
+		todo := &models.Todo{
+			UserID:      userID,
+			Title:       item.Title,
+			Description: item.Description,
+			Priority:    item.Priority,
+			Recurrence:  item.Recurrence,
+			Labels:      item.Labels,
+		}
+		if item.DueOffsetDays != nil {
+			due := anchor.AddDate(0, 0, *item.DueOffsetDays)
+			if item.DueTime != "" {
+				clock, errParse := time.Parse(dueTimeLayout, item.DueTime)
+				if errParse != nil {
+					return nil, ErrInvalidDueTime
+				}
+				due = time.Date(due.Year(), due.Month(), due.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+			}
+			todo.DueDate = &due
+		}
+		todos = append(todos, todo)
+	}
+
+	if err := s.todoRepo.CreateTodos(ctx, todos); err != nil {
+		return nil, err
+	}
+
+	for _, todo := range todos {
+		s.hub.Broadcast <- websocket.Message{
+			Event: "todo.created",
+			Data:  *todo,
+		}
+	}
+
+	return todos, nil
+}
+
+func validateTemplate(template *models.TodoTemplate) error {
+	if template.Name == "" || len(template.Items) == 0 {
+		return ErrInvalidTemplate
+	}
+
+	for i := range template.Items {
+		item := &template.Items[i]
+		item.Title = strings.TrimSpace(item.Title)
+		if item.Title == "" {
+			return ErrInvalidTemplate
+		}
+		if item.Priority == "" {
+			item.Priority = models.PriorityNone
+		}
+		if !validPriority(item.Priority) {
+			return ErrInvalidPriority
+		}
+		if item.DueTime != "" {
+			if _, err := time.Parse(dueTimeLayout, item.DueTime); err != nil {
+				return ErrInvalidDueTime
+			}
+		}
+		item.Labels = normalizeLabels(item.Labels)
+	}
+
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// daysBetween counts calendar days from a to b, ignoring DST shifts.
+func daysBetween(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
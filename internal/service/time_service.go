@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/websocket"
+)
+
+var (
+	ErrInvalidTimeRange   = errors.New("ended_at must be after started_at")
+	ErrUnsupportedGroupBy = errors.New("group_by must be one of: day, week, todo, label")
+	ErrInvalidReportDate  = errors.New("from and to must be formatted YYYY-MM-DD")
+	ErrInvalidReportRange = errors.New("to must not be before from")
+)
+
+const reportDateLayout = "2006-01-02"
+
+type TimeService struct {
+	timeRepo *repository.TimeEntryRepository
+	todoRepo *repository.TodoRepository
+	userRepo *repository.UserRepository
+	hub      *websocket.Hub
+}
+
+func NewTimeService(timeRepo *repository.TimeEntryRepository, todoRepo *repository.TodoRepository, userRepo *repository.UserRepository, hub *websocket.Hub) *TimeService {
+	return &TimeService{
+		timeRepo: timeRepo,
+		todoRepo: todoRepo,
+		userRepo: userRepo,
+		hub:      hub,
+	}
+}
+
+func (s *TimeService) StartTimer(ctx context.Context, userID, todoID int, req *models.StartTimerRequest) (*models.TimerState, error) {
+	if _, err := s.todoRepo.GetTodoByID(ctx, todoID, userID); err != nil {
+		return nil, err
+	}
+
+	started, stopped, err := s.timeRepo.StartTimer(ctx, userID, todoID, req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	if stopped != nil {
+		s.broadcastTimer("timer.stopped", *stopped)
+	}
+	s.broadcastTimer("timer.started", *started)
+
+	return &models.TimerState{
+		Running: started,
+		Stopped: stopped,
+	}, nil
+}
+
+func (s *TimeService) StopTimer(ctx context.Context, userID int) (*models.TimerState, error) {
+	stopped, err := s.timeRepo.StopTimer(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.broadcastTimer("timer.stopped", *stopped)
+
+	return &models.TimerState{Stopped: stopped}, nil
+}
+
+func (s *TimeService) GetTimerState(ctx context.Context, userID int) (*models.TimerState, error) {
+	running, err := s.timeRepo.GetRunningTimer(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TimerState{Running: running}, nil
+}
+
+func (s *TimeService) CreateTimeEntry(ctx context.Context, userID, todoID int, req *models.CreateTimeEntryRequest) (*models.TimeEntry, error) {
+	if req.StartedAt.IsZero() || req.EndedAt.IsZero() || !req.EndedAt.After(req.StartedAt) {
+		return nil, ErrInvalidTimeRange
+	}
+
+	if _, err := s.todoRepo.GetTodoByID(ctx, todoID, userID); err != nil {
+		return nil, err
+	}
+
+	endedAt := req.EndedAt
+	entry := &models.TimeEntry{
+		UserID:    userID,
+		TodoID:    todoID,
+		StartedAt: req.StartedAt,
+		EndedAt:   &endedAt,
+		Note:      req.Note,
+		Source:    models.TimeEntrySourceManual,
+	}
+
+	if err := s.timeRepo.CreateTimeEntry(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (s *TimeService) GetTimeEntries(ctx context.Context, userID, todoID int) ([]models.TimeEntry, error) {
+	if _, err := s.todoRepo.GetTodoByID(ctx, todoID, userID); err != nil {
+		return nil, err
+	}
+	return s.timeRepo.GetTimeEntriesByTodo(ctx, todoID, userID)
+}
+
+func (s *TimeService) DeleteTimeEntry(ctx context.Context, userID, entryID int) error {
+	return s.timeRepo.DeleteTimeEntry(ctx, entryID, userID)
+}
+
+// GetTimeReport aggregates tracked time between the from and to dates
+// (inclusive, formatted YYYY-MM-DD) in the user's time zone. Missing dates
+// default to the last seven days. Weeks start on the user's week start day.
+// When grouping by label, a todo with several labels counts toward each, so
+// the groups can add up to more than the report's total. Todos do not belong
+// to projects, so there is no project grouping; group by todo or label
+// instead.
+func (s *TimeService) GetTimeReport(ctx context.Context, userID int, fromStr, toStr, groupBy string) (*models.TimeReport, error) {
+	if groupBy == "" {
+		groupBy = "day"
+	}
+	if groupBy != "day" && groupBy != "week" && groupBy != "todo" && groupBy != "label" {
+		return nil, ErrUnsupportedGroupBy
+	}
+
+	settings, err := s.userRepo.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	loc := settings.Location()
+	today := startOfDay(time.Now().In(loc))
+
+	to := today
+	if toStr != "" {
+		parsed, err := time.ParseInLocation(reportDateLayout, toStr, loc)
+		if err != nil {
+			return nil, ErrInvalidReportDate
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -6)
+	if fromStr != "" {
+		parsed, err := time.ParseInLocation(reportDateLayout, fromStr, loc)
+		if err != nil {
+			return nil, ErrInvalidReportDate
+		}
+		from = parsed
+	}
+
+	if to.Before(from) {
+		return nil, ErrInvalidReportRange
+	}
+
+	end := to.AddDate(0, 0, 1)
+	groups, err := s.timeRepo.GetTimeReport(ctx, userID, from, end, groupBy, loc.String(), settings.FirstWeekday())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.TimeReport{
+		From:    from.Format(reportDateLayout),
+		To:      to.Format(reportDateLayout),
+		GroupBy: groupBy,
+		Groups:  groups,
+	}
+	if groupBy == "label" {
+		if report.TotalSeconds, err = s.timeRepo.GetTrackedSeconds(ctx, userID, from, end); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, group := range groups {
+			report.TotalSeconds += group.TotalSeconds
+		}
+	}
+
+	return report, nil
+}
+
+func (s *TimeService) broadcastTimer(event string, entry models.TimeEntry) {
+	s.hub.Broadcast <- websocket.Message{
+		Event: event,
+		Data:  entry,
+	}
+}
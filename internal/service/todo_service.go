@@ -1,42 +1,72 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cauldnclark/todo-go/internal/cache"
+	"github.com/cauldnclark/todo-go/internal/exporter"
 	"github.com/cauldnclark/todo-go/internal/models"
+	"github.com/cauldnclark/todo-go/internal/patch"
+	"github.com/cauldnclark/todo-go/internal/quickadd"
 	"github.com/cauldnclark/todo-go/internal/repository"
+	"github.com/cauldnclark/todo-go/internal/validation"
 	"github.com/cauldnclark/todo-go/internal/websocket"
 )
 
+var (
+	ErrInvalidPriority = errors.New("priority must be one of: none, low, medium, high")
+	ErrInvalidTimezone = errors.New("unknown timezone")
+	ErrEmptyTitle      = errors.New("title is required")
+	ErrInvalidSort     = errors.New("sort must be one of: created_asc, created_desc, due_date, priority, title")
+
+	ErrUnsupportedPatch   = errors.New("unsupported patch media type")
+	ErrInvalidPatchResult = errors.New("patched todo is invalid")
+)
+
 type TodoService struct {
 	todoRepo *repository.TodoRepository
+	userRepo *repository.UserRepository
 	cache    *cache.RedisCache
 	hub      *websocket.Hub
 }
 
-func NewTodoService(todoRepo *repository.TodoRepository, cache *cache.RedisCache, hub *websocket.Hub) *TodoService {
+func NewTodoService(todoRepo *repository.TodoRepository, userRepo *repository.UserRepository, cache *cache.RedisCache, hub *websocket.Hub) *TodoService {
 	return &TodoService{
 		todoRepo: todoRepo,
+		userRepo: userRepo,
 		cache:    cache,
 		hub:      hub,
 	}
 }
 
-func (s *TodoService) CreateTodo(ctx context.Context, userID int, req *models.CreateTodoRequest) error {
+func (s *TodoService) CreateTodo(ctx context.Context, userID int, req *models.CreateTodoRequest) (*models.Todo, error) {
+	if req.Priority != "" && !validPriority(req.Priority) {
+		return nil, ErrInvalidPriority
+	}
+
 	todo := &models.Todo{
 		UserID:      userID,
 		Title:       req.Title,
 		Description: req.Description,
 		Completed:   false,
+		DueDate:     req.DueDate,
+		Priority:    req.Priority,
+		Recurrence:  req.Recurrence,
+		Labels:      normalizeLabels(req.Labels),
 	}
 
 	err := s.todoRepo.CreateTodo(ctx, todo)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	s.hub.Broadcast <- websocket.Message{
@@ -44,35 +74,173 @@ func (s *TodoService) CreateTodo(ctx context.Context, userID int, req *models.Cr
 		Data:  *todo,
 	}
 
-	return nil
+	return todo, nil
+}
+
+// QuickAdd parses free-form text into a todo. With DryRun set only the
+// interpretation is returned and nothing is stored.
+func (s *TodoService) QuickAdd(ctx context.Context, userID int, req *models.QuickAddRequest) (*models.QuickAddResult, *models.Todo, error) {
+	loc, err := userLocation(ctx, s.userRepo, userID, req.Timezone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsed := newQuickAddResult(quickadd.Parse(req.Text, time.Now(), loc))
+	if parsed.Title == "" {
+		return parsed, nil, ErrEmptyTitle
+	}
+
+	if req.DryRun {
+		return parsed, nil, nil
+	}
+
+	todo, err := s.CreateTodo(ctx, userID, &models.CreateTodoRequest{
+		Title:      parsed.Title,
+		DueDate:    parsed.DueDate,
+		Priority:   parsed.Priority,
+		Recurrence: parsed.Recurrence,
+		Labels:     parsed.Labels,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parsed, todo, nil
+}
+
+func newQuickAddResult(result *quickadd.Result) *models.QuickAddResult {
+	matches := make([]models.QuickAddMatch, len(result.Matches))
+	for i, match := range result.Matches {
+		matches[i] = models.QuickAddMatch{Kind: match.Kind, Text: match.Text}
+	}
+	return &models.QuickAddResult{
+		Input:      result.Input,
+		Title:      result.Title,
+		DueDate:    result.DueDate,
+		HasTime:    result.HasTime,
+		Recurrence: result.Recurrence,
+		Labels:     result.Labels,
+		Priority:   result.Priority,
+		Timezone:   result.Timezone,
+		Matches:    matches,
+	}
 }
 
-func (s *TodoService) UpdateTodo(ctx context.Context, todoID, userID int, req *models.UpdateTodoRequest) (*models.Todo, error) {
+// UpdateTodo replaces the todo's editable fields with req. ifMatch is the
+// request's If-Match header; when set the update only happens if the todo's
+// ETag still matches. Without it a concurrent update between reading and
+// saving the todo is reported as repository.ErrStaleVersion instead of being
+// overwritten.
+func (s *TodoService) UpdateTodo(ctx context.Context, todoID, userID int, req *models.UpdateTodoRequest, ifMatch string) (*models.Todo, error) {
 	todo, err := s.todoRepo.GetTodoByID(ctx, todoID, userID)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkPreconditions(todo, ifMatch, ""); err != nil {
+		return nil, err
+	}
+
+	return s.replaceTodo(ctx, todo, req, ifMatch)
+}
 
-	if req.Title != "" {
-		todo.Title = req.Title
+// PatchTodo applies a JSON Merge Patch or JSON Patch document, depending on
+// mediaType, to the todo's editable fields. Plain application/json is taken
+// as a merge patch.
+func (s *TodoService) PatchTodo(ctx context.Context, todoID, userID int, mediaType string, patchDoc []byte, ifMatch string) (*models.Todo, error) {
+	var apply func(doc, patch []byte) ([]byte, error)
+	switch mediaType {
+	case patch.MediaTypeMergePatch, "application/json":
+		apply = patch.MergePatch
+	case patch.MediaTypeJSONPatch:
+		apply = patch.JSONPatch
+	default:
+		return nil, ErrUnsupportedPatch
 	}
-	if req.Description != "" {
-		todo.Description = req.Description
+
+	todo, err := s.todoRepo.GetTodoByID(ctx, todoID, userID)
+	if err != nil {
+		return nil, err
 	}
-	if req.Completed != nil {
-		todo.Completed = *req.Completed
+	if err := checkPreconditions(todo, ifMatch, ""); err != nil {
+		return nil, err
 	}
 
-	err = s.todoRepo.UpdateTodo(ctx, todo)
+	doc, err := json.Marshal(models.UpdateTodoRequest{
+		Title:       todo.Title,
+		Description: todo.Description,
+		Completed:   todo.Completed,
+		DueDate:     todo.DueDate,
+		Priority:    todo.Priority,
+		Recurrence:  todo.Recurrence,
+		Labels:      todo.Labels,
+	})
+	if err != nil {
+		return nil, err
+	}
+	patched, err := apply(doc, patchDoc)
 	if err != nil {
 		return nil, err
 	}
 
+	var req models.UpdateTodoRequest
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatchResult, err)
+	}
+	if err := validation.Struct(&req); err != nil {
+		return nil, err
+	}
+
+	return s.replaceTodo(ctx, todo, &req, ifMatch)
+}
+
+func (s *TodoService) replaceTodo(ctx context.Context, todo *models.Todo, req *models.UpdateTodoRequest, ifMatch string) (*models.Todo, error) {
+	if strings.TrimSpace(req.Title) == "" {
+		return nil, ErrEmptyTitle
+	}
+	priority := req.Priority
+	if priority == "" {
+		priority = models.PriorityNone
+	}
+	if !validPriority(priority) {
+		return nil, ErrInvalidPriority
+	}
+
+	todo.Title = req.Title
+	todo.Description = req.Description
+	todo.Completed = req.Completed
+	todo.DueDate = req.DueDate
+	todo.Priority = priority
+	todo.Recurrence = req.Recurrence
+	todo.Labels = normalizeLabels(req.Labels)
+
+	if err := s.todoRepo.UpdateTodo(ctx, todo); err != nil {
+		if errors.Is(err, repository.ErrStaleVersion) && ifMatch != "" {
+			return nil, ErrPreconditionFailed
+		}
+		return nil, err
+	}
+	s.cache.Delete(ctx, todoCacheKey(todo.ID))
+
 	return todo, nil
 }
 
-func (s *TodoService) GetTodos(ctx context.Context, userID int, completed *bool, page, limit int) (*models.TodosPaginated, error) {
-	todosPage, err := s.todoRepo.GetTodosPaginated(ctx, userID, completed, page, limit)
+// GetTodos returns a page of todos in the given sort order, or in the user's
+// default order when sort is empty.
+func (s *TodoService) GetTodos(ctx context.Context, userID int, completed *bool, page, limit int, sort string) (*models.TodosPaginated, error) {
+	if sort == "" {
+		settings, err := s.userRepo.GetSettings(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		sort = settings.DefaultSort
+	}
+	if !validSort(sort) {
+		return nil, ErrInvalidSort
+	}
+
+	todosPage, err := s.todoRepo.GetTodosPaginated(ctx, userID, completed, page, limit, sort)
 	if err != nil {
 		return nil, err
 	}
@@ -80,13 +248,13 @@ func (s *TodoService) GetTodos(ctx context.Context, userID int, completed *bool,
 }
 
 func (s *TodoService) GetTodoByID(ctx context.Context, todoID, userID int) (*models.Todo, error) {
-	cacheKey := "todos_user_" + strconv.Itoa(todoID)
+	cacheKey := todoCacheKey(todoID)
 
 	var todo *models.Todo
 
-	// try cache first
+	// try cache first; the key does not include the user, so check the owner
 	err := s.cache.Get(ctx, cacheKey, &todo)
-	if err == nil {
+	if err == nil && todo != nil && todo.UserID == userID {
 		log.Printf("cache hit for key: %s", cacheKey)
 		return todo, nil
 	}
@@ -104,8 +272,47 @@ func (s *TodoService) GetTodoByID(ctx context.Context, todoID, userID int) (*mod
 	return todo, nil
 }
 
-func (s *TodoService) DeleteTodo(ctx context.Context, todoID, userID int) error {
-	return s.todoRepo.DeleteTodo(ctx, todoID, userID)
+// ExportTodos streams the user's todos matching filter to w in the given
+// format.
+func (s *TodoService) ExportTodos(ctx context.Context, userID int, format string, filter models.TodoFilter, w io.Writer) error {
+	writer, err := exporter.NewWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Begin(); err != nil {
+		return err
+	}
+	if err := s.todoRepo.StreamTodos(ctx, userID, filter, writer.Write); err != nil {
+		return err
+	}
+	return writer.End()
+}
+
+// DeleteTodo deletes the todo, only if its ETag still matches ifMatch when
+// that is set.
+func (s *TodoService) DeleteTodo(ctx context.Context, todoID, userID int, ifMatch string) error {
+	version := 0
+	if ifMatch != "" {
+		todo, err := s.todoRepo.GetTodoByID(ctx, todoID, userID)
+		if err != nil {
+			return err
+		}
+		if err := checkPreconditions(todo, ifMatch, ""); err != nil {
+			return err
+		}
+		version = todo.Version
+	}
+
+	if err := s.todoRepo.DeleteTodo(ctx, todoID, userID, version); err != nil {
+		if errors.Is(err, repository.ErrStaleVersion) {
+			return ErrPreconditionFailed
+		}
+		return err
+	}
+	s.cache.Delete(ctx, todoCacheKey(todoID))
+
+	return nil
 }
 
 func (s *TodoService) ClearTodoCache(ctx context.Context, todoID int) error {
@@ -113,7 +320,7 @@ func (s *TodoService) ClearTodoCache(ctx context.Context, todoID int) error {
 	// For simplicity, we'll delete known key patterns
 
 	keys := []string{
-		"todos_user_" + strconv.Itoa(todoID),
+		todoCacheKey(todoID),
 	}
 
 	for _, key := range keys {
@@ -128,3 +335,64 @@ func (s *TodoService) ClearTodoCache(ctx context.Context, todoID int) error {
 	log.Printf("🧹 Cleared cache for user %d", todoID)
 	return nil
 }
+
+func todoCacheKey(todoID int) string {
+	return "todos_user_" + strconv.Itoa(todoID)
+}
+
+func validSort(sort string) bool {
+	switch sort {
+	case models.SortCreatedAsc, models.SortCreatedDesc, models.SortDueDate, models.SortPriority, models.SortTitle:
+		return true
+	}
+	return false
+}
+
+func validPriority(priority string) bool {
+	switch priority {
+	case models.PriorityNone, models.PriorityLow, models.PriorityMedium, models.PriorityHigh:
+		return true
+	}
+	return false
+}
+
+// normalizeLabels lowercases labels, strips a leading '#' and drops blanks and
+// duplicates while keeping the original order.
+func normalizeLabels(labels []string) []string {
+	normalized := make([]string, 0, len(labels))
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		label = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(label), "#")))
+		if label == "" || seen[label] {
+			continue
+		}
+		seen[label] = true
+		normalized = append(normalized, label)
+	}
+	return normalized
+}
+
+// userLocation resolves the time zone a request works in: the one named, or
+// the user's own time zone when name is empty.
+func userLocation(ctx context.Context, userRepo *repository.UserRepository, userID int, name string) (*time.Location, error) {
+	if name != "" {
+		return loadLocation(name)
+	}
+	settings, err := userRepo.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return settings.Location(), nil
+}
+
+// loadLocation resolves an IANA time zone name, defaulting to UTC.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
@@ -2,189 +2,129 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"net/url"
-	"strings"
-	"time"
 
 	"github.com/cauldnclark/todo-go/internal/models"
 	"github.com/cauldnclark/todo-go/internal/repository"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type UserService struct {
-	userRepo           *repository.UserRepository
-	jwtSecret          string
-	googleClientId     string
-	googleClientSecret string
-	redirectURI        string
+	userRepo     *repository.UserRepository
+	identityRepo *repository.IdentityRepository
+	sessions     *SessionService
+	states       *OAuthStateStore
+	providers    IdentityProviders
 }
 
-func NewUserService(userRepo *repository.UserRepository, jwtSecret, googleClientId, googleClientSecret, redirectURI string) *UserService {
+func NewUserService(userRepo *repository.UserRepository, identityRepo *repository.IdentityRepository, sessions *SessionService, states *OAuthStateStore, providers IdentityProviders) *UserService {
 	return &UserService{
-		userRepo:           userRepo,
-		jwtSecret:          jwtSecret,
-		googleClientId:     googleClientId,
-		googleClientSecret: googleClientSecret,
-		redirectURI:        redirectURI,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		sessions:     sessions,
+		states:       states,
+		providers:    providers,
 	}
 }
 
-func (s *UserService) AuthenticateWithGoogle(ctx context.Context, tokenString string) (*models.AuthResponse, error) {
-	// Exchange authorization code for access token
-	tokenResp, err := s.exchangeCodeForToken(tokenString, s.redirectURI)
+// GetProviders returns the names of the providers users can sign in with.
+func (s *UserService) GetProviders() []string {
+	return s.providers.Names()
+}
+
+// StartSignIn begins a sign-in with the provider and returns the URL to send
+// the user to. The URL carries a state to check on the way back and a PKCE
+// challenge for the code exchange.
+func (s *UserService) StartSignIn(ctx context.Context, providerName string) (*models.AuthorizationStart, error) {
+	provider, err := s.providers.Get(providerName)
 	if err != nil {
-		log.Printf("Error exchanging code for token: %v", err)
-		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
+		return nil, err
 	}
+	return startAuthorization(ctx, s.states, provider, 0)
+}
 
-	googleUser, err := s.getGoogleUserInfo(tokenResp.AccessToken)
+// SignIn finishes a sign-in started by StartSignIn. Users are found by the
+// provider account they signed in with; an account nobody has linked yet
+// signs up a new user. It returns ErrInvalidOAuthState if the state does not
+// belong to a sign-in with the provider.
+func (s *UserService) SignIn(ctx context.Context, providerName, code, state string, client models.SessionClient) (*models.AuthResponse, error) {
+	provider, err := s.providers.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+	flow, err := s.states.Finish(ctx, provider.Name(), state, 0)
 	if err != nil {
-		log.Printf("Error getting user info from Google: %v", err)
-		return nil, fmt.Errorf("failed to get user info: %w", err)
+		return nil, err
 	}
 
-	user, err := s.userRepo.GetUserByGoogleID(ctx, googleUser.ID)
+	external, err := exchangeCodeWith(ctx, provider, code, flow)
 	if err != nil {
-		log.Println("Swallowing this error to create new user:", err)
+		return nil, err
 	}
 
-	if user == nil {
+	var user *models.User
+	identity, err := s.identityRepo.UseIdentity(ctx, provider.Name(), external.Subject, external.Email)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
 		user = &models.User{
-			GoogleID:   googleUser.ID,
-			Email:      googleUser.Email,
-			Name:       googleUser.Name,
-			PictureURL: googleUser.PictureURL,
+			Email:      external.Email,
+			Name:       external.Name,
+			PictureURL: external.PictureURL,
 		}
-		if errCreate := s.userRepo.CreateUser(ctx, user); errCreate != nil {
+		identity = &models.Identity{
+			Provider: provider.Name(),
+			Subject:  external.Subject,
+			Email:    external.Email,
+		}
+		if errCreate := s.userRepo.CreateUser(ctx, user, identity); errCreate != nil {
 			return nil, fmt.Errorf("failed to create user: %w", errCreate)
 		}
-	} else {
-		if user.Email != googleUser.Email || user.Name != googleUser.Name || user.PictureURL != googleUser.PictureURL {
-			user.Email = googleUser.Email
-			user.Name = googleUser.Name
-			user.PictureURL = googleUser.PictureURL
+	case err != nil:
+		return nil, err
+	default:
+		user, err = s.userRepo.GetUserByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user.Email != external.Email || user.Name != external.Name || user.PictureURL != external.PictureURL {
+			user.Email = external.Email
+			user.Name = external.Name
+			user.PictureURL = external.PictureURL
 			if errUpdate := s.userRepo.UpdateUser(ctx, user); errUpdate != nil {
 				return nil, fmt.Errorf("failed to update user: %w", errUpdate)
 			}
 		}
 	}
 
-	token, err := s.generateJWT(user.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate jwt: %w", err)
+	// Signing in again cancels a pending account deletion.
+	if user.DeletionScheduledAt != nil {
+		if errCancel := s.userRepo.CancelDeletion(ctx, user.ID); errCancel != nil {
+			return nil, fmt.Errorf("failed to cancel account deletion: %w", errCancel)
+		}
+		user.DeletionScheduledAt = nil
 	}
 
-	return &models.AuthResponse{
-		Token: token,
-		User:  user,
-	}, nil
+	return s.sessions.StartSession(ctx, user, client)
 }
 
 func (s *UserService) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
 	return s.userRepo.GetUserByID(ctx, userID)
 }
 
-func (s *UserService) ValidateJWT(tokenString string) (int, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.jwtSecret), nil
-	})
-
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse token: %w", err)
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID, ok := claims["user_id"].(float64)
-		if !ok {
-			return 0, fmt.Errorf("invalid user id")
-		}
-		return int(userID), nil
-	}
-
-	return 0, fmt.Errorf("invalid token")
+func (s *UserService) GetSettings(ctx context.Context, userID int) (*models.UserSettings, error) {
+	return s.userRepo.GetSettings(ctx, userID)
 }
 
-func (s *UserService) getGoogleUserInfo(accessToken string) (*models.GoogleTokenInfo, error) {
-	req, err := http.NewRequest("GET", "https://www.googleapis.com/oauth2/v2/userinfo", nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// UpdateSettings changes the settings present in req and returns the result.
+func (s *UserService) UpdateSettings(ctx context.Context, userID int, req *models.UpdateUserSettingsRequest) (*models.UserSettings, error) {
+	settings, err := s.userRepo.GetSettings(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("google userinfo request failed: %s", string(body))
-	}
-
-	var userInfo models.GoogleTokenInfo
-	err = json.NewDecoder(resp.Body).Decode(&userInfo)
-	if err != nil {
+	req.Apply(settings)
+	if err := s.userRepo.UpdateSettings(ctx, userID, settings); err != nil {
 		return nil, err
 	}
-
-	return &userInfo, nil
-}
-
-func (s *UserService) exchangeCodeForToken(code, redirectURI string) (*models.GoogleTokenResponse, error) {
-	tokenURL := "https://oauth2.googleapis.com/token"
-	log.Printf("Making token exchange request to: %s", tokenURL)
-
-	data := url.Values{}
-	data.Set("client_id", s.googleClientId)
-	data.Set("client_secret", s.googleClientSecret)
-	data.Set("code", code)
-	data.Set("grant_type", "authorization_code")
-	data.Set("redirect_uri", redirectURI)
-
-	log.Printf("Token request data: client_id=%s, redirect_uri=%s, code=%s", s.googleClientId, redirectURI, code)
-
-	resp, err := http.Post(tokenURL, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
-	if err != nil {
-		log.Printf("HTTP request failed: %v", err)
-		return nil, fmt.Errorf("failed to make token request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	log.Printf("Token exchange response status: %d", resp.StatusCode)
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("Token exchange failed with status %d: %s", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("google token exchange failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var tokenResp models.GoogleTokenResponse
-	err = json.NewDecoder(resp.Body).Decode(&tokenResp)
-	if err != nil {
-		log.Printf("Failed to decode token response: %v", err)
-		return nil, fmt.Errorf("failed to decode token response: %w", err)
-	}
-
-	log.Printf("Token exchange successful, access_token length: %d", len(tokenResp.AccessToken))
-	return &tokenResp, nil
-}
-
-func (s *UserService) generateJWT(userID int) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
-		"iat":     time.Now().Unix(),
-	})
-
-	return token.SignedString([]byte(s.jwtSecret))
+	return settings, nil
 }
@@ -0,0 +1,119 @@
+// Package validation checks request models against the rules declared in
+// their `validate` struct tags and reports every failing field.
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Normalizer is implemented by request models that clean up their input,
+// such as trimming whitespace, before they are validated.
+type Normalizer interface {
+	Normalize()
+}
+
+// FieldError describes one invalid field. Field is the JSON path of the
+// field, e.g. "items[0].title"; Rule is the rule it broke.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error lists the fields that failed validation.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + " " + f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// Report fields by their JSON names, which is what clients send.
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// Struct normalizes v when it is a Normalizer and then validates it. The
+// returned error is an *Error when fields are invalid.
+func Struct(v any) error {
+	if n, ok := v.(Normalizer); ok {
+		n.Normalize()
+	}
+
+	err := validate.Struct(v)
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return err
+	}
+
+	fields := make([]FieldError, len(invalid))
+	for i, fe := range invalid {
+		fields[i] = FieldError{
+			Field:   fieldPath(fe.Namespace()),
+			Rule:    fe.Tag(),
+			Message: message(fe),
+		}
+	}
+	return &Error{Fields: fields}
+}
+
+// fieldPath drops the struct name validator puts in front of the path.
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+func message(fe validator.FieldError) string {
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Array
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		if isList {
+			return "must have at most " + fe.Param() + " items"
+		}
+		return "must be at most " + fe.Param() + " characters"
+	case "min":
+		if isList {
+			return "must have at least " + fe.Param() + " items"
+		}
+		return "must be at least " + fe.Param() + " characters"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "timezone":
+		return "must be an IANA time zone name"
+	case "datetime":
+		return "must be formatted " + datetimeLayout(fe.Param())
+	}
+	return "is invalid"
+}
+
+// datetimeLayout turns a Go time layout into the notation used in API
+// error messages.
+func datetimeLayout(layout string) string {
+	return strings.NewReplacer("2006", "YYYY", "01", "MM", "02", "DD", "15", "HH", "04", "MM").Replace(layout)
+}
@@ -16,10 +16,11 @@ const (
 )
 
 type Client struct {
-	hub    *Hub
-	conn   *websocket.Conn
-	send   chan []byte
-	UserID int
+	hub       *Hub
+	conn      *websocket.Conn
+	send      chan []byte
+	UserID    int
+	SessionID string
 }
 
 type Message struct {
@@ -3,11 +3,13 @@ package websocket
 import (
 	"log"
 	"net/http"
-	"os"
+	"slices"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/cauldnclark/todo-go/internal/apierror"
+	"github.com/cauldnclark/todo-go/internal/auth"
+	"github.com/cauldnclark/todo-go/internal/middleware"
+	"github.com/cauldnclark/todo-go/internal/models"
 	"github.com/gorilla/websocket"
-	"github.com/joho/godotenv"
 )
 
 var upgrader = websocket.Upgrader{
@@ -18,31 +20,13 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func lookupEnv(key string) (string, bool) {
-	_ = godotenv.Load()
-	return os.Getenv(key), os.Getenv(key) != ""
-}
-
-func getEnv(key, fallback string) string {
-	if value, ok := lookupEnv(key); ok {
-		return value
-	}
-	return fallback
-}
-
-var jwtKey = []byte(getEnv("JWT_SECRET", "fallback-secret-32-chars-long!!"))
-
 type Handler struct {
-	hub *Hub
-}
-
-type Claims struct {
-	UserID int `json:"user_id"`
-	jwt.RegisteredClaims
+	hub    *Hub
+	tokens *auth.Manager
 }
 
-func NewHandler(hub *Hub) *Handler {
-	return &Handler{hub: hub}
+func NewHandler(hub *Hub, tokens *auth.Manager) *Handler {
+	return &Handler{hub: hub, tokens: tokens}
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -57,53 +41,35 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if tokenStr == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.Unauthorized("Unauthorized"))
 		return
 	}
 
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte(jwtKey), nil
-	})
-
+	claims, err := h.tokens.Authenticate(r.Context(), tokenStr)
 	if err != nil {
-		log.Printf("JWT parse error: %v", err)
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return
-	}
-
-	if !token.Valid {
-		log.Printf("Invalid JWT token")
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
-		return
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		log.Printf("Invalid token claims")
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		log.Printf("Websocket authentication failed: %v", err)
+		middleware.WriteAuthError(w, r, err)
 		return
 	}
 
-	userID, ok := claims["user_id"].(float64)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Events carry todos, so the token must be allowed to read them.
+	if !slices.Contains(claims.Scopes(), models.ScopeTodosRead) {
+		apierror.Write(w, r, apierror.Forbidden("Token lacks the "+models.ScopeTodosRead+" scope"))
 		return
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		http.Error(w, "Could not open websocket connection", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("Could not open websocket connection"))
 		return
 	}
 
 	client := &Client{
-		hub:    h.hub,
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		UserID: int(userID),
+		hub:       h.hub,
+		conn:      conn,
+		send:      make(chan []byte, 256),
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
 	}
 
 	h.hub.register <- client
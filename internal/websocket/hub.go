@@ -97,6 +97,46 @@ func (h *Hub) unregisterClient(client *Client) {
 	}
 }
 
+// DisconnectUser closes every connection the user has open, for example
+// after their tokens were revoked.
+func (h *Hub) DisconnectUser(userID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients, ok := h.clients[userID]
+	if !ok {
+		return
+	}
+	for client := range clients {
+		close(client.send)
+	}
+	delete(h.clients, userID)
+	log.Printf("Disconnected %d client(s) for UserID=%d", len(clients), userID)
+}
+
+// DisconnectSession closes the user's connections opened with tokens from
+// the given session.
+func (h *Hub) DisconnectSession(userID int, sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients, ok := h.clients[userID]
+	if !ok {
+		return
+	}
+	for client := range clients {
+		if client.SessionID != sessionID {
+			continue
+		}
+		delete(clients, client)
+		close(client.send)
+		log.Printf("Disconnected client for UserID=%d, Session=%s", userID, sessionID)
+	}
+	if len(clients) == 0 {
+		delete(h.clients, userID)
+	}
+}
+
 func (h *Hub) broadcastMessage(message Message) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -105,6 +145,12 @@ func (h *Hub) broadcastMessage(message Message) {
 	switch v := message.Data.(type) {
 	case models.Todo:
 		targetUserID = v.UserID
+	case models.TimeEntry:
+		targetUserID = v.UserID
+	case models.ImportJob:
+		targetUserID = v.UserID
+	case models.AccountExport:
+		targetUserID = v.UserID
 	case map[string]interface{}:
 		if uid, ok := v["user_id"].(int); ok {
 			targetUserID = uid
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE todos
+    ADD COLUMN due_date TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN priority VARCHAR(16) NOT NULL DEFAULT 'none',
+    ADD COLUMN recurrence TEXT NOT NULL DEFAULT '',
+    ADD COLUMN labels TEXT[] NOT NULL DEFAULT '{}',
+    ADD CONSTRAINT todos_priority_check CHECK (priority IN ('none', 'low', 'medium', 'high'));
+
+CREATE INDEX idx_todos_due_date ON todos(user_id, due_date) WHERE due_date IS NOT NULL;
+CREATE INDEX idx_todos_labels ON todos USING GIN (labels);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_todos_labels;
+DROP INDEX IF EXISTS idx_todos_due_date;
+ALTER TABLE todos
+    DROP CONSTRAINT IF EXISTS todos_priority_check,
+    DROP COLUMN IF EXISTS labels,
+    DROP COLUMN IF EXISTS recurrence,
+    DROP COLUMN IF EXISTS priority,
+    DROP COLUMN IF EXISTS due_date;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE calendar_feeds (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    label VARCHAR(255) NOT NULL DEFAULT '',
+    include_completed BOOLEAN NOT NULL DEFAULT FALSE,
+    component VARCHAR(16) NOT NULL DEFAULT 'vtodo',
+    last_accessed_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CONSTRAINT calendar_feeds_component_check CHECK (component IN ('vtodo', 'vevent', 'both'))
+);
+
+CREATE INDEX idx_calendar_feeds_user_id ON calendar_feeds(user_id);
+
+CREATE TRIGGER update_calendar_feeds_updated_at
+    BEFORE UPDATE ON calendar_feeds
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS calendar_feeds;
+-- +goose StatementEnd
@@ -0,0 +1,90 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE SEQUENCE todo_change_seq;
+
+ALTER TABLE todos
+    ADD COLUMN ical_uid TEXT,
+    ADD COLUMN caldav_resource TEXT,
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('todo_change_seq');
+
+UPDATE todos SET ical_uid = 'todo-' || id || '@todo-go';
+ALTER TABLE todos ALTER COLUMN ical_uid SET NOT NULL;
+
+CREATE UNIQUE INDEX idx_todos_user_ical_uid ON todos(user_id, ical_uid);
+CREATE UNIQUE INDEX idx_todos_user_caldav_resource ON todos(user_id, caldav_resource) WHERE caldav_resource IS NOT NULL;
+CREATE INDEX idx_todos_user_change_seq ON todos(user_id, change_seq);
+
+-- Every insert and update takes a fresh value from the sequence so clients
+-- can ask for everything that changed after a given point.
+CREATE OR REPLACE FUNCTION bump_todo_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = nextval('todo_change_seq');
+    IF NEW.ical_uid IS NULL THEN
+        NEW.ical_uid = 'todo-' || NEW.id || '@todo-go';
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER bump_todos_change_seq
+    BEFORE INSERT OR UPDATE ON todos
+    FOR EACH ROW
+    EXECUTE FUNCTION bump_todo_change_seq();
+
+CREATE TABLE todo_tombstones (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    todo_id INTEGER NOT NULL,
+    ical_uid TEXT NOT NULL,
+    caldav_resource TEXT,
+    change_seq BIGINT NOT NULL DEFAULT nextval('todo_change_seq'),
+    deleted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_todo_tombstones_user_change_seq ON todo_tombstones(user_id, change_seq);
+
+CREATE OR REPLACE FUNCTION record_todo_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO todo_tombstones (user_id, todo_id, ical_uid, caldav_resource)
+    VALUES (OLD.user_id, OLD.id, OLD.ical_uid, OLD.caldav_resource);
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_todos_tombstone
+    AFTER DELETE ON todos
+    FOR EACH ROW
+    EXECUTE FUNCTION record_todo_tombstone();
+
+CREATE TABLE app_passwords (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    password_hash CHAR(64) UNIQUE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_app_passwords_user_id ON app_passwords(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS app_passwords;
+DROP TRIGGER IF EXISTS record_todos_tombstone ON todos;
+DROP FUNCTION IF EXISTS record_todo_tombstone();
+DROP TABLE IF EXISTS todo_tombstones;
+DROP TRIGGER IF EXISTS bump_todos_change_seq ON todos;
+DROP FUNCTION IF EXISTS bump_todo_change_seq();
+DROP INDEX IF EXISTS idx_todos_user_change_seq;
+DROP INDEX IF EXISTS idx_todos_user_caldav_resource;
+DROP INDEX IF EXISTS idx_todos_user_ical_uid;
+ALTER TABLE todos
+    DROP COLUMN IF EXISTS change_seq,
+    DROP COLUMN IF EXISTS caldav_resource,
+    DROP COLUMN IF EXISTS ical_uid;
+DROP SEQUENCE IF EXISTS todo_change_seq;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE todos ADD COLUMN client_id TEXT;
+CREATE UNIQUE INDEX idx_todos_user_client_id ON todos(user_id, client_id) WHERE client_id IS NOT NULL;
+
+ALTER TABLE todo_tombstones ADD COLUMN client_id TEXT;
+
+CREATE OR REPLACE FUNCTION record_todo_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO todo_tombstones (user_id, todo_id, ical_uid, caldav_resource, client_id)
+    VALUES (OLD.user_id, OLD.id, OLD.ical_uid, OLD.caldav_resource, OLD.client_id);
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_todo_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO todo_tombstones (user_id, todo_id, ical_uid, caldav_resource)
+    VALUES (OLD.user_id, OLD.id, OLD.ical_uid, OLD.caldav_resource);
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+ALTER TABLE todo_tombstones DROP COLUMN IF EXISTS client_id;
+DROP INDEX IF EXISTS idx_todos_user_client_id;
+ALTER TABLE todos DROP COLUMN IF EXISTS client_id;
+-- +goose StatementEnd
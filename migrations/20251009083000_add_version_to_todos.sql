@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_todo_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = nextval('todo_change_seq');
+    IF TG_OP = 'UPDATE' THEN
+        NEW.version = OLD.version + 1;
+    END IF;
+    IF NEW.ical_uid IS NULL THEN
+        NEW.ical_uid = 'todo-' || NEW.id || '@todo-go';
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION bump_todo_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = nextval('todo_change_seq');
+    IF NEW.ical_uid IS NULL THEN
+        NEW.ical_uid = 'todo-' || NEW.id || '@todo-go';
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+ALTER TABLE todos DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC',
+    ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT 'en-US',
+    ADD COLUMN week_start VARCHAR(16) NOT NULL DEFAULT 'monday',
+    ADD COLUMN default_sort VARCHAR(32) NOT NULL DEFAULT 'created_asc',
+    ADD COLUMN notification_settings JSONB NOT NULL
+        DEFAULT '{"due_reminders": true, "reminder_minutes": 30, "daily_digest": false}',
+    ADD CONSTRAINT users_week_start_check CHECK (week_start IN ('monday', 'sunday', 'saturday'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_week_start_check,
+    DROP COLUMN IF EXISTS notification_settings,
+    DROP COLUMN IF EXISTS default_sort,
+    DROP COLUMN IF EXISTS week_start,
+    DROP COLUMN IF EXISTS locale,
+    DROP COLUMN IF EXISTS timezone;
+-- +goose StatementEnd
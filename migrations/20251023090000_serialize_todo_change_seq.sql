@@ -0,0 +1,93 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Sequence values are not handed out in commit order, so a client that
+-- synced up to a value could miss a change committed later with a lower one.
+-- Each user now has a counter row instead: taking the next value locks the
+-- row until the transaction ends, so a user's changes commit in order.
+CREATE TABLE todo_change_counters (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    change_seq BIGINT NOT NULL
+);
+
+INSERT INTO todo_change_counters (user_id, change_seq)
+SELECT user_id, MAX(change_seq)
+FROM (
+    SELECT user_id, change_seq FROM todos
+    UNION ALL
+    SELECT user_id, change_seq FROM todo_tombstones
+) changes
+GROUP BY user_id;
+
+CREATE OR REPLACE FUNCTION next_todo_change_seq(p_user_id INTEGER)
+RETURNS BIGINT AS $$
+DECLARE
+    seq BIGINT;
+BEGIN
+    INSERT INTO todo_change_counters (user_id, change_seq)
+    VALUES (p_user_id, 1)
+    ON CONFLICT (user_id) DO UPDATE SET change_seq = todo_change_counters.change_seq + 1
+    RETURNING change_seq INTO seq;
+    RETURN seq;
+END;
+$$ language 'plpgsql';
+
+CREATE OR REPLACE FUNCTION bump_todo_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = next_todo_change_seq(NEW.user_id);
+    IF TG_OP = 'UPDATE' THEN
+        NEW.version = OLD.version + 1;
+    END IF;
+    IF NEW.ical_uid IS NULL THEN
+        NEW.ical_uid = 'todo-' || NEW.id || '@todo-go';
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+ALTER TABLE todos ALTER COLUMN change_seq DROP DEFAULT;
+ALTER TABLE todo_tombstones ALTER COLUMN change_seq DROP DEFAULT;
+
+CREATE OR REPLACE FUNCTION bump_tombstone_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = next_todo_change_seq(NEW.user_id);
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER bump_todo_tombstones_change_seq
+    BEFORE INSERT ON todo_tombstones
+    FOR EACH ROW
+    EXECUTE FUNCTION bump_tombstone_change_seq();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS bump_todo_tombstones_change_seq ON todo_tombstones;
+DROP FUNCTION IF EXISTS bump_tombstone_change_seq();
+
+SELECT setval('todo_change_seq', GREATEST(
+    (SELECT COALESCE(MAX(change_seq), 0) FROM todo_change_counters),
+    (SELECT last_value FROM todo_change_seq)
+));
+ALTER TABLE todos ALTER COLUMN change_seq SET DEFAULT nextval('todo_change_seq');
+ALTER TABLE todo_tombstones ALTER COLUMN change_seq SET DEFAULT nextval('todo_change_seq');
+
+CREATE OR REPLACE FUNCTION bump_todo_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = nextval('todo_change_seq');
+    IF TG_OP = 'UPDATE' THEN
+        NEW.version = OLD.version + 1;
+    END IF;
+    IF NEW.ical_uid IS NULL THEN
+        NEW.ical_uid = 'todo-' || NEW.id || '@todo-go';
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP FUNCTION IF EXISTS next_todo_change_seq(INTEGER);
+DROP TABLE IF EXISTS todo_change_counters;
+-- +goose StatementEnd